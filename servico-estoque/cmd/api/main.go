@@ -7,14 +7,29 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
-	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+
+	"servico-estoque/internal/auth"
+	"servico-estoque/internal/config"
+	"servico-estoque/internal/domain"
+	"servico-estoque/internal/handler"
+	"servico-estoque/internal/metricas"
+	"servico-estoque/internal/middleware"
+	"servico-estoque/internal/repository"
+	"servico-estoque/internal/service"
+	"servico-estoque/internal/tenant"
+	"servico-estoque/pkg/lock"
 )
 
 func main() {
@@ -22,61 +37,150 @@ func main() {
 	logger, _ := zap.NewProduction()
 	defer logger.Sync()
 
-	// DSN com variável de ambiente
-	dbHost := os.Getenv("DB_HOST")
-	if dbHost == "" {
-		dbHost = "localhost" // fallback local
+	// Quantidades saem como número no JSON, como antes de serem decimais
+	decimal.MarshalJSONWithoutQuotes = true
+
+	// Configuração
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		logger.Fatal("Erro ao carregar configuração", zap.Error(err))
 	}
-dbName := os.Getenv("DB_NAME")
-if dbName == "" {
-    dbName = "faturamento"
-}
 
-	dsn := fmt.Sprintf("host=%s user=postgres password=postgres dbname=" + dbName + " port=5432 sslmode=disable", dbHost)
-	
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(cfg.DB.DSN()), &gorm.Config{})
 	if err != nil {
 		logger.Fatal("Erro ao conectar ao banco", zap.Error(err))
 	}
-	db.AutoMigrate(&Produto{})
+	if err := db.Use(repository.IsolamentoTenant{}); err != nil {
+		logger.Fatal("Erro ao configurar isolamento por empresa", zap.Error(err))
+	}
+	// A migração enxerga todas as empresas
+	if err := db.WithContext(tenant.Sistema(context.Background())).AutoMigrate(
+		&domain.Deposito{},
+		&domain.Categoria{},
+		&domain.Produto{},
+		&domain.ConversaoUnidade{},
+		&domain.SaldoDeposito{},
+		&domain.Lote{},
+		&domain.ReservaEstoque{},
+		&domain.ReservaLote{},
+		&domain.NumeroSerie{},
+		&domain.HistoricoSerie{},
+		&domain.MovimentoEstoque{},
+		&domain.BaixaEstoque{},
+		&domain.ItemBaixa{},
+		&domain.LoteBaixa{},
+		&domain.ChaveAPI{},
+	); err != nil {
+		logger.Fatal("Erro ao migrar banco", zap.Error(err))
+	}
+	if err := repository.MigrarTenants(context.Background(), db, cfg.Tenant.Legado); err != nil {
+		logger.Fatal("Erro ao migrar dados para o isolamento por empresa", zap.Error(err))
+	}
+
+	// Redis
+	redisOpts, err := cfg.Redis.Options()
+	if err != nil {
+		logger.Fatal("Erro ao configurar Redis", zap.Error(err))
+	}
+	redisClient := redis.NewClient(redisOpts)
+	defer redisClient.Close()
+
+	// Camadas
+	alocacao, err := domain.NovaEstrategiaAlocacao(cfg.Deposito.Estrategia, cfg.Deposito.Prioridade)
+	if err != nil {
+		logger.Fatal("Erro ao configurar alocação de depósitos", zap.Error(err))
+	}
+	produtoRepo := repository.NewProdutoRepository(db, alocacao)
+	depositoRepo := repository.NewDepositoRepository(db)
+	categoriaRepo := repository.NewCategoriaRepository(db)
+	if err := produtoRepo.Inicializar(context.Background()); err != nil {
+		logger.Fatal("Erro ao preparar busca de produtos", zap.Error(err))
+	}
+	distributedLock := lock.NewDistributedLock(redisClient)
+	estoqueService := service.NewEstoqueService(produtoRepo, depositoRepo, categoriaRepo, redisClient, distributedLock, service.Options{
+		ReservaTTL:         cfg.Reserva.TTL,
+		IdempotenciaTTL:    cfg.Reserva.IdempotenciaTTL,
+		CacheProdutoTTL:    cfg.Cache.ProdutoTTL,
+		CacheListaTTL:      cfg.Cache.ListaTTL,
+		LockReservaTTL:     cfg.Lock.ReservaTTL,
+		LockBaixaTTL:       cfg.Lock.BaixaTTL,
+		ExpiracaoIntervalo: cfg.Reserva.ExpiracaoIntervalo,
+		ExpiracaoLote:      cfg.Reserva.ExpiracaoLote,
+		LockExpiracaoTTL:   cfg.Lock.ExpiracaoTTL,
+	}, logger)
+	// Empresas novas são preparadas na primeira requisição
+	if err := estoqueService.PrepararTenants(context.Background()); err != nil {
+		logger.Fatal("Erro ao inicializar empresas", zap.Error(err))
+	}
+	chaveAPIService := service.NewChaveAPIService(repository.NewChaveAPIRepository(db), redisClient, cfg.ChavesAPI.CacheTTL, logger)
+	produtoHandler := handler.NewProdutoHandler(estoqueService, logger)
+	depositoHandler := handler.NewDepositoHandler(estoqueService, logger)
+	categoriaHandler := handler.NewCategoriaHandler(estoqueService, logger)
+	relatorioHandler := handler.NewRelatorioHandler(estoqueService, logger)
+	chaveAPIHandler := handler.NewChaveAPIHandler(chaveAPIService, logger)
+
+	// Rotinas em segundo plano
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	go estoqueService.IniciarExpiracaoReservas(bgCtx)
+
+	// Autenticação
+	validador, err := auth.NovoValidadorJWT(auth.Opcoes{
+		JWKSArquivo: cfg.Auth.JWKSArquivo,
+		Segredo:     cfg.Auth.Segredo,
+		Emissor:     cfg.Auth.Emissor,
+		Audiencia:   cfg.Auth.Audiencia,
+	})
+	if err != nil {
+		logger.Fatal("Erro ao configurar autenticação", zap.Error(err))
+	}
 
 	// Gin
 	r := gin.Default()
-	r.Use(cors.Default())
+	r.Use(middleware.Metricas())
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowOrigins = cfg.HTTP.CORSOrigens
+	corsConfig.AddAllowHeaders("Authorization", middleware.CabecalhoChaveAPI, middleware.CabecalhoTenant, "If-Match", middleware.CabecalhoIdempotencia)
+	corsConfig.AddExposeHeaders("ETag", "Idempotent-Replayed",
+		"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After")
+	r.Use(cors.New(corsConfig))
 
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "healthy"})
 	})
 
-	r.GET("/api/produtos", func(c *gin.Context) {
-		var produtos []Produto
-		db.Find(&produtos)
-		c.JSON(200, produtos)
-	})
-
-	r.POST("/api/produtos", func(c *gin.Context) {
-		var p Produto
-		if err := c.ShouldBindJSON(&p); err == nil {
-			db.Create(&p)
-			c.JSON(201, p)
-		} else {
-			c.JSON(400, gin.H{"error": err.Error()})
-		}
-	})
+	// Métricas do Prometheus, fora de /api e sem autenticação, como o health
+	prometheus.MustRegister(metricas.NovoColetorEstoque(produtoRepo, logger))
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
-	r.POST("/api/produtos/reservar", func(c *gin.Context) {
-		var req ReservaRequest
-		if err := c.ShouldBindJSON(&req); err == nil {
-			c.JSON(200, gin.H{
-				"reservaId": req.NotaFiscalId,
-				"mensagem":  "Reserva simulada com sucesso",
-			})
-		} else {
-			c.JSON(400, gin.H{"error": err.Error()})
-		}
-	})
+	idempotencia := middleware.NewIdempotencia(redisClient, middleware.IdempotenciaOptions{
+		TTL:         cfg.Idempotencia.TTL,
+		ExecucaoTTL: cfg.Idempotencia.ExecucaoTTL,
+		Espera:      cfg.Idempotencia.Espera,
+		CorpoMaximo: int64(cfg.Idempotencia.CorpoMaximo),
+	}, logger)
 
-	port := "8080"
+	autenticacao := middleware.NewAutenticacao(validador, chaveAPIService, logger)
+	tenantMiddleware := middleware.NewTenant(estoqueService, logger)
+	limitesClientes, err := cfg.Limite.LimitesClientes()
+	if err != nil {
+		logger.Fatal("Erro ao configurar limites de requisições", zap.Error(err))
+	}
+	limite := middleware.NewLimite(redisClient, middleware.LimiteOptions{
+		Janela:   cfg.Limite.Janela,
+		Limites:  cfg.Limite.Limites(),
+		Clientes: limitesClientes,
+	}, logger)
+
+	api := r.Group("/api")
+	api.Use(autenticacao.Handler(), tenantMiddleware.Handler(), limite.Handler(), idempotencia.Handler())
+	produtoHandler.RegisterRoutes(api)
+	depositoHandler.RegisterRoutes(api)
+	categoriaHandler.RegisterRoutes(api)
+	relatorioHandler.RegisterRoutes(api)
+	chaveAPIHandler.RegisterRoutes(api)
+
+	port := strconv.Itoa(cfg.HTTP.Port)
 	srv := &http.Server{
 		Addr:    ":" + port,
 		Handler: r,
@@ -94,27 +198,11 @@ if dbName == "" {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	fmt.Println("Desligando...")
+	stopBackground()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatal("Shutdown:", err)
 	}
 }
-
-type Produto struct {
-	ID        uint   `json:"id" gorm:"primaryKey"`
-	Codigo    string `json:"codigo"`
-	Descricao string `json:"descricao"`
-	Saldo     int    `json:"saldo"`
-}
-
-type ReservaRequest struct {
-	NotaFiscalId string        `json:"notaFiscalId"`
-	Itens        []ReservaItem `json:"itens"`
-}
-
-type ReservaItem struct {
-	ProdutoId  string `json:"produtoId"`
-	Quantidade int    `json:"quantidade"`
-}
@@ -0,0 +1,136 @@
+// Comando chaves administra as chaves de API das integrações de uma empresa,
+// com as mesmas regras de /api/chaves-api.
+//
+//	chaves -tenant 11222333000181 criar -nome faturamento -escopos reservas:write,estoque:baixar [-expira 2026-12-31T23:59:59Z]
+//	chaves -tenant 11222333000181 listar
+//	chaves -tenant 11222333000181 rotacionar <id>
+//	chaves -tenant 11222333000181 revogar <id>
+//
+// A conexão com banco e Redis vem das variáveis de ambiente ou do arquivo
+// indicado em CONFIG_FILE, como na API. O resultado sai em JSON na saída
+// padrão; em criar e rotacionar ele traz a chave completa, exibida só nesse
+// momento.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"servico-estoque/internal/config"
+	"servico-estoque/internal/domain"
+	"servico-estoque/internal/repository"
+	"servico-estoque/internal/service"
+	"servico-estoque/internal/tenant"
+)
+
+const uso = `uso:
+  chaves -tenant CNPJ criar -nome NOME -escopos ESCOPO[,ESCOPO...] [-expira RFC3339]
+  chaves -tenant CNPJ listar
+  chaves -tenant CNPJ rotacionar ID
+  chaves -tenant CNPJ revogar ID
+
+escopos: `
+
+func main() {
+	global := flag.NewFlagSet("chaves", flag.ExitOnError)
+	empresa := global.String("tenant", "", "CNPJ da empresa dona das chaves")
+	global.Usage = sair
+	global.Parse(os.Args[1:])
+	if global.NArg() < 1 || *empresa == "" {
+		sair()
+	}
+	comando, args := global.Arg(0), global.Args()[1:]
+
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		logger.Fatal("Erro ao carregar configuração", zap.Error(err))
+	}
+	db, err := gorm.Open(postgres.Open(cfg.DB.DSN()), &gorm.Config{})
+	if err != nil {
+		logger.Fatal("Erro ao conectar ao banco", zap.Error(err))
+	}
+	cnpj, err := domain.NormalizarCNPJ(*empresa)
+	if err != nil {
+		logger.Fatal("Empresa inválida", zap.Error(err))
+	}
+	if err := db.Use(repository.IsolamentoTenant{}); err != nil {
+		logger.Fatal("Erro ao configurar isolamento por empresa", zap.Error(err))
+	}
+	if err := db.WithContext(tenant.Sistema(context.Background())).AutoMigrate(&domain.ChaveAPI{}); err != nil {
+		logger.Fatal("Erro ao migrar banco", zap.Error(err))
+	}
+	redisOpts, err := cfg.Redis.Options()
+	if err != nil {
+		logger.Fatal("Erro ao configurar Redis", zap.Error(err))
+	}
+	redisClient := redis.NewClient(redisOpts)
+	defer redisClient.Close()
+
+	chaves := service.NewChaveAPIService(repository.NewChaveAPIRepository(db), redisClient, cfg.ChavesAPI.CacheTTL, logger)
+	ctx := tenant.Com(context.Background(), cnpj)
+
+	var resultado any
+	switch comando {
+	case "criar":
+		fs := flag.NewFlagSet("criar", flag.ExitOnError)
+		nome := fs.String("nome", "", "nome da integração dona da chave")
+		escopos := fs.String("escopos", "", "escopos separados por vírgula")
+		expira := fs.String("expira", "", "expiração em RFC 3339 (padrão: não expira)")
+		fs.Parse(args)
+		if *nome == "" || *escopos == "" {
+			sair()
+		}
+		req := domain.CriarChaveAPIRequest{Nome: *nome, Escopos: strings.Split(*escopos, ",")}
+		if *expira != "" {
+			t, err := time.Parse(time.RFC3339, *expira)
+			if err != nil {
+				logger.Fatal("Expiração inválida", zap.Error(err))
+			}
+			req.ExpiraEm = &t
+		}
+		resultado, err = chaves.CriarChave(ctx, req)
+	case "listar":
+		resultado, err = chaves.ListarChaves(ctx)
+	case "rotacionar", "revogar":
+		if len(args) != 1 {
+			sair()
+		}
+		id, errID := uuid.Parse(args[0])
+		if errID != nil {
+			logger.Fatal("ID inválido", zap.Error(errID))
+		}
+		if comando == "rotacionar" {
+			resultado, err = chaves.RotacionarChave(ctx, id)
+		} else {
+			resultado, err = chaves.RevogarChave(ctx, id)
+		}
+	default:
+		sair()
+	}
+	if err != nil {
+		logger.Fatal("Erro ao "+comando+" chave de API", zap.Error(err))
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(resultado)
+}
+
+func sair() {
+	fmt.Fprintln(os.Stderr, uso+strings.Join(domain.EscoposChaveAPI, ", "))
+	os.Exit(2)
+}
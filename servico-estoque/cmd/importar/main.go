@@ -0,0 +1,119 @@
+// Comando importar cadastra produtos em massa de uma empresa a partir de um
+// CSV ou XLSX, com as mesmas regras de POST /api/produtos/importar.
+//
+//	importar -tenant CNPJ [-dry-run] [-upsert] [-formato csv|xlsx] arquivo
+//
+// A conexão com banco e Redis vem das variáveis de ambiente ou do arquivo
+// indicado em CONFIG_FILE, como na API. O relatório sai em JSON na saída
+// padrão e o código de saída é 1 se alguma linha falhar.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"servico-estoque/internal/config"
+	"servico-estoque/internal/domain"
+	"servico-estoque/internal/repository"
+	"servico-estoque/internal/service"
+	"servico-estoque/internal/tenant"
+	"servico-estoque/pkg/lock"
+	"servico-estoque/pkg/planilha"
+)
+
+func main() {
+	fs := flag.NewFlagSet("importar", flag.ExitOnError)
+	empresa := fs.String("tenant", "", "CNPJ da empresa dona dos produtos")
+	dryRun := fs.Bool("dry-run", false, "apenas valida as linhas, sem gravar")
+	upsert := fs.Bool("upsert", false, "atualiza produtos com código já cadastrado")
+	formato := fs.String("formato", "", "csv ou xlsx (padrão: extensão do arquivo)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "uso: importar -tenant CNPJ [-dry-run] [-upsert] [-formato csv|xlsx] arquivo")
+		fs.PrintDefaults()
+	}
+	fs.Parse(os.Args[1:])
+	if fs.NArg() != 1 || *empresa == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	caminho := fs.Arg(0)
+	if *formato == "" {
+		*formato = planilha.FormatoDoArquivo(caminho)
+	}
+
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		logger.Fatal("Erro ao carregar configuração", zap.Error(err))
+	}
+
+	cnpj, err := domain.NormalizarCNPJ(*empresa)
+	if err != nil {
+		logger.Fatal("Empresa inválida", zap.Error(err))
+	}
+
+	db, err := gorm.Open(postgres.Open(cfg.DB.DSN()), &gorm.Config{})
+	if err != nil {
+		logger.Fatal("Erro ao conectar ao banco", zap.Error(err))
+	}
+	if err := db.Use(repository.IsolamentoTenant{}); err != nil {
+		logger.Fatal("Erro ao configurar isolamento por empresa", zap.Error(err))
+	}
+	redisOpts, err := cfg.Redis.Options()
+	if err != nil {
+		logger.Fatal("Erro ao configurar Redis", zap.Error(err))
+	}
+	redisClient := redis.NewClient(redisOpts)
+	defer redisClient.Close()
+
+	alocacao, err := domain.NovaEstrategiaAlocacao(cfg.Deposito.Estrategia, cfg.Deposito.Prioridade)
+	if err != nil {
+		logger.Fatal("Erro ao configurar alocação de depósitos", zap.Error(err))
+	}
+	estoqueService := service.NewEstoqueService(
+		repository.NewProdutoRepository(db, alocacao),
+		repository.NewDepositoRepository(db),
+		repository.NewCategoriaRepository(db),
+		redisClient,
+		lock.NewDistributedLock(redisClient),
+		service.Options{CacheProdutoTTL: cfg.Cache.ProdutoTTL, CacheListaTTL: cfg.Cache.ListaTTL},
+		logger,
+	)
+
+	ctx := tenant.Com(context.Background(), cnpj)
+	if err := estoqueService.PrepararTenant(ctx); err != nil {
+		logger.Fatal("Erro ao preparar empresa", zap.Error(err))
+	}
+
+	arquivo, err := os.Open(caminho)
+	if err != nil {
+		logger.Fatal("Erro ao abrir arquivo", zap.Error(err))
+	}
+	defer arquivo.Close()
+
+	resultado, err := estoqueService.ImportarProdutos(ctx, arquivo, *formato, domain.OpcoesImportacao{
+		DryRun: *dryRun,
+		Upsert: *upsert,
+	})
+	if err != nil {
+		logger.Fatal("Erro ao importar produtos", zap.Error(err))
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(resultado)
+	if resultado.Erros > 0 {
+		logger.Sync()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,107 @@
+// internal/auth/auth.go
+package auth
+
+import (
+	"slices"
+
+	"servico-estoque/internal/domain"
+)
+
+// Papéis aceitos nos tokens. Nos claims o papel de serviço pode vir com ou
+// sem acento ("serviço" ou "servico").
+const (
+	PapelLeitura  = "leitura"
+	PapelOperador = "operador"
+	PapelGestor   = "gestor"
+	PapelServico  = "servico"
+)
+
+// Principal é quem fez a requisição, já autenticado: um usuário (token JWT,
+// com papéis) ou uma integração (chave de API, com escopos). Tenants são os
+// CNPJs das empresas a que ele tem acesso.
+type Principal struct {
+	Sujeito string   `json:"sujeito"`
+	Papeis  []string `json:"papeis,omitempty"`
+	Escopos []string `json:"escopos,omitempty"`
+	Tenants []string `json:"tenants"`
+}
+
+// Permissao lista quem pode executar uma operação: usuários com algum dos
+// papéis ou chaves de API com algum dos escopos
+type Permissao struct {
+	Papeis  []string
+	Escopos []string
+}
+
+// NovoPrincipal normaliza os papéis (caixa e acentos) e os CNPJs recebidos e
+// descarta os desconhecidos ou inválidos
+func NovoPrincipal(sujeito string, papeis, tenants []string) *Principal {
+	p := &Principal{Sujeito: sujeito}
+	for _, t := range tenants {
+		if cnpj, err := domain.NormalizarCNPJ(t); err == nil && !slices.Contains(p.Tenants, cnpj) {
+			p.Tenants = append(p.Tenants, cnpj)
+		}
+	}
+	for _, papel := range papeis {
+		papel = domain.NormalizarTermo(papel)
+		switch papel {
+		case PapelLeitura, PapelOperador, PapelGestor, PapelServico:
+			if !slices.Contains(p.Papeis, papel) {
+				p.Papeis = append(p.Papeis, papel)
+			}
+		}
+	}
+	return p
+}
+
+// NovoPrincipalChave identifica uma chave de API pelo seu ID; ela só acessa
+// a empresa em que foi criada
+func NovoPrincipalChave(id, tenant string, escopos []string) *Principal {
+	return &Principal{Sujeito: "chave-api:" + id, Escopos: escopos, Tenants: []string{tenant}}
+}
+
+// Tenant escolhe a empresa da requisição. pedido é o CNPJ já normalizado do
+// cabeçalho X-Tenant-ID, ou vazio: nesse caso vale a única empresa do
+// principal, e quem tem acesso a várias precisa escolher.
+func (p *Principal) Tenant(pedido string) (string, error) {
+	if pedido != "" {
+		if !slices.Contains(p.Tenants, pedido) {
+			return "", domain.ErrOperacaoNaoPermitida
+		}
+		return pedido, nil
+	}
+	switch len(p.Tenants) {
+	case 0:
+		return "", domain.ErrOperacaoNaoPermitida
+	case 1:
+		return p.Tenants[0], nil
+	}
+	return "", domain.ErrTenantObrigatorio
+}
+
+// Permite indica se o principal atende à permissão
+func (p *Principal) Permite(perm Permissao) bool {
+	if p == nil {
+		return false
+	}
+	for _, escopo := range perm.Escopos {
+		if slices.Contains(p.Escopos, escopo) {
+			return true
+		}
+	}
+	return p.TemAlgum(perm.Papeis...)
+}
+
+// TemAlgum indica se o principal tem ao menos um dos papéis. Um principal
+// nil (requisição não autenticada) não tem nenhum.
+func (p *Principal) TemAlgum(papeis ...string) bool {
+	if p == nil {
+		return false
+	}
+	for _, papel := range papeis {
+		if slices.Contains(p.Papeis, papel) {
+			return true
+		}
+	}
+	return false
+}
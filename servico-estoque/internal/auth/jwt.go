@@ -0,0 +1,238 @@
+// internal/auth/jwt.go
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// tamanhoMinimoSegredo é o menor segredo compartilhado aceito para HMAC
+const tamanhoMinimoSegredo = 32
+
+// toleranciaRelogio absorve diferenças de relógio entre emissor e serviço
+const toleranciaRelogio = 30 * time.Second
+
+// Opcoes configura a validação dos tokens. Exatamente um entre JWKSArquivo
+// (chaves públicas RSA ou EC, ou chaves simétricas "oct") e Segredo (HMAC)
+// deve ser informado. Emissor e Audiencia, quando informados, são exigidos
+// nos claims iss e aud.
+type Opcoes struct {
+	JWKSArquivo string
+	Segredo     string
+	Emissor     string
+	Audiencia   string
+}
+
+// claims são os claims lidos do token: os registrados, os papéis e as
+// empresas (CNPJ), em tenant quando é uma só ou em tenants
+type claims struct {
+	jwt.RegisteredClaims
+	Roles   []string `json:"roles"`
+	Tenant  string   `json:"tenant"`
+	Tenants []string `json:"tenants"`
+}
+
+// ValidadorJWT valida tokens JWT assinados com as chaves configuradas
+type ValidadorJWT struct {
+	parser  *jwt.Parser
+	chaves  map[string]any
+	unica   any
+	segredo []byte
+}
+
+// NovoValidadorJWT carrega as chaves e prepara a validação
+func NovoValidadorJWT(opcoes Opcoes) (*ValidadorJWT, error) {
+	parserOpts := []jwt.ParserOption{
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(toleranciaRelogio),
+	}
+	if opcoes.Emissor != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(opcoes.Emissor))
+	}
+	if opcoes.Audiencia != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(opcoes.Audiencia))
+	}
+
+	v := &ValidadorJWT{}
+	switch {
+	case opcoes.JWKSArquivo != "" && opcoes.Segredo != "":
+		return nil, errors.New("informe o arquivo JWKS ou o segredo compartilhado, não os dois")
+	case opcoes.JWKSArquivo != "":
+		chaves, err := lerJWKS(opcoes.JWKSArquivo)
+		if err != nil {
+			return nil, err
+		}
+		v.chaves = chaves
+		if len(chaves) == 1 {
+			for _, k := range chaves {
+				v.unica = k
+			}
+		}
+		parserOpts = append(parserOpts, jwt.WithValidMethods([]string{
+			"RS256", "RS384", "RS512", "PS256", "PS384", "PS512",
+			"ES256", "ES384", "ES512", "HS256", "HS384", "HS512",
+		}))
+	case opcoes.Segredo != "":
+		if len(opcoes.Segredo) < tamanhoMinimoSegredo {
+			return nil, fmt.Errorf("segredo JWT deve ter ao menos %d caracteres", tamanhoMinimoSegredo)
+		}
+		v.segredo = []byte(opcoes.Segredo)
+		parserOpts = append(parserOpts, jwt.WithValidMethods([]string{"HS256", "HS384", "HS512"}))
+	default:
+		return nil, errors.New("autenticação exige um arquivo JWKS ou um segredo compartilhado")
+	}
+
+	v.parser = jwt.NewParser(parserOpts...)
+	return v, nil
+}
+
+// Validar confere assinatura, validade, emissor e audiência do token e
+// retorna o principal com os papéis e as empresas do token
+func (v *ValidadorJWT) Validar(token string) (*Principal, error) {
+	var c claims
+	if _, err := v.parser.ParseWithClaims(token, &c, v.chave); err != nil {
+		return nil, err
+	}
+	tenants := c.Tenants
+	if c.Tenant != "" {
+		tenants = append(tenants, c.Tenant)
+	}
+	return NovoPrincipal(c.Subject, c.Roles, tenants), nil
+}
+
+// chave escolhe a chave de verificação pelo kid do cabeçalho. Sem kid, só
+// é aceito quando há uma única chave.
+func (v *ValidadorJWT) chave(t *jwt.Token) (any, error) {
+	if v.segredo != nil {
+		return v.segredo, nil
+	}
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" {
+		if v.unica == nil {
+			return nil, errors.New("token sem kid")
+		}
+		return v.unica, nil
+	}
+	k, ok := v.chaves[kid]
+	if !ok {
+		return nil, fmt.Errorf("kid desconhecido: %s", kid)
+	}
+	return k, nil
+}
+
+// jwk é uma chave do JWKS, com os campos usados para RSA, EC e oct
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// lerJWKS lê as chaves de assinatura do arquivo, indexadas pelo kid.
+// Chaves marcadas para criptografia (use "enc") são ignoradas.
+func lerJWKS(caminho string) (map[string]any, error) {
+	conteudo, err := os.ReadFile(caminho)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler JWKS: %w", err)
+	}
+	var conjunto struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(conteudo, &conjunto); err != nil {
+		return nil, fmt.Errorf("erro ao decodificar JWKS %s: %w", caminho, err)
+	}
+
+	chaves := make(map[string]any, len(conjunto.Keys))
+	for i, k := range conjunto.Keys {
+		if k.Use == "enc" {
+			continue
+		}
+		chave, err := k.chavePublica()
+		if err != nil {
+			return nil, fmt.Errorf("JWKS %s: chave %d (%s): %w", caminho, i, k.Kid, err)
+		}
+		if _, repetida := chaves[k.Kid]; repetida {
+			return nil, fmt.Errorf("JWKS %s: kid repetido: %q", caminho, k.Kid)
+		}
+		chaves[k.Kid] = chave
+	}
+	if len(chaves) == 0 {
+		return nil, fmt.Errorf("JWKS %s não tem chaves de assinatura", caminho)
+	}
+	return chaves, nil
+}
+
+func (k jwk) chavePublica() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := inteiroBase64(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := inteiroBase64(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() {
+			return nil, errors.New("expoente RSA inválido")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curva elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curva = elliptic.P256()
+		case "P-384":
+			curva = elliptic.P384()
+		case "P-521":
+			curva = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("curva não suportada: %s", k.Crv)
+		}
+		x, err := inteiroBase64(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := inteiroBase64(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curva.IsOnCurve(x, y) {
+			return nil, errors.New("ponto fora da curva")
+		}
+		return &ecdsa.PublicKey{Curve: curva, X: x, Y: y}, nil
+	case "oct":
+		segredo, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return nil, err
+		}
+		if len(segredo) < tamanhoMinimoSegredo {
+			return nil, fmt.Errorf("chave simétrica deve ter ao menos %d bytes", tamanhoMinimoSegredo)
+		}
+		return segredo, nil
+	}
+	return nil, fmt.Errorf("tipo de chave não suportado: %s", k.Kty)
+}
+
+func inteiroBase64(v string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("valor base64url inválido")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,230 @@
+// internal/auth/jwt_test.go
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	segredoTeste   = "segredo-de-teste-com-pelo-menos-32-caracteres"
+	emissorTeste   = "https://auth.korp.test"
+	audienciaTeste = "servico-estoque"
+)
+
+// claimsTeste são claims válidos para os validadores de teste
+func claimsTeste() jwt.MapClaims {
+	agora := time.Now()
+	return jwt.MapClaims{
+		"sub":   "usuario-1",
+		"iss":   emissorTeste,
+		"aud":   audienciaTeste,
+		"exp":   agora.Add(time.Hour).Unix(),
+		"iat":   agora.Unix(),
+		"roles": []string{"operador"},
+	}
+}
+
+func assinar(t *testing.T, metodo jwt.SigningMethod, chave any, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(metodo, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	assinado, err := token.SignedString(chave)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return assinado
+}
+
+func base64URL(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func TestNovoValidadorJWTExigeUmaFonteDeChaves(t *testing.T) {
+	casos := []struct {
+		nome   string
+		opcoes Opcoes
+	}{
+		{"sem chaves", Opcoes{}},
+		{"segredo curto", Opcoes{Segredo: "curto"}},
+		{"segredo e JWKS", Opcoes{Segredo: segredoTeste, JWKSArquivo: "jwks.json"}},
+		{"JWKS inexistente", Opcoes{JWKSArquivo: filepath.Join(t.TempDir(), "nao-existe.json")}},
+	}
+	for _, c := range casos {
+		if _, err := NovoValidadorJWT(c.opcoes); err == nil {
+			t.Errorf("%s: validador criado sem erro", c.nome)
+		}
+	}
+}
+
+func TestValidarTokenComSegredo(t *testing.T) {
+	v, err := NovoValidadorJWT(Opcoes{Segredo: segredoTeste, Emissor: emissorTeste, Audiencia: audienciaTeste})
+	if err != nil {
+		t.Fatal(err)
+	}
+	com := func(alterar func(jwt.MapClaims)) jwt.MapClaims {
+		c := claimsTeste()
+		alterar(c)
+		return c
+	}
+	outroRSA, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	casos := []struct {
+		nome   string
+		token  string
+		valido bool
+	}{
+		{"válido", assinar(t, jwt.SigningMethodHS256, []byte(segredoTeste), "", claimsTeste()), true},
+		{"HS512", assinar(t, jwt.SigningMethodHS512, []byte(segredoTeste), "", claimsTeste()), true},
+		{"dentro da tolerância de relógio", assinar(t, jwt.SigningMethodHS256, []byte(segredoTeste), "",
+			com(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-10 * time.Second).Unix() })), true},
+		{"assinatura de outro segredo", assinar(t, jwt.SigningMethodHS256, []byte(segredoTeste+"x"), "", claimsTeste()), false},
+		{"emissor errado", assinar(t, jwt.SigningMethodHS256, []byte(segredoTeste), "",
+			com(func(c jwt.MapClaims) { c["iss"] = "https://outro.test" })), false},
+		{"sem emissor", assinar(t, jwt.SigningMethodHS256, []byte(segredoTeste), "",
+			com(func(c jwt.MapClaims) { delete(c, "iss") })), false},
+		{"audiência errada", assinar(t, jwt.SigningMethodHS256, []byte(segredoTeste), "",
+			com(func(c jwt.MapClaims) { c["aud"] = "servico-faturamento" })), false},
+		{"audiência em lista", assinar(t, jwt.SigningMethodHS256, []byte(segredoTeste), "",
+			com(func(c jwt.MapClaims) { c["aud"] = []string{"outro", audienciaTeste} })), true},
+		{"expirado", assinar(t, jwt.SigningMethodHS256, []byte(segredoTeste), "",
+			com(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() })), false},
+		{"sem expiração", assinar(t, jwt.SigningMethodHS256, []byte(segredoTeste), "",
+			com(func(c jwt.MapClaims) { delete(c, "exp") })), false},
+		{"ainda não válido", assinar(t, jwt.SigningMethodHS256, []byte(segredoTeste), "",
+			com(func(c jwt.MapClaims) { c["nbf"] = time.Now().Add(time.Hour).Unix() })), false},
+		{"alg none", assinar(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", claimsTeste()), false},
+		{"RS256 com o validador HMAC", assinar(t, jwt.SigningMethodRS256, outroRSA, "", claimsTeste()), false},
+		{"malformado", "abc.def.ghi", false},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			_, err := v.Validar(c.token)
+			if (err == nil) != c.valido {
+				t.Errorf("Validar = %v, válido esperado %v", err, c.valido)
+			}
+		})
+	}
+}
+
+func TestValidarNormalizaPapeisEEmpresas(t *testing.T) {
+	v, err := NovoValidadorJWT(Opcoes{Segredo: segredoTeste})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := claimsTeste()
+	claims["roles"] = []string{"Serviço", "GESTOR", "admin", "gestor"}
+	claims["tenant"] = "11.222.333/0001-81"
+	claims["tenants"] = []string{"12ABC34501DE35", "11222333000181", "00000000000000"}
+
+	p, err := v.Validar(assinar(t, jwt.SigningMethodHS256, []byte(segredoTeste), "", claims))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Sujeito != "usuario-1" {
+		t.Errorf("sujeito = %q", p.Sujeito)
+	}
+	if !slices.Equal(p.Papeis, []string{PapelServico, PapelGestor}) {
+		t.Errorf("papéis = %v, esperados [servico gestor]", p.Papeis)
+	}
+	if !slices.Equal(p.Tenants, []string{"12ABC34501DE35", "11222333000181"}) {
+		t.Errorf("empresas = %v, esperadas [12ABC34501DE35 11222333000181]", p.Tenants)
+	}
+}
+
+func TestValidarTokenComJWKS(t *testing.T) {
+	chaveRSA, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chaveEC, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outraRSA, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks, _ := json.Marshal(map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": base64URL(chaveRSA.N.Bytes()), "e": base64URL(big.NewInt(int64(chaveRSA.E)).Bytes())},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": base64URL(chaveEC.X.FillBytes(make([]byte, 32))), "y": base64URL(chaveEC.Y.FillBytes(make([]byte, 32)))},
+		{"kty": "RSA", "kid": "enc-1", "use": "enc", "n": "inválida", "e": "AQAB"},
+	}})
+	arquivo := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(arquivo, jwks, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	v, err := NovoValidadorJWT(Opcoes{JWKSArquivo: arquivo, Emissor: emissorTeste, Audiencia: audienciaTeste})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Token HS256 assinado com a chave pública RSA como segredo (confusão de
+	// algoritmo)
+	publica := chaveRSA.N.Bytes()
+
+	casos := []struct {
+		nome   string
+		token  string
+		valido bool
+	}{
+		{"RSA", assinar(t, jwt.SigningMethodRS256, chaveRSA, "rsa-1", claimsTeste()), true},
+		{"EC", assinar(t, jwt.SigningMethodES256, chaveEC, "ec-1", claimsTeste()), true},
+		{"kid de outra chave", assinar(t, jwt.SigningMethodRS256, chaveRSA, "ec-1", claimsTeste()), false},
+		{"assinado por outra chave", assinar(t, jwt.SigningMethodRS256, outraRSA, "rsa-1", claimsTeste()), false},
+		{"kid desconhecido", assinar(t, jwt.SigningMethodRS256, chaveRSA, "rsa-2", claimsTeste()), false},
+		{"kid de chave de criptografia", assinar(t, jwt.SigningMethodRS256, chaveRSA, "enc-1", claimsTeste()), false},
+		{"sem kid com várias chaves", assinar(t, jwt.SigningMethodRS256, chaveRSA, "", claimsTeste()), false},
+		{"HS256 com a chave pública", assinar(t, jwt.SigningMethodHS256, publica, "rsa-1", claimsTeste()), false},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			_, err := v.Validar(c.token)
+			if (err == nil) != c.valido {
+				t.Errorf("Validar = %v, válido esperado %v", err, c.valido)
+			}
+		})
+	}
+}
+
+func TestLerJWKSRecusaChavesInvalidas(t *testing.T) {
+	casos := map[string]string{
+		"sem chaves":          `{"keys":[]}`,
+		"só criptografia":     `{"keys":[{"kty":"RSA","kid":"a","use":"enc","n":"AQAB","e":"AQAB"}]}`,
+		"tipo desconhecido":   `{"keys":[{"kty":"OKP","kid":"a"}]}`,
+		"curva desconhecida":  `{"keys":[{"kty":"EC","kid":"a","crv":"P-192","x":"AQ","y":"AQ"}]}`,
+		"ponto fora da curva": `{"keys":[{"kty":"EC","kid":"a","crv":"P-256","x":"AQ","y":"AQ"}]}`,
+		"oct curta":           `{"keys":[{"kty":"oct","kid":"a","k":"` + base64URL([]byte("curta")) + `"}]}`,
+		"kid repetido": `{"keys":[{"kty":"oct","kid":"a","k":"` + base64URL([]byte(segredoTeste)) + `"},` +
+			`{"kty":"oct","kid":"a","k":"` + base64URL([]byte(segredoTeste)) + `"}]}`,
+		"JSON inválido": `{"keys":`,
+	}
+	for nome, conteudo := range casos {
+		arquivo := filepath.Join(t.TempDir(), strings.ReplaceAll(nome, " ", "-")+".json")
+		if err := os.WriteFile(arquivo, []byte(conteudo), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := lerJWKS(arquivo); err == nil {
+			t.Errorf("%s: JWKS aceito", nome)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+// internal/config/config_test.go
+package config
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestDBConfigDSN(t *testing.T) {
+	padrao := Default().DB
+	comSenha := padrao
+	comSenha.Password = "p@ss word/=:?#x"
+	comSenha.User = "estoque app"
+	ipv6 := padrao
+	ipv6.Host = "::1"
+	semUsuario := DBConfig{Host: "db", Port: 6543, Name: "outro"}
+
+	casos := []struct {
+		nome    string
+		config  DBConfig
+		host    string
+		porta   uint16
+		usuario string
+		senha   string
+		banco   string
+	}{
+		{"padrão sem senha", padrao, "localhost", 5432, "postgres", "", "estoque"},
+		{"senha e usuário com caracteres especiais", comSenha, "localhost", 5432, "estoque app", "p@ss word/=:?#x", "estoque"},
+		{"host IPv6", ipv6, "::1", 5432, "postgres", "", "estoque"},
+		{"sem usuário nem sslmode", semUsuario, "db", 6543, "", "", "outro"},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			// Sem usuário o pgx usa o do sistema operacional
+			t.Setenv("PGUSER", "")
+			t.Setenv("PGPASSWORD", "")
+			t.Setenv("PGDATABASE", "")
+
+			dsn := c.config.DSN()
+			pg, err := pgconn.ParseConfig(dsn)
+			if err != nil {
+				t.Fatalf("ParseConfig(%q): %v", dsn, err)
+			}
+			if pg.Host != c.host || pg.Port != c.porta || pg.Password != c.senha || pg.Database != c.banco {
+				t.Errorf("DSN %q = host %q porta %d senha %q banco %q; esperado %q %d %q %q",
+					dsn, pg.Host, pg.Port, pg.Password, pg.Database, c.host, c.porta, c.senha, c.banco)
+			}
+			if c.usuario != "" && pg.User != c.usuario {
+				t.Errorf("DSN %q = usuário %q, esperado %q", dsn, pg.User, c.usuario)
+			}
+		})
+	}
+}
+
+func TestDBConfigDSNUsaAURLInformada(t *testing.T) {
+	c := Default().DB
+	c.URL = "postgres://u:s@banco:5433/estoque?sslmode=require"
+	if dsn := c.DSN(); dsn != c.URL {
+		t.Errorf("DSN = %q, esperada a URL %q", dsn, c.URL)
+	}
+}
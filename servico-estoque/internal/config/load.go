@@ -0,0 +1,251 @@
+// internal/config/load.go
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// campo liga uma chave de configuração ao seu flag, à sua variável de
+// ambiente e ao campo correspondente em Config
+type campo struct {
+	chave string // chave no arquivo e nome do flag (ex.: "db.host")
+	env   []string
+	uso   string
+	set   func(c *Config, v string) error
+}
+
+func texto(chave, uso string, f func(*Config) *string, env ...string) campo {
+	return campo{chave: chave, env: env, uso: uso, set: func(c *Config, v string) error {
+		*f(c) = v
+		return nil
+	}}
+}
+
+func inteiro(chave, uso string, f func(*Config) *int, env ...string) campo {
+	return campo{chave: chave, env: env, uso: uso, set: func(c *Config, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("esperado número inteiro, recebido %q", v)
+		}
+		*f(c) = n
+		return nil
+	}}
+}
+
+func duracao(chave, uso string, f func(*Config) *time.Duration, env ...string) campo {
+	return campo{chave: chave, env: env, uso: uso, set: func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("esperada duração (ex.: 30s, 10m), recebido %q", v)
+		}
+		*f(c) = d
+		return nil
+	}}
+}
+
+func lista(chave, uso string, f func(*Config) *[]string, env ...string) campo {
+	return campo{chave: chave, env: env, uso: uso, set: func(c *Config, v string) error {
+		var itens []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				itens = append(itens, item)
+			}
+		}
+		*f(c) = itens
+		return nil
+	}}
+}
+
+var campos = []campo{
+	texto("db.url", "DSN completo do PostgreSQL", func(c *Config) *string { return &c.DB.URL }, "DATABASE_URL"),
+	texto("db.host", "host do PostgreSQL", func(c *Config) *string { return &c.DB.Host }, "DB_HOST"),
+	inteiro("db.port", "porta do PostgreSQL", func(c *Config) *int { return &c.DB.Port }, "DB_PORT"),
+	texto("db.user", "usuário do PostgreSQL", func(c *Config) *string { return &c.DB.User }, "DB_USER"),
+	texto("db.password", "senha do PostgreSQL", func(c *Config) *string { return &c.DB.Password }, "DB_PASSWORD"),
+	texto("db.name", "banco do PostgreSQL", func(c *Config) *string { return &c.DB.Name }, "DB_NAME"),
+	texto("db.sslmode", "sslmode do PostgreSQL", func(c *Config) *string { return &c.DB.SSLMode }, "DB_SSLMODE"),
+
+	texto("redis.url", "endereço ou URL do Redis", func(c *Config) *string { return &c.Redis.URL }, "REDIS_URL"),
+	texto("redis.password", "senha do Redis", func(c *Config) *string { return &c.Redis.Password }, "REDIS_PASSWORD"),
+	inteiro("redis.db", "número do banco do Redis", func(c *Config) *int { return &c.Redis.DB }, "REDIS_DB"),
+
+	inteiro("http.port", "porta HTTP", func(c *Config) *int { return &c.HTTP.Port }, "PORT", "SERVER_PORT"),
+	duracao("http.shutdown_timeout", "tempo máximo de desligamento", func(c *Config) *time.Duration { return &c.HTTP.ShutdownTimeout }, "HTTP_SHUTDOWN_TIMEOUT"),
+	lista("http.cors_origens", "origens autorizadas pelo CORS, separadas por vírgula", func(c *Config) *[]string { return &c.HTTP.CORSOrigens }, "CORS_ORIGINS"),
+
+	duracao("reserva.ttl", "validade de uma reserva pendente", func(c *Config) *time.Duration { return &c.Reserva.TTL }, "RESERVA_TTL"),
+	duracao("reserva.idempotencia_ttl", "retenção do resultado idempotente de reservas", func(c *Config) *time.Duration { return &c.Reserva.IdempotenciaTTL }, "RESERVA_IDEMPOTENCIA_TTL"),
+	duracao("reserva.expiracao_intervalo", "intervalo entre execuções da expiração de reservas", func(c *Config) *time.Duration { return &c.Reserva.ExpiracaoIntervalo }, "RESERVA_EXPIRACAO_INTERVALO"),
+	inteiro("reserva.expiracao_lote", "reservas expiradas por transação", func(c *Config) *int { return &c.Reserva.ExpiracaoLote }, "RESERVA_EXPIRACAO_LOTE"),
+
+	duracao("cache.produto_ttl", "TTL do cache de produto", func(c *Config) *time.Duration { return &c.Cache.ProdutoTTL }, "CACHE_PRODUTO_TTL"),
+	duracao("cache.lista_ttl", "TTL do cache da listagem", func(c *Config) *time.Duration { return &c.Cache.ListaTTL }, "CACHE_LISTA_TTL"),
+
+	duracao("lock.reserva_ttl", "TTL do lock de reserva", func(c *Config) *time.Duration { return &c.Lock.ReservaTTL }, "LOCK_RESERVA_TTL"),
+	duracao("lock.baixa_ttl", "TTL do lock de baixa", func(c *Config) *time.Duration { return &c.Lock.BaixaTTL }, "LOCK_BAIXA_TTL"),
+	duracao("lock.expiracao_ttl", "TTL do lock da expiração de reservas", func(c *Config) *time.Duration { return &c.Lock.ExpiracaoTTL }, "LOCK_EXPIRACAO_TTL"),
+
+	texto("deposito.estrategia", "estratégia de alocação de depósito (padrao, maior_saldo, prioridade)", func(c *Config) *string { return &c.Deposito.Estrategia }, "DEPOSITO_ESTRATEGIA"),
+	lista("deposito.prioridade", "códigos de depósito em ordem de prioridade, separados por vírgula", func(c *Config) *[]string { return &c.Deposito.Prioridade }, "DEPOSITO_PRIORIDADE"),
+
+	duracao("idempotencia.ttl", "retenção das respostas de requisições com Idempotency-Key", func(c *Config) *time.Duration { return &c.Idempotencia.TTL }, "IDEMPOTENCIA_TTL"),
+	duracao("idempotencia.execucao_ttl", "validade do marcador de requisição idempotente em execução", func(c *Config) *time.Duration { return &c.Idempotencia.ExecucaoTTL }, "IDEMPOTENCIA_EXECUCAO_TTL"),
+	duracao("idempotencia.espera", "espera máxima de uma requisição duplicada pela original", func(c *Config) *time.Duration { return &c.Idempotencia.Espera }, "IDEMPOTENCIA_ESPERA"),
+	inteiro("idempotencia.corpo_maximo", "tamanho máximo, em bytes, do corpo de requisições com Idempotency-Key", func(c *Config) *int { return &c.Idempotencia.CorpoMaximo }, "IDEMPOTENCIA_CORPO_MAXIMO"),
+
+	texto("auth.jwks_arquivo", "arquivo JWKS com as chaves públicas dos tokens", func(c *Config) *string { return &c.Auth.JWKSArquivo }, "AUTH_JWKS_FILE"),
+	texto("auth.segredo", "segredo compartilhado (HMAC) dos tokens", func(c *Config) *string { return &c.Auth.Segredo }, "AUTH_JWT_SECRET"),
+	texto("auth.emissor", "emissor (iss) exigido nos tokens", func(c *Config) *string { return &c.Auth.Emissor }, "AUTH_JWT_ISSUER"),
+	texto("auth.audiencia", "audiência (aud) exigida nos tokens", func(c *Config) *string { return &c.Auth.Audiencia }, "AUTH_JWT_AUDIENCE"),
+
+	duracao("chaves_api.cache_ttl", "tempo em que a consulta de uma chave de API fica em cache", func(c *Config) *time.Duration { return &c.ChavesAPI.CacheTTL }, "CHAVES_API_CACHE_TTL"),
+
+	texto("tenant.legado", "CNPJ que recebe os registros anteriores ao isolamento por empresa", func(c *Config) *string { return &c.Tenant.Legado }, "TENANT_LEGADO"),
+
+	duracao("limite.janela", "janela em que as requisições de cada cliente são contadas", func(c *Config) *time.Duration { return &c.Limite.Janela }, "LIMITE_JANELA"),
+	inteiro("limite.leitura", "requisições de consulta por cliente na janela (0 desliga)", func(c *Config) *int { return &c.Limite.Leitura }, "LIMITE_LEITURA"),
+	inteiro("limite.escrita", "requisições de cadastro e alteração por cliente na janela (0 desliga)", func(c *Config) *int { return &c.Limite.Escrita }, "LIMITE_ESCRITA"),
+	inteiro("limite.movimentacao", "reservas e baixas por cliente na janela (0 desliga)", func(c *Config) *int { return &c.Limite.Movimentacao }, "LIMITE_MOVIMENTACAO"),
+	inteiro("limite.massa", "importações, exportações e relatórios por cliente na janela (0 desliga)", func(c *Config) *int { return &c.Limite.Massa }, "LIMITE_MASSA"),
+	lista("limite.excecoes", "limites de clientes específicos (grupo:sujeito=limite), separados por vírgula", func(c *Config) *[]string { return &c.Limite.Excecoes }, "LIMITE_EXCECOES"),
+}
+
+// Load monta a configuração a partir dos valores padrão, flags, arquivo
+// opcional (YAML ou TOML) e variáveis de ambiente. A precedência é
+// ambiente > arquivo > flags > padrão. O arquivo é indicado por
+// CONFIG_FILE ou pelo flag -config.
+func Load(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("servico-estoque", flag.ContinueOnError)
+	arquivo := fs.String("config", "", "arquivo de configuração (.yaml, .yml ou .toml)")
+	valores := make(map[string]*string, len(campos))
+	for _, c := range campos {
+		valores[c.chave] = fs.String(c.chave, "", c.uso)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+	var errs []error
+
+	// Flags
+	fs.Visit(func(f *flag.Flag) {
+		for _, c := range campos {
+			if c.chave == f.Name {
+				if err := c.set(&cfg, *valores[c.chave]); err != nil {
+					errs = append(errs, fmt.Errorf("flag -%s: %w", c.chave, err))
+				}
+			}
+		}
+	})
+
+	// Arquivo
+	caminho := *arquivo
+	if v, ok := os.LookupEnv("CONFIG_FILE"); ok && v != "" {
+		caminho = v
+	}
+	if caminho != "" {
+		chaves, err := lerArquivo(caminho)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range campos {
+			if v, ok := chaves[c.chave]; ok {
+				if err := c.set(&cfg, v); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %s: %w", caminho, c.chave, err))
+				}
+				delete(chaves, c.chave)
+			}
+		}
+		for _, chave := range ordenadas(chaves) {
+			errs = append(errs, fmt.Errorf("%s: chave desconhecida %q", caminho, chave))
+		}
+	}
+
+	// Ambiente
+	for _, c := range campos {
+		for _, env := range c.env {
+			if v, ok := os.LookupEnv(env); ok && v != "" {
+				if err := c.set(&cfg, v); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", env, err))
+				}
+				break
+			}
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("configuração inválida:\n%w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("configuração inválida:\n%w", err)
+	}
+	return &cfg, nil
+}
+
+// lerArquivo decodifica o arquivo e achata as seções em chaves "secao.campo"
+func lerArquivo(caminho string) (map[string]string, error) {
+	conteudo, err := os.ReadFile(caminho)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler arquivo de configuração: %w", err)
+	}
+
+	bruto := map[string]any{}
+	switch strings.ToLower(filepath.Ext(caminho)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(conteudo, &bruto)
+	case ".toml":
+		err = toml.Unmarshal(conteudo, &bruto)
+	default:
+		return nil, fmt.Errorf("formato de configuração não suportado: %s", caminho)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ao decodificar %s: %w", caminho, err)
+	}
+
+	chaves := map[string]string{}
+	achatar("", bruto, chaves)
+	return chaves, nil
+}
+
+func achatar(prefixo string, valores map[string]any, destino map[string]string) {
+	for k, v := range valores {
+		chave := k
+		if prefixo != "" {
+			chave = prefixo + "." + k
+		}
+		switch v := v.(type) {
+		case map[string]any:
+			achatar(chave, v, destino)
+			continue
+		case []any:
+			itens := make([]string, len(v))
+			for i, item := range v {
+				itens[i] = fmt.Sprint(item)
+			}
+			destino[chave] = strings.Join(itens, ",")
+			continue
+		}
+		destino[chave] = fmt.Sprint(v)
+	}
+}
+
+func ordenadas(m map[string]string) []string {
+	chaves := make([]string, 0, len(m))
+	for k := range m {
+		chaves = append(chaves, k)
+	}
+	sort.Strings(chaves)
+	return chaves
+}
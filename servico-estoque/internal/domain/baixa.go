@@ -0,0 +1,71 @@
+// internal/domain/baixa.go
+package domain
+
+import (
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+)
+
+// Status possíveis de uma baixa direta
+const (
+    StatusBaixaEfetivada = "EFETIVADA"
+    StatusBaixaEstornada = "ESTORNADA"
+)
+
+// BaixaEstoque registra uma baixa direta de estoque, identificada pela
+// operação informada pelo chamador (ou pela nota fiscal). Todos os itens
+// saem na mesma transação; repetir a operação devolve este registro em vez
+// de baixar de novo, e o estorno devolve ao estoque exatamente o que saiu.
+// Impressao identifica os itens pedidos, para recusar a mesma operação com
+// outros itens.
+type BaixaEstoque struct {
+    ID            uuid.UUID   `gorm:"type:uuid;primary_key" json:"operacaoId"`
+    TenantID      string      `gorm:"type:varchar(14);not null;default:'';index" json:"-"`
+    NotaFiscalID  *uuid.UUID  `gorm:"type:uuid;index" json:"notaFiscalId,omitempty"`
+    Status        string      `gorm:"not null" json:"status"`
+    Impressao     string      `gorm:"not null" json:"-"`
+    Itens         []ItemBaixa `gorm:"foreignKey:BaixaID" json:"itens"`
+    MotivoEstorno string      `json:"motivoEstorno,omitempty"`
+    EstornadaEm   *time.Time  `json:"estornadaEm,omitempty"`
+    CreatedAt     time.Time   `gorm:"autoCreateTime" json:"createdAt"`
+    UpdatedAt     time.Time   `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+// ItemBaixa é a quantidade de um produto que saiu de um depósito em uma
+// baixa, com o custo médio baixado e, conforme o controle do produto, os
+// lotes consumidos ou os números de série vendidos
+type ItemBaixa struct {
+    ID            uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+    TenantID      string          `gorm:"type:varchar(14);not null;default:'';index" json:"-"`
+    BaixaID       uuid.UUID       `gorm:"type:uuid;not null;index" json:"-"`
+    ProdutoID     uuid.UUID       `gorm:"type:uuid;not null;index" json:"produtoId"`
+    DepositoID    uuid.UUID       `gorm:"type:uuid;not null" json:"depositoId"`
+    Quantidade    decimal.Decimal `gorm:"type:numeric(18,4);not null" json:"quantidade"`
+    CustoUnitario decimal.Decimal `gorm:"type:numeric(18,6);default:0" json:"custoUnitario"`
+    Lotes         []LoteBaixa     `gorm:"foreignKey:ItemID" json:"lotes,omitempty"`
+    Series        []string        `gorm:"type:jsonb;serializer:json" json:"series,omitempty"`
+}
+
+// LoteBaixa registra quanto de cada lote saiu em um item de baixa
+type LoteBaixa struct {
+    ItemID     uuid.UUID       `gorm:"type:uuid;primaryKey" json:"-"`
+    LoteID     uuid.UUID       `gorm:"type:uuid;primaryKey" json:"loteId"`
+    TenantID   string          `gorm:"type:varchar(14);not null;default:'';index" json:"-"`
+    Numero     string          `gorm:"not null" json:"numero"`
+    Validade   time.Time       `gorm:"type:date" json:"validade"`
+    Quantidade decimal.Decimal `gorm:"type:numeric(18,4);not null" json:"quantidade"`
+}
+
+// LotesBaixados converte as alocações FEFO de um item em lotes da baixa
+func LotesBaixados(alocacoes []ReservaLote) []LoteBaixa {
+    if len(alocacoes) == 0 {
+        return nil
+    }
+    lotes := make([]LoteBaixa, len(alocacoes))
+    for i, a := range alocacoes {
+        lotes[i] = LoteBaixa{LoteID: a.LoteID, Numero: a.Numero, Validade: a.Validade, Quantidade: a.Quantidade}
+    }
+    return lotes
+}
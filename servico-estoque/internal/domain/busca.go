@@ -0,0 +1,20 @@
+// internal/domain/busca.go
+package domain
+
+// FiltroBusca pagina a busca textual de produtos
+type FiltroBusca struct {
+    Termo string
+    Page  int
+    Size  int
+}
+
+// ResultadoBusca é um produto encontrado pela busca. Exato indica que o
+// termo é o código ou o GTIN do produto; Relevancia ordena os demais e
+// Destaque traz a descrição escapada para HTML, com os trechos encontrados
+// entre <mark></mark>.
+type ResultadoBusca struct {
+    Produto
+    Exato      bool    `json:"exato"`
+    Relevancia float64 `json:"relevancia"`
+    Destaque   string  `json:"destaque"`
+}
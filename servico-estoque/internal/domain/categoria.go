@@ -0,0 +1,95 @@
+// internal/domain/categoria.go
+package domain
+
+import (
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+)
+
+// Categoria agrupa produtos em uma árvore: famílias, subfamílias etc.
+// Categorias sem PaiID são raízes.
+type Categoria struct {
+    ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+    TenantID  string     `gorm:"type:varchar(14);not null;default:'';uniqueIndex:idx_categorias_tenant_codigo,priority:1" json:"-"`
+    Codigo    string     `gorm:"not null;uniqueIndex:idx_categorias_tenant_codigo,priority:2" json:"codigo"`
+    Nome      string     `gorm:"not null" json:"nome"`
+    PaiID     *uuid.UUID `gorm:"type:uuid;index" json:"paiId,omitempty"`
+    CreatedAt time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+    UpdatedAt time.Time  `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+// TableName evita que o GORM trate "categoria" como plural
+func (Categoria) TableName() string {
+    return "categorias"
+}
+
+// TotaisCategoria soma os produtos e o estoque ligados a uma categoria
+type TotaisCategoria struct {
+    CategoriaID uuid.UUID       `json:"-"`
+    Produtos    int64           `json:"produtos"`
+    Saldo       decimal.Decimal `json:"saldo"`
+    Reservado   decimal.Decimal `json:"reservado"`
+    Disponivel  decimal.Decimal `json:"disponivel"`
+}
+
+func (t *TotaisCategoria) somar(o TotaisCategoria) {
+    t.Produtos += o.Produtos
+    t.Saldo = t.Saldo.Add(o.Saldo)
+    t.Reservado = t.Reservado.Add(o.Reservado)
+    t.Disponivel = t.Disponivel.Add(o.Disponivel)
+}
+
+// NoCategoria é uma categoria na árvore do catálogo. Os totais incluem os
+// produtos da própria categoria e de todas as descendentes.
+type NoCategoria struct {
+    Categoria
+    TotaisCategoria
+    Filhas []*NoCategoria `json:"filhas"`
+}
+
+// MontarArvore organiza as categorias em árvore, mantendo em cada nível a
+// ordem recebida, e acumula os totais diretos de cada categoria nas
+// ancestrais. Categorias cujo pai não está na lista viram raízes.
+func MontarArvore(categorias []Categoria, totais []TotaisCategoria) []*NoCategoria {
+    nos := make(map[uuid.UUID]*NoCategoria, len(categorias))
+    for _, c := range categorias {
+        nos[c.ID] = &NoCategoria{Categoria: c, Filhas: []*NoCategoria{}}
+    }
+    for _, t := range totais {
+        if n, ok := nos[t.CategoriaID]; ok {
+            n.TotaisCategoria.somar(t)
+        }
+    }
+
+    raizes := []*NoCategoria{}
+    for _, c := range categorias {
+        n := nos[c.ID]
+        if pai, ok := paiDe(nos, c); ok {
+            pai.Filhas = append(pai.Filhas, n)
+        } else {
+            raizes = append(raizes, n)
+        }
+    }
+    for _, r := range raizes {
+        acumular(r)
+    }
+    return raizes
+}
+
+func paiDe(nos map[uuid.UUID]*NoCategoria, c Categoria) (*NoCategoria, bool) {
+    if c.PaiID == nil {
+        return nil, false
+    }
+    pai, ok := nos[*c.PaiID]
+    return pai, ok
+}
+
+// acumular soma os totais das filhas no nó, da folha para a raiz
+func acumular(n *NoCategoria) TotaisCategoria {
+    for _, f := range n.Filhas {
+        n.TotaisCategoria.somar(acumular(f))
+    }
+    return n.TotaisCategoria
+}
@@ -0,0 +1,110 @@
+// internal/domain/chave_api.go
+package domain
+
+import (
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
+    "slices"
+    "strings"
+    "time"
+
+    "github.com/google/uuid"
+)
+
+// Escopos que uma chave de API pode receber
+const (
+    EscopoProdutosLeitura = "produtos:read"
+    EscopoReservasEscrita = "reservas:write"
+    EscopoEstoqueBaixar   = "estoque:baixar"
+)
+
+// EscoposChaveAPI são todos os escopos aceitos, na ordem da documentação
+var EscoposChaveAPI = []string{EscopoProdutosLeitura, EscopoReservasEscrita, EscopoEstoqueBaixar}
+
+// prefixoChaveAPI identifica o formato das chaves: "ek_<prefixo>_<segredo>"
+const prefixoChaveAPI = "ek_"
+
+// ChaveAPI é uma credencial de máquina (integrações como o faturamento).
+// Só o hash SHA-256 da chave é guardado; o Prefixo, visível, identifica a
+// chave em listagens e logs sem revelar o segredo.
+type ChaveAPI struct {
+    ID            uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+    TenantID      string     `gorm:"type:varchar(14);not null;default:'';index" json:"tenant"`
+    Nome          string     `gorm:"not null" json:"nome"`
+    Prefixo       string     `gorm:"uniqueIndex;not null" json:"prefixo"`
+    Hash          string     `gorm:"uniqueIndex;not null" json:"-"`
+    Escopos       []string   `gorm:"type:jsonb;serializer:json;not null" json:"escopos"`
+    ExpiraEm      *time.Time `json:"expiraEm,omitempty"`
+    UltimoUsoEm   *time.Time `json:"ultimoUsoEm,omitempty"`
+    RevogadaEm    *time.Time `json:"revogadaEm,omitempty"`
+    RotacionadaEm *time.Time `json:"rotacionadaEm,omitempty"`
+    CreatedAt     time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+    UpdatedAt     time.Time  `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+// TableName segue o plural em português das demais tabelas
+func (ChaveAPI) TableName() string {
+    return "chaves_api"
+}
+
+// Ativa indica se a chave pode ser usada no instante informado
+func (c *ChaveAPI) Ativa(agora time.Time) bool {
+    return c.RevogadaEm == nil && (c.ExpiraEm == nil || agora.Before(*c.ExpiraEm))
+}
+
+// ChaveAPICriada é a resposta de criação e rotação: a única vez em que a
+// chave completa aparece
+type ChaveAPICriada struct {
+    *ChaveAPI
+    Chave string `json:"chave"`
+}
+
+// NovaChaveAPI gera uma chave aleatória e retorna o texto completo, o
+// prefixo e o hash a guardar
+func NovaChaveAPI() (chave, prefixo, hash string, err error) {
+    id := make([]byte, 6)
+    segredo := make([]byte, 32)
+    if _, err = rand.Read(id); err != nil {
+        return "", "", "", err
+    }
+    if _, err = rand.Read(segredo); err != nil {
+        return "", "", "", err
+    }
+    prefixo = hex.EncodeToString(id)
+    chave = prefixoChaveAPI + prefixo + "_" + base64.RawURLEncoding.EncodeToString(segredo)
+    return chave, prefixo, HashChaveAPI(chave), nil
+}
+
+// HashChaveAPI é o hash guardado para a chave. As chaves têm 256 bits
+// aleatórios, então um hash rápido basta (não há senha para adivinhar).
+func HashChaveAPI(chave string) string {
+    soma := sha256.Sum256([]byte(chave))
+    return hex.EncodeToString(soma[:])
+}
+
+// FormatoChaveAPIValido descarta textos que não podem ser chaves antes de
+// qualquer consulta
+func FormatoChaveAPIValido(chave string) bool {
+    return strings.HasPrefix(chave, prefixoChaveAPI) && len(chave) <= 128
+}
+
+// ValidarEscopos verifica se todos os escopos são conhecidos e remove os
+// repetidos
+func ValidarEscopos(escopos []string) ([]string, error) {
+    var validos []string
+    for _, e := range escopos {
+        e = strings.TrimSpace(e)
+        if !slices.Contains(EscoposChaveAPI, e) {
+            return nil, ErrEscopoInvalido
+        }
+        if !slices.Contains(validos, e) {
+            validos = append(validos, e)
+        }
+    }
+    if len(validos) == 0 {
+        return nil, ErrEscopoInvalido
+    }
+    return validos, nil
+}
@@ -0,0 +1,120 @@
+// internal/domain/deposito.go
+package domain
+
+import (
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+)
+
+// Deposito é um local físico de armazenagem (depósito, loja, etc.)
+type Deposito struct {
+    ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+    TenantID  string    `gorm:"type:varchar(14);not null;default:'';uniqueIndex:idx_depositos_tenant_codigo,priority:1" json:"-"`
+    Codigo    string    `gorm:"not null;uniqueIndex:idx_depositos_tenant_codigo,priority:2" json:"codigo"`
+    Nome      string    `gorm:"not null" json:"nome"`
+    Padrao    bool      `gorm:"default:false" json:"padrao"`
+    CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
+    UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+// SaldoDeposito é o saldo de um produto em um depósito. Produto.Saldo e
+// Produto.Reservado são sempre a soma destas linhas.
+type SaldoDeposito struct {
+    ProdutoID  uuid.UUID       `gorm:"type:uuid;primaryKey" json:"-"`
+    DepositoID uuid.UUID       `gorm:"type:uuid;primaryKey" json:"depositoId"`
+    TenantID   string          `gorm:"type:varchar(14);not null;default:'';index" json:"-"`
+    Deposito   *Deposito       `gorm:"foreignKey:DepositoID" json:"deposito,omitempty"`
+    Saldo      decimal.Decimal `gorm:"type:numeric(18,4);not null" json:"saldo"`
+    Reservado  decimal.Decimal `gorm:"type:numeric(18,4);default:0" json:"reservado"`
+    UpdatedAt  time.Time       `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+func (s *SaldoDeposito) Disponivel() decimal.Decimal {
+    return s.Saldo.Sub(s.Reservado)
+}
+
+// OpcaoDeposito é um depósito candidato a atender uma quantidade
+type OpcaoDeposito struct {
+    DepositoID uuid.UUID
+    Codigo     string
+    Padrao     bool
+    Disponivel decimal.Decimal
+}
+
+// EstrategiaAlocacao escolhe de qual depósito sai uma quantidade quando a
+// requisição não informa o depósito
+type EstrategiaAlocacao interface {
+    Escolher(opcoes []OpcaoDeposito, quantidade decimal.Decimal) (uuid.UUID, bool)
+}
+
+// Estratégias de alocação disponíveis
+const (
+    AlocacaoPadrao     = "padrao"
+    AlocacaoMaiorSaldo = "maior_saldo"
+    AlocacaoPrioridade = "prioridade"
+)
+
+// NovaEstrategiaAlocacao cria a estratégia pelo nome. prioridade lista os
+// códigos de depósito em ordem de preferência e só é usada por "prioridade".
+func NovaEstrategiaAlocacao(nome string, prioridade []string) (EstrategiaAlocacao, error) {
+    switch nome {
+    case AlocacaoPadrao:
+        return alocacaoPadrao{}, nil
+    case AlocacaoMaiorSaldo:
+        return alocacaoMaiorSaldo{}, nil
+    case AlocacaoPrioridade:
+        if len(prioridade) == 0 {
+            return nil, fmt.Errorf("estratégia %q exige a lista de prioridade de depósitos", nome)
+        }
+        return alocacaoPrioridade{codigos: prioridade}, nil
+    default:
+        return nil, fmt.Errorf("estratégia de alocação desconhecida: %q", nome)
+    }
+}
+
+// alocacaoPadrao usa sempre o depósito padrão
+type alocacaoPadrao struct{}
+
+func (alocacaoPadrao) Escolher(opcoes []OpcaoDeposito, quantidade decimal.Decimal) (uuid.UUID, bool) {
+    for _, o := range opcoes {
+        if o.Padrao {
+            return o.DepositoID, o.Disponivel.GreaterThanOrEqual(quantidade)
+        }
+    }
+    return uuid.Nil, false
+}
+
+// alocacaoMaiorSaldo usa o depósito com mais estoque disponível
+type alocacaoMaiorSaldo struct{}
+
+func (alocacaoMaiorSaldo) Escolher(opcoes []OpcaoDeposito, quantidade decimal.Decimal) (uuid.UUID, bool) {
+    var melhor *OpcaoDeposito
+    for i := range opcoes {
+        if melhor == nil || opcoes[i].Disponivel.GreaterThan(melhor.Disponivel) {
+            melhor = &opcoes[i]
+        }
+    }
+    if melhor == nil {
+        return uuid.Nil, false
+    }
+    return melhor.DepositoID, melhor.Disponivel.GreaterThanOrEqual(quantidade)
+}
+
+// alocacaoPrioridade usa o primeiro depósito da lista que atende a quantidade
+type alocacaoPrioridade struct {
+    codigos []string
+}
+
+func (a alocacaoPrioridade) Escolher(opcoes []OpcaoDeposito, quantidade decimal.Decimal) (uuid.UUID, bool) {
+    for _, codigo := range a.codigos {
+        for _, o := range opcoes {
+            if o.Codigo == codigo && o.Disponivel.GreaterThanOrEqual(quantidade) {
+                return o.DepositoID, true
+            }
+        }
+    }
+    return uuid.Nil, false
+}
@@ -0,0 +1,73 @@
+// internal/domain/deposito_test.go
+package domain
+
+import (
+    "testing"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+)
+
+func TestNovaEstrategiaAlocacao(t *testing.T) {
+    casos := []struct {
+        nome       string
+        prioridade []string
+        valida     bool
+    }{
+        {AlocacaoPadrao, nil, true},
+        {AlocacaoMaiorSaldo, nil, true},
+        {AlocacaoPrioridade, []string{"CENTRAL"}, true},
+        {AlocacaoPrioridade, nil, false},
+        {"fifo", nil, false},
+        {"", nil, false},
+    }
+    for _, c := range casos {
+        e, err := NovaEstrategiaAlocacao(c.nome, c.prioridade)
+        if (err == nil) != c.valida || (e != nil) != c.valida {
+            t.Errorf("NovaEstrategiaAlocacao(%q, %v) = %v, %v", c.nome, c.prioridade, e, err)
+        }
+    }
+}
+
+func TestEstrategiasAlocacao(t *testing.T) {
+    central, loja, filial := uuid.New(), uuid.New(), uuid.New()
+    opcoes := []OpcaoDeposito{
+        {DepositoID: central, Codigo: "CENTRAL", Padrao: true, Disponivel: decimal.NewFromInt(5)},
+        {DepositoID: loja, Codigo: "LOJA", Disponivel: decimal.NewFromInt(20)},
+        {DepositoID: filial, Codigo: "FILIAL", Disponivel: decimal.NewFromInt(8)},
+    }
+    semPadrao := opcoes[1:]
+
+    casos := []struct {
+        nome       string
+        estrategia string
+        prioridade []string
+        opcoes     []OpcaoDeposito
+        quantidade int64
+        deposito   uuid.UUID
+        atende     bool
+    }{
+        {"padrão atende", AlocacaoPadrao, nil, opcoes, 5, central, true},
+        {"padrão sem saldo", AlocacaoPadrao, nil, opcoes, 6, central, false},
+        {"sem depósito padrão", AlocacaoPadrao, nil, semPadrao, 1, uuid.Nil, false},
+        {"maior saldo", AlocacaoMaiorSaldo, nil, opcoes, 20, loja, true},
+        {"maior saldo insuficiente", AlocacaoMaiorSaldo, nil, opcoes, 21, loja, false},
+        {"maior saldo sem opções", AlocacaoMaiorSaldo, nil, nil, 1, uuid.Nil, false},
+        {"primeiro da prioridade", AlocacaoPrioridade, []string{"FILIAL", "LOJA"}, opcoes, 8, filial, true},
+        {"pula o que não atende", AlocacaoPrioridade, []string{"FILIAL", "LOJA"}, opcoes, 9, loja, true},
+        {"ignora depósito fora da lista", AlocacaoPrioridade, []string{"CENTRAL", "FILIAL"}, opcoes, 10, uuid.Nil, false},
+        {"código inexistente", AlocacaoPrioridade, []string{"OUTRO"}, opcoes, 1, uuid.Nil, false},
+    }
+    for _, c := range casos {
+        t.Run(c.nome, func(t *testing.T) {
+            e, err := NovaEstrategiaAlocacao(c.estrategia, c.prioridade)
+            if err != nil {
+                t.Fatal(err)
+            }
+            deposito, atende := e.Escolher(c.opcoes, decimal.NewFromInt(c.quantidade))
+            if deposito != c.deposito || atende != c.atende {
+                t.Errorf("Escolher = %s, %v; esperado %s, %v", deposito, atende, c.deposito, c.atende)
+            }
+        })
+    }
+}
@@ -1,7 +1,13 @@
 // internal/domain/errors.go
 package domain
 
-import "errors"
+import (
+    "errors"
+    "fmt"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+)
 
 var (
     ErrProdutoNaoEncontrado   = errors.New("produto não encontrado")
@@ -15,4 +21,58 @@ var (
     ErrQuantidadeInvalida     = errors.New("quantidade deve ser maior que zero")
     ErrDadosInvalidos         = errors.New("dados inválidos")
     ErrOperacaoNaoPermitida   = errors.New("operação não permitida")
-)
\ No newline at end of file
+    ErrOperacaoEmAndamento    = errors.New("operação em andamento para este recurso")
+    ErrDepositoNaoEncontrado  = errors.New("depósito não encontrado")
+    ErrLoteVencido            = errors.New("estoque disponível apenas em lotes vencidos")
+    ErrLoteObrigatorio        = errors.New("produto controlado por lote só movimenta saldo por lote")
+    ErrProdutoSemLote         = errors.New("produto não é controlado por lote")
+    ErrSerieObrigatoria       = errors.New("produto controlado por número de série só movimenta saldo por série")
+    ErrProdutoSemSerie        = errors.New("produto não é controlado por número de série")
+    ErrSerieNaoEncontrada     = errors.New("número de série não encontrado")
+    ErrSerieIndisponivel      = errors.New("número de série indisponível")
+    ErrSerieDuplicada         = errors.New("número de série já cadastrado")
+    ErrUnidadeInvalida        = errors.New("unidade de medida inválida para o produto")
+    ErrNCMInvalido            = errors.New("NCM deve ter 8 dígitos")
+    ErrGTINInvalido           = errors.New("GTIN/EAN inválido")
+    ErrDadosFiscaisInvalidos  = errors.New("dados fiscais inválidos")
+    ErrCategoriaNaoEncontrada = errors.New("categoria não encontrada")
+    ErrCursorInvalido         = errors.New("cursor de paginação inválido")
+    ErrPlanilhaInvalida       = errors.New("planilha inválida")
+    ErrVersaoDivergente       = errors.New("produto alterado por outra operação; recarregue e tente novamente")
+    ErrBaixaNaoEncontrada     = errors.New("baixa não encontrada")
+    ErrIdempotenciaDivergente = errors.New("chave de idempotência já usada com outra requisição")
+    ErrCorpoMuitoGrande       = errors.New("corpo da requisição excede o tamanho máximo")
+    ErrNaoAutenticado         = errors.New("credencial ausente ou inválida")
+    ErrChaveAPINaoEncontrada  = errors.New("chave de API não encontrada")
+    ErrEscopoInvalido         = errors.New("escopo de chave de API inválido")
+    ErrChaveAPIRevogada       = errors.New("chave de API revogada")
+    ErrTenantInvalido         = errors.New("CNPJ da empresa inválido")
+    ErrTenantObrigatorio      = errors.New("informe a empresa (CNPJ) no cabeçalho X-Tenant-ID")
+    ErrTenantAusente          = errors.New("operação sem empresa definida")
+    ErrLimiteExcedido         = errors.New("limite de requisições excedido; tente novamente mais tarde")
+)
+
+// ItemIndisponivel descreve um item que impediu a reserva
+type ItemIndisponivel struct {
+    ProdutoID  uuid.UUID       `json:"produtoId"`
+    DepositoID *uuid.UUID      `json:"depositoId,omitempty"`
+    Solicitado decimal.Decimal `json:"solicitado"`
+    Disponivel decimal.Decimal `json:"disponivel"`
+    Faltante   decimal.Decimal `json:"faltante"`
+    Motivo     string          `json:"motivo"`
+    Series     []string        `json:"series,omitempty"`
+}
+
+// ReservaItensError informa, item a item, por que uma reserva com vários
+// produtos foi recusada. Compara como ErrEstoqueInsuficiente via errors.Is.
+type ReservaItensError struct {
+    Itens []ItemIndisponivel
+}
+
+func (e *ReservaItensError) Error() string {
+    return fmt.Sprintf("%s: %d item(ns) indisponível(is)", ErrEstoqueInsuficiente, len(e.Itens))
+}
+
+func (e *ReservaItensError) Unwrap() error {
+    return ErrEstoqueInsuficiente
+}
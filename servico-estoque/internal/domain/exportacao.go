@@ -0,0 +1,132 @@
+// internal/domain/exportacao.go
+package domain
+
+import (
+    "strconv"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+)
+
+// Formatos aceitos na exportação
+const (
+    ExportacaoCSV   = "csv"
+    ExportacaoJSONL = "jsonl"
+)
+
+// FormatoExportacaoValido indica se o formato é aceito na exportação
+func FormatoExportacaoValido(formato string) bool {
+    return formato == ExportacaoCSV || formato == ExportacaoJSONL
+}
+
+// ProdutoExportado é uma linha da exportação de produtos: o cadastro sem as
+// coleções relacionadas e com o disponível calculado. CustoMedio e
+// ValorEstoque só são preenchidos quando a exportação inclui a valorização.
+type ProdutoExportado struct {
+    ID            uuid.UUID        `json:"id"`
+    Codigo        string           `json:"codigo"`
+    Descricao     string           `json:"descricao"`
+    CategoriaID   *uuid.UUID       `json:"categoriaId,omitempty"`
+    Unidade       string           `json:"unidade"`
+    Saldo         decimal.Decimal  `json:"saldo"`
+    Reservado     decimal.Decimal  `json:"reservado"`
+    Disponivel    decimal.Decimal  `json:"disponivel"`
+    ControlaLote  bool             `json:"controlaLote"`
+    ControlaSerie bool             `json:"controlaSerie"`
+    Fiscal        DadosFiscais     `json:"fiscal"`
+    CustoMedio    *decimal.Decimal `json:"custoMedio,omitempty"`
+    ValorEstoque  *decimal.Decimal `json:"valorEstoque,omitempty"`
+    UpdatedAt     time.Time        `json:"updatedAt"`
+}
+
+// NovoProdutoExportado monta a linha de exportação de p. Com valorizar, o
+// valor do estoque é o saldo pelo custo médio.
+func NovoProdutoExportado(p Produto, valorizar bool) ProdutoExportado {
+    e := ProdutoExportado{
+        ID:            p.ID,
+        Codigo:        p.Codigo,
+        Descricao:     p.Descricao,
+        CategoriaID:   p.CategoriaID,
+        Unidade:       p.Unidade,
+        Saldo:         p.Saldo,
+        Reservado:     p.Reservado,
+        Disponivel:    p.Saldo.Sub(p.Reservado),
+        ControlaLote:  p.ControlaLote,
+        ControlaSerie: p.ControlaSerie,
+        Fiscal:        p.Fiscal,
+        UpdatedAt:     p.UpdatedAt,
+    }
+    if valorizar {
+        custo := p.CustoMedio
+        valor := p.Saldo.Mul(p.CustoMedio).Round(CasasValor)
+        e.CustoMedio = &custo
+        e.ValorEstoque = &valor
+    }
+    return e
+}
+
+// ColunasProdutoExportado são os nomes das colunas do CSV de produtos, na
+// ordem de Valores
+func ColunasProdutoExportado(valorizar bool) []string {
+    colunas := []string{
+        "id", "codigo", "descricao", "categoriaId", "unidade",
+        "saldo", "reservado", "disponivel", "controlaLote", "controlaSerie",
+        "ncm", "cest", "origem", "cfop", "unidadeComercial", "unidadeTributavel", "gtin",
+        "updatedAt",
+    }
+    if valorizar {
+        colunas = append(colunas, "custoMedio", "valorEstoque")
+    }
+    return colunas
+}
+
+// Valores retorna os campos da linha no formato do CSV
+func (e ProdutoExportado) Valores() []string {
+    valores := []string{
+        e.ID.String(), e.Codigo, e.Descricao, textoUUID(e.CategoriaID), e.Unidade,
+        e.Saldo.String(), e.Reservado.String(), e.Disponivel.String(),
+        strconv.FormatBool(e.ControlaLote), strconv.FormatBool(e.ControlaSerie),
+        e.Fiscal.NCM, e.Fiscal.CEST, strconv.Itoa(e.Fiscal.Origem), e.Fiscal.CFOP,
+        e.Fiscal.UnidadeComercial, e.Fiscal.UnidadeTributavel, e.Fiscal.GTIN,
+        e.UpdatedAt.Format(time.RFC3339Nano),
+    }
+    if e.ValorEstoque != nil {
+        valores = append(valores, e.CustoMedio.String(), e.ValorEstoque.String())
+    }
+    return valores
+}
+
+// MovimentoExportado é uma linha da exportação do kardex, com o código do
+// produto para dispensar a junção no destino
+type MovimentoExportado struct {
+    MovimentoEstoque
+    ProdutoCodigo string `json:"produtoCodigo"`
+}
+
+// ColunasMovimentoExportado são os nomes das colunas do CSV de movimentos,
+// na ordem de Valores
+func ColunasMovimentoExportado() []string {
+    return []string{
+        "id", "produtoId", "produtoCodigo", "depositoId", "tipo", "quantidade",
+        "saldoAnterior", "saldoPosterior", "custoUnitario", "custoTotal", "custoMedio",
+        "notaFiscalId", "motivo", "createdAt",
+    }
+}
+
+// Valores retorna os campos da linha no formato do CSV
+func (m MovimentoExportado) Valores() []string {
+    return []string{
+        m.ID.String(), m.ProdutoID.String(), m.ProdutoCodigo, textoUUID(m.DepositoID), m.Tipo,
+        m.Quantidade.String(), m.SaldoAnterior.String(), m.SaldoPosterior.String(),
+        m.CustoUnitario.String(), m.CustoTotal.String(), m.CustoMedio.String(),
+        textoUUID(m.NotaFiscalID), m.Motivo, m.CreatedAt.Format(time.RFC3339Nano),
+    }
+}
+
+func textoUUID(id *uuid.UUID) string {
+    if id == nil {
+        return ""
+    }
+    return id.String()
+}
@@ -0,0 +1,102 @@
+// internal/domain/fiscal.go
+package domain
+
+import (
+    "strings"
+)
+
+// SemGTIN é o valor aceito pela NF-e para produtos sem código de barras
+const SemGTIN = "SEM GTIN"
+
+// DadosFiscais reúne os atributos do produto usados na emissão da NF-e.
+// NCM e CEST são guardados só com dígitos. Origem segue a tabela de origem
+// da mercadoria (0 a 8) e CFOP é o CFOP padrão de saída do item. As unidades
+// comercial e tributável assumem a unidade base do produto quando omitidas.
+type DadosFiscais struct {
+    NCM               string `gorm:"size:8" json:"ncm,omitempty"`
+    CEST              string `gorm:"size:7" json:"cest,omitempty"`
+    Origem            int    `gorm:"default:0" json:"origem"`
+    CFOP              string `gorm:"size:4" json:"cfop,omitempty"`
+    UnidadeComercial  string `gorm:"size:6" json:"unidadeComercial,omitempty"`
+    UnidadeTributavel string `gorm:"size:6" json:"unidadeTributavel,omitempty"`
+    GTIN              string `gorm:"size:14;index" json:"gtin,omitempty"`
+}
+
+// Normalizar remove a pontuação de NCM, CEST e CFOP, padroniza as unidades
+// e preenche as unidades omitidas com a unidade base
+func (f *DadosFiscais) Normalizar(unidadeBase string) {
+    f.NCM = semPontuacao(f.NCM)
+    f.CEST = semPontuacao(f.CEST)
+    f.CFOP = semPontuacao(f.CFOP)
+    f.GTIN = strings.ToUpper(strings.TrimSpace(f.GTIN))
+    f.UnidadeComercial = strings.ToUpper(strings.TrimSpace(f.UnidadeComercial))
+    f.UnidadeTributavel = strings.ToUpper(strings.TrimSpace(f.UnidadeTributavel))
+    if f.UnidadeComercial == "" {
+        f.UnidadeComercial = unidadeBase
+    }
+    if f.UnidadeTributavel == "" {
+        f.UnidadeTributavel = unidadeBase
+    }
+}
+
+// Validar confere os formatos exigidos pela NF-e. Campos vazios são aceitos,
+// exceto as unidades, preenchidas por Normalizar.
+func (f DadosFiscais) Validar() error {
+    if f.NCM != "" && !digitos(f.NCM, 8) {
+        return ErrNCMInvalido
+    }
+    if f.CEST != "" && !digitos(f.CEST, 7) {
+        return ErrDadosFiscaisInvalidos
+    }
+    if f.Origem < 0 || f.Origem > 8 {
+        return ErrDadosFiscaisInvalidos
+    }
+    if f.CFOP != "" && (!digitos(f.CFOP, 4) || f.CFOP[0] == '0' || f.CFOP[0] == '4' || f.CFOP[0] > '7') {
+        return ErrDadosFiscaisInvalidos
+    }
+    for _, u := range []string{f.UnidadeComercial, f.UnidadeTributavel} {
+        if u == "" || len(u) > 6 {
+            return ErrDadosFiscaisInvalidos
+        }
+    }
+    if f.GTIN != "" && f.GTIN != SemGTIN && !GTINValido(f.GTIN) {
+        return ErrGTINInvalido
+    }
+    return nil
+}
+
+// GTINValido confere tamanho (GTIN-8, 12, 13 ou 14) e dígito verificador
+func GTINValido(gtin string) bool {
+    if !digitos(gtin, 8) && !digitos(gtin, 12) && !digitos(gtin, 13) && !digitos(gtin, 14) {
+        return false
+    }
+
+    // Da direita para a esquerda, sem o verificador, os pesos alternam 3 e 1
+    soma := 0
+    for i := len(gtin) - 2; i >= 0; i-- {
+        d := int(gtin[i] - '0')
+        if (len(gtin)-2-i)%2 == 0 {
+            d *= 3
+        }
+        soma += d
+    }
+    return (10-soma%10)%10 == int(gtin[len(gtin)-1]-'0')
+}
+
+// semPontuacao remove pontos, hífens e espaços, como em "8471.30.12"
+func semPontuacao(v string) string {
+    return strings.NewReplacer(".", "", "-", "", " ", "").Replace(v)
+}
+
+// digitos indica se v tem exatamente n dígitos
+func digitos(v string, n int) bool {
+    if len(v) != n {
+        return false
+    }
+    for _, r := range v {
+        if r < '0' || r > '9' {
+            return false
+        }
+    }
+    return true
+}
@@ -0,0 +1,100 @@
+// internal/domain/fiscal_test.go
+package domain
+
+import (
+    "errors"
+    "testing"
+)
+
+func TestGTINValido(t *testing.T) {
+    casos := []struct {
+        gtin   string
+        valido bool
+    }{
+        {"96385074", true},
+        {"036000291452", true},
+        {"4006381333931", true},
+        {"00012345600012", true},
+        {"4006381333932", false},
+        {"036000291453", false},
+        {"1234567", false},
+        {"400638133393", false},
+        {"400638133393A", false},
+        {"", false},
+        {SemGTIN, false},
+    }
+    for _, c := range casos {
+        if got := GTINValido(c.gtin); got != c.valido {
+            t.Errorf("GTINValido(%q) = %v, esperado %v", c.gtin, got, c.valido)
+        }
+    }
+}
+
+func TestDadosFiscaisNormalizar(t *testing.T) {
+    f := DadosFiscais{
+        NCM:              "8471.30.12",
+        CEST:             "21.064.00",
+        CFOP:             "5.102",
+        GTIN:             " sem gtin ",
+        UnidadeComercial: " cx ",
+    }
+    f.Normalizar("UN")
+
+    esperado := DadosFiscais{
+        NCM:               "84713012",
+        CEST:              "2106400",
+        CFOP:              "5102",
+        GTIN:              SemGTIN,
+        UnidadeComercial:  "CX",
+        UnidadeTributavel: "UN",
+    }
+    if f != esperado {
+        t.Errorf("Normalizar = %+v, esperado %+v", f, esperado)
+    }
+}
+
+func TestDadosFiscaisValidar(t *testing.T) {
+    validos := func() DadosFiscais {
+        return DadosFiscais{
+            NCM:               "84713012",
+            CEST:              "2106400",
+            CFOP:              "5102",
+            GTIN:              "4006381333931",
+            UnidadeComercial:  "UN",
+            UnidadeTributavel: "UN",
+        }
+    }
+
+    casos := []struct {
+        nome    string
+        alterar func(*DadosFiscais)
+        erro    error
+    }{
+        {"completos", func(*DadosFiscais) {}, nil},
+        {"campos opcionais vazios", func(f *DadosFiscais) { f.NCM, f.CEST, f.CFOP, f.GTIN = "", "", "", "" }, nil},
+        {"sem gtin", func(f *DadosFiscais) { f.GTIN = SemGTIN }, nil},
+        {"origem 8", func(f *DadosFiscais) { f.Origem = 8 }, nil},
+        {"cfop de entrada", func(f *DadosFiscais) { f.CFOP = "1102" }, nil},
+        {"ncm curto", func(f *DadosFiscais) { f.NCM = "8471301" }, ErrNCMInvalido},
+        {"ncm com letra", func(f *DadosFiscais) { f.NCM = "8471301A" }, ErrNCMInvalido},
+        {"cest curto", func(f *DadosFiscais) { f.CEST = "210640" }, ErrDadosFiscaisInvalidos},
+        {"origem negativa", func(f *DadosFiscais) { f.Origem = -1 }, ErrDadosFiscaisInvalidos},
+        {"origem 9", func(f *DadosFiscais) { f.Origem = 9 }, ErrDadosFiscaisInvalidos},
+        {"cfop iniciado em 0", func(f *DadosFiscais) { f.CFOP = "0102" }, ErrDadosFiscaisInvalidos},
+        {"cfop iniciado em 4", func(f *DadosFiscais) { f.CFOP = "4102" }, ErrDadosFiscaisInvalidos},
+        {"cfop iniciado em 8", func(f *DadosFiscais) { f.CFOP = "8102" }, ErrDadosFiscaisInvalidos},
+        {"cfop com 3 dígitos", func(f *DadosFiscais) { f.CFOP = "510" }, ErrDadosFiscaisInvalidos},
+        {"unidade comercial vazia", func(f *DadosFiscais) { f.UnidadeComercial = "" }, ErrDadosFiscaisInvalidos},
+        {"unidade tributável longa", func(f *DadosFiscais) { f.UnidadeTributavel = "CAIXA12" }, ErrDadosFiscaisInvalidos},
+        {"gtin com dígito errado", func(f *DadosFiscais) { f.GTIN = "4006381333932" }, ErrGTINInvalido},
+    }
+    for _, c := range casos {
+        t.Run(c.nome, func(t *testing.T) {
+            f := validos()
+            c.alterar(&f)
+            if err := f.Validar(); !errors.Is(err, c.erro) {
+                t.Errorf("Validar() = %v, esperado %v", err, c.erro)
+            }
+        })
+    }
+}
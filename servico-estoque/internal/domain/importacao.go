@@ -0,0 +1,50 @@
+// internal/domain/importacao.go
+package domain
+
+// Ações de cada linha no relatório de importação
+const (
+    ImportacaoCriar     = "CRIAR"
+    ImportacaoAtualizar = "ATUALIZAR"
+    ImportacaoErro      = "ERRO"
+)
+
+// OpcoesImportacao controla a importação em massa de produtos. Com Upsert,
+// códigos já cadastrados são atualizados em vez de recusados. Com DryRun,
+// todas as linhas são validadas e nada é gravado.
+type OpcoesImportacao struct {
+    DryRun bool
+    Upsert bool
+}
+
+// ResultadoImportacao resume a importação e traz o resultado de cada linha.
+// Em dry-run, Criados e Atualizados contam o que seria gravado.
+type ResultadoImportacao struct {
+    DryRun      bool              `json:"dryRun"`
+    Upsert      bool              `json:"upsert"`
+    Total       int               `json:"total"`
+    Criados     int               `json:"criados"`
+    Atualizados int               `json:"atualizados"`
+    Erros       int               `json:"erros"`
+    Linhas      []LinhaImportacao `json:"linhas"`
+}
+
+// LinhaImportacao é o resultado de uma linha do arquivo
+type LinhaImportacao struct {
+    Linha  int    `json:"linha"`
+    Codigo string `json:"codigo,omitempty"`
+    Acao   string `json:"acao"`
+    Erro   string `json:"erro,omitempty"`
+}
+
+func (r *ResultadoImportacao) Registrar(l LinhaImportacao) {
+    r.Total++
+    switch l.Acao {
+    case ImportacaoCriar:
+        r.Criados++
+    case ImportacaoAtualizar:
+        r.Atualizados++
+    case ImportacaoErro:
+        r.Erros++
+    }
+    r.Linhas = append(r.Linhas, l)
+}
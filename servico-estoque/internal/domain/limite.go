@@ -0,0 +1,18 @@
+// internal/domain/limite.go
+package domain
+
+// Grupos de rotas com limites de requisições próprios. São os nomes usados
+// na configuração (limite.<grupo> e nas exceções) e na classificação das
+// rotas pelo middleware de limite.
+const (
+    // GrupoLeitura reúne as consultas
+    GrupoLeitura = "leitura"
+    // GrupoEscrita reúne cadastros e demais alterações
+    GrupoEscrita = "escrita"
+    // GrupoMovimentacao reúne reservas (criação, confirmação e cancelamento)
+    // e baixas, as rotas mais disputadas pelas integrações
+    GrupoMovimentacao = "movimentacao"
+    // GrupoMassa reúne importações, exportações e relatórios, que percorrem
+    // o cadastro inteiro
+    GrupoMassa = "massa"
+)
@@ -0,0 +1,92 @@
+// internal/domain/listagem.go
+package domain
+
+import (
+    "encoding/base64"
+    "encoding/json"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+)
+
+// Ordenações aceitas na listagem de produtos
+const (
+    OrdemCodigo      = "codigo"
+    OrdemDescricao   = "descricao"
+    OrdemSaldo       = "saldo"
+    OrdemDisponivel  = "disponivel"
+    OrdemAtualizacao = "updatedAt"
+)
+
+// OrdemProdutosValida indica se a ordenação é aceita na listagem
+func OrdemProdutosValida(ordem string) bool {
+    switch ordem {
+    case OrdemCodigo, OrdemDescricao, OrdemSaldo, OrdemDisponivel, OrdemAtualizacao:
+        return true
+    }
+    return false
+}
+
+// FiltroProdutos restringe, ordena e pagina a listagem de produtos.
+// CategoriaID inclui os produtos das categorias descendentes. DisponivelAte
+// seleciona produtos com disponível baixo e SemDisponivel os que não têm
+// nada disponível. Com Cursor, a página começa logo após o último item da
+// página anterior e Page é ignorado.
+type FiltroProdutos struct {
+    CategoriaID     *uuid.UUID
+    DisponivelAte   *decimal.Decimal
+    SemDisponivel   bool
+    AtualizadoDesde *time.Time
+    ComReservas     *bool
+    Ordem           string
+    Decrescente     bool
+    Page            int
+    Size            int
+    Cursor          *CursorProdutos
+}
+
+// CursorProdutos marca a posição do último item entregue: o valor da coluna
+// de ordenação e o ID, que desempata itens com o mesmo valor
+type CursorProdutos struct {
+    Ordem       string    `json:"o"`
+    Decrescente bool      `json:"d,omitempty"`
+    Valor       string    `json:"v"`
+    ID          uuid.UUID `json:"id"`
+}
+
+// NovoCursor gera o cursor opaco que continua a listagem após p
+func NovoCursor(p Produto, f FiltroProdutos) string {
+    c := CursorProdutos{Ordem: f.Ordem, Decrescente: f.Decrescente, ID: p.ID}
+    switch f.Ordem {
+    case OrdemDescricao:
+        c.Valor = p.Descricao
+    case OrdemSaldo:
+        c.Valor = p.Saldo.String()
+    case OrdemDisponivel:
+        c.Valor = p.Saldo.Sub(p.Reservado).String()
+    case OrdemAtualizacao:
+        c.Valor = p.UpdatedAt.Format(time.RFC3339Nano)
+    default:
+        c.Valor = p.Codigo
+    }
+    b, _ := json.Marshal(c)
+    return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// LerCursor decodifica um cursor gerado por NovoCursor e confere se ele
+// pertence à mesma ordenação do filtro
+func LerCursor(v string, f FiltroProdutos) (*CursorProdutos, error) {
+    b, err := base64.RawURLEncoding.DecodeString(v)
+    if err != nil {
+        return nil, ErrCursorInvalido
+    }
+    var c CursorProdutos
+    if err := json.Unmarshal(b, &c); err != nil || c.ID == uuid.Nil {
+        return nil, ErrCursorInvalido
+    }
+    if c.Ordem != f.Ordem || c.Decrescente != f.Decrescente {
+        return nil, ErrCursorInvalido
+    }
+    return &c, nil
+}
@@ -0,0 +1,77 @@
+// internal/domain/lote.go
+package domain
+
+import (
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+)
+
+// Lote é uma partida de um produto controlado por lote, armazenada em um
+// depósito, com sua própria validade e saldo
+type Lote struct {
+    ID         uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+    TenantID   string          `gorm:"type:varchar(14);not null;default:'';index" json:"-"`
+    ProdutoID  uuid.UUID       `gorm:"type:uuid;not null;uniqueIndex:idx_lote_produto_deposito_numero,priority:1" json:"produtoId"`
+    DepositoID uuid.UUID       `gorm:"type:uuid;not null;uniqueIndex:idx_lote_produto_deposito_numero,priority:2" json:"depositoId"`
+    Numero     string          `gorm:"not null;uniqueIndex:idx_lote_produto_deposito_numero,priority:3" json:"numero"`
+    Fabricacao *time.Time      `gorm:"type:date" json:"fabricacao,omitempty"`
+    Validade   time.Time       `gorm:"type:date;not null;index" json:"validade"`
+    Saldo      decimal.Decimal `gorm:"type:numeric(18,4);not null" json:"saldo"`
+    Reservado  decimal.Decimal `gorm:"type:numeric(18,4);default:0" json:"reservado"`
+    CreatedAt  time.Time       `gorm:"autoCreateTime" json:"createdAt"`
+    UpdatedAt  time.Time       `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+// Vencido indica se a validade do lote já passou (o lote vale até o fim do
+// dia da validade)
+func (l *Lote) Vencido(agora time.Time) bool {
+    ano, mes, dia := agora.Date()
+    hoje := time.Date(ano, mes, dia, 0, 0, 0, 0, time.UTC)
+    ano, mes, dia = l.Validade.Date()
+    return time.Date(ano, mes, dia, 0, 0, 0, 0, time.UTC).Before(hoje)
+}
+
+// Disponivel retorna a quantidade do lote que ainda pode ser reservada
+func (l *Lote) Disponivel(agora time.Time) decimal.Decimal {
+    if l.Vencido(agora) {
+        return decimal.Zero
+    }
+    return l.Saldo.Sub(l.Reservado)
+}
+
+// ReservaLote registra quanto de cada lote foi separado para uma reserva
+type ReservaLote struct {
+    ReservaID  uuid.UUID       `gorm:"type:uuid;primaryKey" json:"-"`
+    LoteID     uuid.UUID       `gorm:"type:uuid;primaryKey" json:"loteId"`
+    TenantID   string          `gorm:"type:varchar(14);not null;default:'';index" json:"-"`
+    Numero     string          `gorm:"not null" json:"numero"`
+    Validade   time.Time       `gorm:"type:date" json:"validade"`
+    Quantidade decimal.Decimal `gorm:"type:numeric(18,4);not null" json:"quantidade"`
+}
+
+// AlocarFEFO distribui a quantidade entre os lotes, primeiro os que vencem
+// antes (first-expire-first-out). Lotes vencidos são ignorados. Retorna
+// ok=false quando os lotes válidos não cobrem a quantidade.
+func AlocarFEFO(lotes []*Lote, quantidade decimal.Decimal, agora time.Time) (alocacoes []ReservaLote, ok bool) {
+    restante := quantidade
+    for _, l := range lotes {
+        if restante.IsZero() {
+            break
+        }
+        disponivel := l.Disponivel(agora)
+        if !disponivel.IsPositive() {
+            continue
+        }
+        qtd := decimal.Min(disponivel, restante)
+        alocacoes = append(alocacoes, ReservaLote{
+            LoteID:     l.ID,
+            Numero:     l.Numero,
+            Validade:   l.Validade,
+            Quantidade: qtd,
+        })
+        restante = restante.Sub(qtd)
+    }
+    return alocacoes, restante.IsZero()
+}
@@ -0,0 +1,96 @@
+// internal/domain/lote_test.go
+package domain
+
+import (
+    "testing"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+)
+
+func TestLoteVencido(t *testing.T) {
+    l := &Lote{Validade: time.Date(2026, 5, 10, 0, 0, 0, 0, time.UTC)}
+    casos := []struct {
+        agora   time.Time
+        vencido bool
+    }{
+        {time.Date(2026, 5, 9, 12, 0, 0, 0, time.UTC), false},
+        {time.Date(2026, 5, 10, 0, 0, 0, 0, time.UTC), false},
+        {time.Date(2026, 5, 10, 23, 59, 59, 0, time.UTC), false},
+        {time.Date(2026, 5, 11, 0, 0, 0, 0, time.UTC), true},
+        {time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC), true},
+    }
+    for _, c := range casos {
+        if got := l.Vencido(c.agora); got != c.vencido {
+            t.Errorf("Vencido(%s) = %v, esperado %v", c.agora, got, c.vencido)
+        }
+    }
+}
+
+func TestLoteDisponivel(t *testing.T) {
+    l := &Lote{
+        Validade:  time.Date(2026, 5, 10, 0, 0, 0, 0, time.UTC),
+        Saldo:     decimal.NewFromInt(10),
+        Reservado: decimal.NewFromInt(4),
+    }
+    if d := l.Disponivel(time.Date(2026, 5, 10, 8, 0, 0, 0, time.UTC)); !d.Equal(decimal.NewFromInt(6)) {
+        t.Errorf("disponível no dia da validade = %s, esperado 6", d)
+    }
+    if d := l.Disponivel(time.Date(2026, 5, 11, 8, 0, 0, 0, time.UTC)); !d.IsZero() {
+        t.Errorf("disponível vencido = %s, esperado 0", d)
+    }
+}
+
+func TestAlocarFEFO(t *testing.T) {
+    agora := time.Date(2026, 5, 10, 9, 0, 0, 0, time.UTC)
+    lote := func(numero string, dias int, saldo, reservado int64) *Lote {
+        return &Lote{
+            ID:        uuid.New(),
+            Numero:    numero,
+            Validade:  time.Date(2026, 5, 10+dias, 0, 0, 0, 0, time.UTC),
+            Saldo:     decimal.NewFromInt(saldo),
+            Reservado: decimal.NewFromInt(reservado),
+        }
+    }
+    // Na ordem de validade, como o repositório devolve os lotes
+    lotes := []*Lote{
+        lote("L0", -1, 50, 0),
+        lote("L1", 0, 5, 2),
+        lote("L2", 10, 4, 4),
+        lote("L3", 20, 10, 0),
+        lote("L4", 30, 10, 0),
+    }
+
+    casos := []struct {
+        nome       string
+        quantidade int64
+        esperado   map[string]int64
+        ok         bool
+    }{
+        {"cabe no primeiro válido", 3, map[string]int64{"L1": 3}, true},
+        {"completa com o seguinte", 8, map[string]int64{"L1": 3, "L3": 5}, true},
+        {"usa todos os válidos", 23, map[string]int64{"L1": 3, "L3": 10, "L4": 10}, true},
+        {"lotes válidos insuficientes", 24, map[string]int64{"L1": 3, "L3": 10, "L4": 10}, false},
+    }
+    for _, c := range casos {
+        t.Run(c.nome, func(t *testing.T) {
+            alocacoes, ok := AlocarFEFO(lotes, decimal.NewFromInt(c.quantidade), agora)
+            if ok != c.ok {
+                t.Errorf("ok = %v, esperado %v", ok, c.ok)
+            }
+            if len(alocacoes) != len(c.esperado) {
+                t.Fatalf("alocações = %+v, esperadas %v", alocacoes, c.esperado)
+            }
+            for _, a := range alocacoes {
+                if q, existe := c.esperado[a.Numero]; !existe || !a.Quantidade.Equal(decimal.NewFromInt(q)) {
+                    t.Errorf("lote %s alocado em %s, esperado %v", a.Numero, a.Quantidade, c.esperado)
+                }
+            }
+        })
+    }
+
+    if alocacoes, ok := AlocarFEFO(nil, decimal.NewFromInt(1), agora); ok || len(alocacoes) != 0 {
+        t.Errorf("sem lotes = %+v, %v", alocacoes, ok)
+    }
+}
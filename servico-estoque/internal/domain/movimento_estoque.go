@@ -0,0 +1,51 @@
+// internal/domain/movimento_estoque.go
+package domain
+
+import (
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+)
+
+// Tipos de movimentação registrados no kardex
+const (
+    MovimentoEntrada      = "ENTRADA"
+    MovimentoSaidaNota    = "SAIDA_NOTA"
+    MovimentoAjuste       = "AJUSTE"
+    MovimentoReserva      = "RESERVA"
+    MovimentoCancelamento = "CANCELAMENTO"
+    MovimentoEstorno      = "ESTORNO"
+)
+
+// MovimentoEstoque é um lançamento imutável do kardex. Cada alteração de
+// saldo ou de reserva de um produto gera exatamente um movimento, gravado na
+// mesma transação da alteração. CustoUnitario é o custo da entrada ou, nas
+// saídas, o custo médio baixado (CustoTotal é o custo da mercadoria vendida);
+// CustoMedio é o custo médio do produto após o movimento.
+type MovimentoEstoque struct {
+    ID             uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+    TenantID       string          `gorm:"type:varchar(14);not null;default:'';index" json:"-"`
+    ProdutoID      uuid.UUID       `gorm:"type:uuid;not null;index:idx_movimento_produto_data,priority:1" json:"produtoId"`
+    DepositoID     *uuid.UUID      `gorm:"type:uuid" json:"depositoId,omitempty"`
+    Tipo           string          `gorm:"not null" json:"tipo"`
+    Quantidade     decimal.Decimal `gorm:"type:numeric(18,4);not null" json:"quantidade"`
+    SaldoAnterior  decimal.Decimal `gorm:"type:numeric(18,4);not null" json:"saldoAnterior"`
+    SaldoPosterior decimal.Decimal `gorm:"type:numeric(18,4);not null" json:"saldoPosterior"`
+    CustoUnitario  decimal.Decimal `gorm:"type:numeric(18,6);default:0" json:"custoUnitario"`
+    CustoTotal     decimal.Decimal `gorm:"type:numeric(18,2);default:0" json:"custoTotal"`
+    CustoMedio     decimal.Decimal `gorm:"type:numeric(18,6);default:0" json:"custoMedio"`
+    NotaFiscalID   *uuid.UUID      `gorm:"type:uuid;index" json:"notaFiscalId,omitempty"`
+    Motivo         string          `json:"motivo,omitempty"`
+    CreatedAt      time.Time       `gorm:"autoCreateTime;index:idx_movimento_produto_data,priority:2" json:"createdAt"`
+}
+
+// FiltroMovimentos restringe a consulta ao kardex de um produto. Na
+// exportação, ProdutoID zero inclui todos os produtos.
+type FiltroMovimentos struct {
+    ProdutoID uuid.UUID
+    De        *time.Time
+    Ate       *time.Time
+    Page      int
+    Size      int
+}
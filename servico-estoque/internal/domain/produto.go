@@ -5,18 +5,33 @@ import (
     "time"
 
     "github.com/google/uuid"
+    "github.com/shopspring/decimal"
 )
 
+// Produto é o cadastro do item com seus saldos consolidados. Versao é
+// incrementada a cada alteração do cadastro (edição ou ajuste de saldo) e
+// serve de controle de concorrência otimista: a alteração só é gravada se a
+// versão lida ainda for a atual. Reservas e baixas não mudam a versão.
 type Produto struct {
-    ID          uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-    Codigo      string    `gorm:"uniqueIndex;not null" json:"codigo"`
-    Descricao   string    `gorm:"not null" json:"descricao"`
-    Saldo       int       `gorm:"not null" json:"saldo"`
-    Reservado   int       `gorm:"default:0" json:"reservado"`
-    CreatedAt   time.Time `gorm:"autoCreateTime" json:"createdAt"`
-    UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+    ID            uuid.UUID          `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+    TenantID      string             `gorm:"type:varchar(14);not null;default:'';uniqueIndex:idx_produtos_tenant_codigo,priority:1" json:"-"`
+    Codigo        string             `gorm:"not null;uniqueIndex:idx_produtos_tenant_codigo,priority:2" json:"codigo"`
+    Descricao     string             `gorm:"not null" json:"descricao"`
+    CategoriaID   *uuid.UUID         `gorm:"type:uuid;index" json:"categoriaId,omitempty"`
+    Saldo         decimal.Decimal    `gorm:"type:numeric(18,4);not null" json:"saldo"`
+    Reservado     decimal.Decimal    `gorm:"type:numeric(18,4);default:0" json:"reservado"`
+    CustoMedio    decimal.Decimal    `gorm:"type:numeric(18,6);default:0" json:"custoMedio"`
+    Unidade       string             `gorm:"not null;default:'UN'" json:"unidade"`
+    Conversoes    []ConversaoUnidade `gorm:"foreignKey:ProdutoID" json:"conversoes,omitempty"`
+    ControlaLote  bool               `gorm:"default:false" json:"controlaLote"`
+    ControlaSerie bool               `gorm:"default:false" json:"controlaSerie"`
+    Fiscal        DadosFiscais       `gorm:"embedded" json:"fiscal"`
+    Depositos     []SaldoDeposito    `gorm:"foreignKey:ProdutoID" json:"depositos,omitempty"`
+    CreatedAt     time.Time          `gorm:"autoCreateTime" json:"createdAt"`
+    UpdatedAt     time.Time          `gorm:"autoUpdateTime" json:"updatedAt"`
+    Versao        int64              `gorm:"not null;default:1" json:"versao"`
 }
 
-func (p *Produto) PodeReservar(quantidade int) bool {
-    return p.Saldo-p.Reservado >= quantidade
-}
\ No newline at end of file
+func (p *Produto) PodeReservar(quantidade decimal.Decimal) bool {
+    return p.Saldo.Sub(p.Reservado).GreaterThanOrEqual(quantidade)
+}
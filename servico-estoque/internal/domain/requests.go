@@ -1,22 +1,95 @@
 // internal/domain/requests.go
 package domain
 
-import "github.com/google/uuid"
+import (
+    "time"
 
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+)
+
+// CriarProdutoRequest cria um produto. Produtos com ControlaLote ou
+// ControlaSerie recebem saldo apenas por entrada de lote ou de séries, então
+// Saldo deve ser zero. Os dois controles não podem ser combinados. Unidade é
+// a unidade base (padrão "UN"), em que Saldo e todo o estoque são mantidos.
+// CustoUnitario é o custo do saldo inicial e inicia o custo médio. Fiscal
+// traz os atributos usados pelo faturamento na emissão da NF-e.
 type CriarProdutoRequest struct {
-    Codigo    string `json:"codigo" binding:"required"`
-    Descricao string `json:"descricao" binding:"required"`
-    Saldo     int    `json:"saldo" binding:"required,gte=0"`
+    Codigo        string             `json:"codigo" binding:"required"`
+    Descricao     string             `json:"descricao" binding:"required"`
+    CategoriaID   *uuid.UUID         `json:"categoriaId,omitempty"`
+    Saldo         decimal.Decimal    `json:"saldo"`
+    CustoUnitario decimal.Decimal    `json:"custoUnitario"`
+    Unidade       string             `json:"unidade,omitempty"`
+    Conversoes    []ConversaoUnidade `json:"conversoes,omitempty"`
+    ControlaLote  bool               `json:"controlaLote"`
+    ControlaSerie bool               `json:"controlaSerie"`
+    Fiscal        DadosFiscais       `json:"fiscal"`
+    DepositoID    *uuid.UUID         `json:"depositoId,omitempty"`
 }
 
+// AtualizarProdutoRequest altera os dados do produto. Saldo é o novo saldo
+// no depósito informado (ou no depósito padrão), na unidade base.
+// Conversoes, quando informado, substitui as unidades alternativas.
+// CustoUnitario é o custo do acréscimo quando o ajuste aumenta o saldo;
+// omitido, o acréscimo entra pelo custo médio atual. Fiscal, quando
+// informado, substitui todos os dados fiscais. CategoriaID move o produto de
+// categoria; o UUID nulo o deixa sem categoria.
 type AtualizarProdutoRequest struct {
-    Descricao *string `json:"descricao,omitempty"`
-    Saldo     *int    `json:"saldo,omitempty"`
+    Descricao     *string             `json:"descricao,omitempty"`
+    CategoriaID   *uuid.UUID          `json:"categoriaId,omitempty"`
+    Saldo         *decimal.Decimal    `json:"saldo,omitempty"`
+    CustoUnitario *decimal.Decimal    `json:"custoUnitario,omitempty"`
+    Conversoes    *[]ConversaoUnidade `json:"conversoes,omitempty"`
+    Fiscal        *DadosFiscais       `json:"fiscal,omitempty"`
+    DepositoID    *uuid.UUID          `json:"depositoId,omitempty"`
+    Motivo        string              `json:"motivo,omitempty"`
+}
+
+// EntradaLoteRequest dá entrada de uma quantidade em um lote. Se o lote já
+// existir no depósito a quantidade é somada. Datas no formato AAAA-MM-DD.
+type EntradaLoteRequest struct {
+    Numero        string           `json:"numero" binding:"required"`
+    Fabricacao    string           `json:"fabricacao,omitempty" binding:"omitempty,datetime=2006-01-02"`
+    Validade      string           `json:"validade" binding:"required,datetime=2006-01-02"`
+    Quantidade    decimal.Decimal  `json:"quantidade"`
+    CustoUnitario *decimal.Decimal `json:"custoUnitario,omitempty"`
+    DepositoID    *uuid.UUID       `json:"depositoId,omitempty"`
+    Motivo        string           `json:"motivo,omitempty"`
+}
+
+// EntradaSeriesRequest dá entrada de unidades de um produto controlado por
+// número de série, uma por número. CustoUnitario omitido usa o custo médio.
+type EntradaSeriesRequest struct {
+    Numeros       []string         `json:"numeros" binding:"required,min=1,dive,required"`
+    CustoUnitario *decimal.Decimal `json:"custoUnitario,omitempty"`
+    DepositoID    *uuid.UUID       `json:"depositoId,omitempty"`
+    Motivo        string           `json:"motivo,omitempty"`
+}
+
+// CriarCategoriaRequest cria uma categoria, como raiz ou filha de PaiID
+type CriarCategoriaRequest struct {
+    Codigo string     `json:"codigo" binding:"required"`
+    Nome   string     `json:"nome" binding:"required"`
+    PaiID  *uuid.UUID `json:"paiId,omitempty"`
+}
+
+type CriarDepositoRequest struct {
+    Codigo string `json:"codigo" binding:"required"`
+    Nome   string `json:"nome" binding:"required"`
+    Padrao bool   `json:"padrao"`
+}
+
+// CriarChaveAPIRequest cria uma chave de API. Sem ExpiraEm a chave não expira.
+type CriarChaveAPIRequest struct {
+    Nome     string     `json:"nome" binding:"required"`
+    Escopos  []string   `json:"escopos" binding:"required,min=1"`
+    ExpiraEm *time.Time `json:"expiraEm"`
 }
 
 type ReservarEstoqueRequest struct {
     NotaFiscalID uuid.UUID     `json:"notaFiscalId" binding:"required"`
-    Itens        []ItemReserva `json:"itens" binding:"required,dive"`
+    Itens        []ItemReserva `json:"itens" binding:"required,min=1,dive"`
 }
 
 type ConfirmarReservaRequest struct {
@@ -27,6 +100,26 @@ type CancelarReservaRequest struct {
     ReservaID uuid.UUID `json:"reservaId" binding:"required"`
 }
 
+// BaixarEstoqueRequest baixa os itens diretamente, sem reserva. OperacaoID
+// identifica a baixa para repetições e estorno; sem ela, a nota fiscal faz
+// esse papel. Uma das duas é obrigatória.
 type BaixarEstoqueRequest struct {
-    Itens []ItemReserva `json:"itens" binding:"required,dive"`
-}
\ No newline at end of file
+    OperacaoID   *uuid.UUID    `json:"operacaoId,omitempty"`
+    NotaFiscalID *uuid.UUID    `json:"notaFiscalId,omitempty"`
+    Itens        []ItemReserva `json:"itens" binding:"required,min=1,dive"`
+}
+
+// Operacao retorna o identificador da baixa: a operação ou, sem ela, a nota
+func (r BaixarEstoqueRequest) Operacao() (uuid.UUID, bool) {
+    switch {
+    case r.OperacaoID != nil && *r.OperacaoID != uuid.Nil:
+        return *r.OperacaoID, true
+    case r.NotaFiscalID != nil && *r.NotaFiscalID != uuid.Nil:
+        return *r.NotaFiscalID, true
+    }
+    return uuid.Nil, false
+}
+
+type EstornarBaixaRequest struct {
+    Motivo string `json:"motivo"`
+}
@@ -5,26 +5,55 @@ import (
     "time"
 
     "github.com/google/uuid"
+    "github.com/shopspring/decimal"
 )
 
+// Status possíveis de uma reserva
+const (
+    StatusReservaPendente   = "PENDENTE"
+    StatusReservaConfirmada = "CONFIRMADO"
+    StatusReservaCancelada  = "CANCELADO"
+    StatusReservaExpirada   = "EXPIRADO"
+)
+
+// ReservaEstoque é a quantidade de um produto separada para uma nota. Para
+// produtos controlados por lote, Lotes indica de quais lotes ela saiu; para
+// produtos controlados por série, Series lista as unidades separadas.
 type ReservaEstoque struct {
-    ID           uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-    ProdutoID    uuid.UUID `gorm:"type:uuid;not null;index" json:"produtoId"`
-    NotaFiscalID uuid.UUID `gorm:"type:uuid;not null;index" json:"notaFiscalId"`
-    Quantidade   int       `gorm:"not null" json:"quantidade"`
-    Status       string    `gorm:"default:'PENDENTE'" json:"status"`
-    ExpiresAt    time.Time `json:"expiresAt"`
-    CreatedAt    time.Time `gorm:"autoCreateTime" json:"createdAt"`
-    UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+    ID           uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+    TenantID     string          `gorm:"type:varchar(14);not null;default:'';index" json:"-"`
+    ProdutoID    uuid.UUID       `gorm:"type:uuid;not null;index" json:"produtoId"`
+    NotaFiscalID uuid.UUID       `gorm:"type:uuid;not null;index" json:"notaFiscalId"`
+    DepositoID   uuid.UUID       `gorm:"type:uuid;index" json:"depositoId"`
+    Quantidade   decimal.Decimal `gorm:"type:numeric(18,4);not null" json:"quantidade"`
+    Status       string          `gorm:"default:'PENDENTE'" json:"status"`
+    Lotes        []ReservaLote   `gorm:"foreignKey:ReservaID" json:"lotes,omitempty"`
+    Series       []string        `gorm:"-" json:"series,omitempty"`
+    ExpiresAt    time.Time       `gorm:"index" json:"expiresAt"`
+    CreatedAt    time.Time       `gorm:"autoCreateTime" json:"createdAt"`
+    UpdatedAt    time.Time       `gorm:"autoUpdateTime" json:"updatedAt"`
 }
 
+// Expirada indica se a reserva pendente já passou da validade
+func (r *ReservaEstoque) Expirada(agora time.Time) bool {
+    return r.Status == StatusReservaExpirada ||
+        (r.Status == StatusReservaPendente && !r.ExpiresAt.IsZero() && agora.After(r.ExpiresAt))
+}
+
+// ItemReserva é um item de reserva ou baixa. Quantidade está em Unidade, ou
+// na unidade base do produto quando Unidade é vazia. Para produtos
+// controlados por série, Series pode listar exatamente Quantidade números a
+// usar; vazio, as unidades disponíveis mais antigas do depósito são escolhidas.
 type ItemReserva struct {
-    ProdutoID  uuid.UUID `json:"produtoId" binding:"required"`
-    Quantidade int       `json:"quantidade" binding:"required,gt=0"`
+    ProdutoID  uuid.UUID       `json:"produtoId" binding:"required"`
+    DepositoID *uuid.UUID      `json:"depositoId,omitempty"`
+    Quantidade decimal.Decimal `json:"quantidade"`
+    Unidade    string          `json:"unidade,omitempty"`
+    Series     []string        `json:"series,omitempty" binding:"omitempty,dive,required"`
 }
 
 type ReservaResult struct {
-    ReservaID uuid.UUID       `json:"reservaId"`
+    ReservaID uuid.UUID        `json:"reservaId"`
     Reservas  []ReservaEstoque `json:"reservas"`
-    Mensagem  string          `json:"mensagem"`
-}
\ No newline at end of file
+    Mensagem  string           `json:"mensagem"`
+}
@@ -6,4 +6,14 @@ func NewErrorResponse(code, message string) map[string]any {
         "code":  code,
         "error": message,
     }
-}
\ No newline at end of file
+}
+
+// Pagina envelopa uma listagem paginada com seus metadados. Em listagens
+// por cursor, NextCursor continua a partir do último item e Page fica vazio.
+type Pagina[T any] struct {
+    Itens      []T    `json:"itens"`
+    Total      int64  `json:"total"`
+    Page       int    `json:"page,omitempty"`
+    Size       int    `json:"size"`
+    NextCursor string `json:"nextCursor,omitempty"`
+}
@@ -0,0 +1,45 @@
+// internal/domain/serie.go
+package domain
+
+import (
+    "time"
+
+    "github.com/google/uuid"
+)
+
+// Status possíveis de um número de série
+const (
+    SerieDisponivel = "DISPONIVEL"
+    SerieReservada  = "RESERVADO"
+    SerieVendida    = "VENDIDO"
+)
+
+// NumeroSerie é uma unidade individual de um produto controlado por número
+// de série. ReservaID e NotaFiscalID apontam para a última reserva e para a
+// nota em que a unidade foi vendida.
+type NumeroSerie struct {
+    ID           uuid.UUID        `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+    TenantID     string           `gorm:"type:varchar(14);not null;default:'';index" json:"-"`
+    ProdutoID    uuid.UUID        `gorm:"type:uuid;not null;uniqueIndex:idx_serie_produto_numero,priority:1" json:"produtoId"`
+    Numero       string           `gorm:"not null;uniqueIndex:idx_serie_produto_numero,priority:2" json:"numero"`
+    DepositoID   uuid.UUID        `gorm:"type:uuid;not null;index" json:"depositoId"`
+    Status       string           `gorm:"not null;default:'DISPONIVEL';index" json:"status"`
+    ReservaID    *uuid.UUID       `gorm:"type:uuid;index" json:"reservaId,omitempty"`
+    NotaFiscalID *uuid.UUID       `gorm:"type:uuid;index" json:"notaFiscalId,omitempty"`
+    Historico    []HistoricoSerie `gorm:"foreignKey:SerieID" json:"historico,omitempty"`
+    CreatedAt    time.Time        `gorm:"autoCreateTime" json:"createdAt"`
+    UpdatedAt    time.Time        `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+// HistoricoSerie registra cada mudança de status de um número de série
+type HistoricoSerie struct {
+    ID           uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+    TenantID     string     `gorm:"type:varchar(14);not null;default:'';index" json:"-"`
+    SerieID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"-"`
+    Status       string     `gorm:"not null" json:"status"`
+    DepositoID   uuid.UUID  `gorm:"type:uuid" json:"depositoId"`
+    ReservaID    *uuid.UUID `gorm:"type:uuid" json:"reservaId,omitempty"`
+    NotaFiscalID *uuid.UUID `gorm:"type:uuid" json:"notaFiscalId,omitempty"`
+    Motivo       string     `json:"motivo,omitempty"`
+    CreatedAt    time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+}
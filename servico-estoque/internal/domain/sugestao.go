@@ -0,0 +1,56 @@
+// internal/domain/sugestao.go
+package domain
+
+import (
+    "strings"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+)
+
+// Sugestao é um item do autocomplete de produtos
+type Sugestao struct {
+    ID         uuid.UUID       `json:"id"`
+    Codigo     string          `json:"codigo"`
+    Descricao  string          `json:"descricao"`
+    Disponivel decimal.Decimal `json:"disponivel"`
+}
+
+var semAcentos = strings.NewReplacer(
+    "á", "a", "à", "a", "â", "a", "ã", "a", "ä", "a",
+    "é", "e", "è", "e", "ê", "e", "ë", "e",
+    "í", "i", "ì", "i", "î", "i", "ï", "i",
+    "ó", "o", "ò", "o", "ô", "o", "õ", "o", "ö", "o",
+    "ú", "u", "ù", "u", "û", "u", "ü", "u",
+    "ç", "c", "ñ", "n",
+)
+
+// NormalizarTermo deixa o texto em minúsculas, sem acentos e sem espaços
+// repetidos, para comparar prefixos
+func NormalizarTermo(v string) string {
+    return strings.Join(strings.Fields(semAcentos.Replace(strings.ToLower(v))), " ")
+}
+
+// TermosSugestao lista os termos normalizados pelos quais o produto é
+// encontrado no autocomplete: o código, a descrição inteira e cada palavra
+// da descrição com ao menos duas letras
+func TermosSugestao(p Produto) []string {
+    vistos := map[string]bool{}
+    var termos []string
+    add := func(t string) {
+        if t != "" && !vistos[t] {
+            vistos[t] = true
+            termos = append(termos, t)
+        }
+    }
+
+    add(NormalizarTermo(p.Codigo))
+    descricao := NormalizarTermo(p.Descricao)
+    add(descricao)
+    for _, palavra := range strings.Fields(descricao) {
+        if len([]rune(palavra)) >= 2 {
+            add(palavra)
+        }
+    }
+    return termos
+}
@@ -0,0 +1,58 @@
+// internal/domain/tenant.go
+package domain
+
+import "strings"
+
+// Cada empresa atendida pelo serviço (tenant) é identificada pelo CNPJ sem
+// pontuação. Todas as tabelas têm a coluna TenantID, que o repositório
+// preenche e filtra a partir do contexto da requisição; ela não aparece no
+// JSON, exceto nas chaves de API.
+
+// tamanhoCNPJ é o tamanho do CNPJ sem pontuação, numérico ou alfanumérico
+const tamanhoCNPJ = 14
+
+// NormalizarCNPJ remove a pontuação do CNPJ que identifica a empresa
+// (tenant) e confere os dígitos verificadores. Aceita também o CNPJ
+// alfanumérico, em que as 12 primeiras posições podem ter letras.
+func NormalizarCNPJ(v string) (string, error) {
+    var b strings.Builder
+    for _, r := range strings.ToUpper(strings.TrimSpace(v)) {
+        switch {
+        case r == '.' || r == '/' || r == '-':
+        case r >= '0' && r <= '9', r >= 'A' && r <= 'Z':
+            b.WriteRune(r)
+        default:
+            return "", ErrTenantInvalido
+        }
+    }
+    cnpj := b.String()
+    if len(cnpj) != tamanhoCNPJ || strings.Count(cnpj, cnpj[:1]) == tamanhoCNPJ {
+        return "", ErrTenantInvalido
+    }
+    for _, r := range cnpj[12:] {
+        if r < '0' || r > '9' {
+            return "", ErrTenantInvalido
+        }
+    }
+    if digitoCNPJ(cnpj[:12]) != cnpj[12] || digitoCNPJ(cnpj[:13]) != cnpj[13] {
+        return "", ErrTenantInvalido
+    }
+    return cnpj, nil
+}
+
+// digitoCNPJ calcula o dígito verificador (módulo 11) da base informada. O
+// valor de cada posição é o código ASCII menos 48, o que mantém o cálculo
+// tradicional para dígitos e o estende às letras.
+func digitoCNPJ(base string) byte {
+    soma, peso := 0, 2
+    for i := len(base) - 1; i >= 0; i-- {
+        soma += int(base[i]-'0') * peso
+        if peso++; peso > 9 {
+            peso = 2
+        }
+    }
+    if resto := soma % 11; resto >= 2 {
+        return byte('0' + 11 - resto)
+    }
+    return '0'
+}
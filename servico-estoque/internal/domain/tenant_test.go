@@ -0,0 +1,41 @@
+// internal/domain/tenant_test.go
+package domain
+
+import (
+    "errors"
+    "testing"
+)
+
+func TestNormalizarCNPJ(t *testing.T) {
+    casos := []struct {
+        entrada  string
+        esperado string
+        erro     error
+    }{
+        {"11222333000181", "11222333000181", nil},
+        {"11.222.333/0001-81", "11222333000181", nil},
+        {" 11.222.333/0001-81 ", "11222333000181", nil},
+        {"12ABC34501DE35", "12ABC34501DE35", nil},
+        {"12.abc.345/01de-35", "12ABC34501DE35", nil},
+        {"11222333000182", "", ErrTenantInvalido},
+        {"12ABC34501DE36", "", ErrTenantInvalido},
+        {"12ABC34501DEA5", "", ErrTenantInvalido},
+        {"1122233300018", "", ErrTenantInvalido},
+        {"112223330001811", "", ErrTenantInvalido},
+        {"11 222 333 0001 81", "", ErrTenantInvalido},
+        {"11222333_000181", "", ErrTenantInvalido},
+        {"00000000000000", "", ErrTenantInvalido},
+        {"AAAAAAAAAAAAAA", "", ErrTenantInvalido},
+        {"", "", ErrTenantInvalido},
+    }
+    for _, c := range casos {
+        cnpj, err := NormalizarCNPJ(c.entrada)
+        if !errors.Is(err, c.erro) {
+            t.Errorf("NormalizarCNPJ(%q) erro = %v, esperado %v", c.entrada, err, c.erro)
+            continue
+        }
+        if cnpj != c.esperado {
+            t.Errorf("NormalizarCNPJ(%q) = %q, esperado %q", c.entrada, cnpj, c.esperado)
+        }
+    }
+}
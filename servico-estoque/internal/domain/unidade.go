@@ -0,0 +1,81 @@
+// internal/domain/unidade.go
+package domain
+
+import (
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+)
+
+// CasasDecimais é a precisão das quantidades de estoque, na unidade base
+const CasasDecimais = 4
+
+// UnidadePadrao é a unidade base de produtos criados sem unidade informada
+const UnidadePadrao = "UN"
+
+// ConversaoUnidade diz quantas unidades base cabem em uma unidade
+// alternativa do produto (ex.: 1 CX = 12 UN tem Unidade "CX" e Fator 12)
+type ConversaoUnidade struct {
+    ProdutoID uuid.UUID       `gorm:"type:uuid;primaryKey" json:"-"`
+    Unidade   string          `gorm:"primaryKey" json:"unidade"`
+    TenantID  string          `gorm:"type:varchar(14);not null;default:'';index" json:"-"`
+    Fator     decimal.Decimal `gorm:"type:numeric(18,6);not null" json:"fator"`
+}
+
+// ParaUnidadeBase converte uma quantidade informada em unidade para a
+// unidade base do produto. Unidade vazia indica a unidade base. O resultado
+// precisa ser positivo e caber em CasasDecimais.
+func (p *Produto) ParaUnidadeBase(quantidade decimal.Decimal, unidade string) (decimal.Decimal, error) {
+    if unidade != "" && unidade != p.Unidade {
+        fator, ok := p.FatorConversao(unidade)
+        if !ok {
+            return decimal.Zero, ErrUnidadeInvalida
+        }
+        quantidade = quantidade.Mul(fator)
+    }
+    if err := ValidarQuantidade(quantidade); err != nil {
+        return decimal.Zero, err
+    }
+    return quantidade, nil
+}
+
+// FatorConversao retorna o fator da unidade alternativa informada
+func (p *Produto) FatorConversao(unidade string) (decimal.Decimal, bool) {
+    for _, c := range p.Conversoes {
+        if c.Unidade == unidade {
+            return c.Fator, true
+        }
+    }
+    return decimal.Zero, false
+}
+
+// ValidarQuantidade exige uma quantidade positiva com até CasasDecimais
+func ValidarQuantidade(q decimal.Decimal) error {
+    if !q.IsPositive() || !q.Equal(q.Truncate(CasasDecimais)) {
+        return ErrQuantidadeInvalida
+    }
+    return nil
+}
+
+// ValidarSaldo exige um saldo não negativo com até CasasDecimais
+func ValidarSaldo(s decimal.Decimal) error {
+    if s.IsNegative() {
+        return ErrSaldoNegativo
+    }
+    if !s.Equal(s.Truncate(CasasDecimais)) {
+        return ErrQuantidadeInvalida
+    }
+    return nil
+}
+
+// ValidarConversoes verifica as unidades alternativas de um produto com a
+// unidade base informada
+func ValidarConversoes(base string, conversoes []ConversaoUnidade) error {
+    vistas := make(map[string]bool, len(conversoes))
+    for _, c := range conversoes {
+        if c.Unidade == "" || c.Unidade == base || vistas[c.Unidade] || !c.Fator.IsPositive() {
+            return ErrUnidadeInvalida
+        }
+        vistas[c.Unidade] = true
+    }
+    return nil
+}
@@ -0,0 +1,99 @@
+// internal/domain/unidade_test.go
+package domain
+
+import (
+    "errors"
+    "testing"
+
+    "github.com/shopspring/decimal"
+)
+
+func TestParaUnidadeBase(t *testing.T) {
+    p := &Produto{
+        Unidade: "UN",
+        Conversoes: []ConversaoUnidade{
+            {Unidade: "CX", Fator: decimal.NewFromInt(12)},
+            {Unidade: "KG", Fator: decimal.RequireFromString("0.0001")},
+            {Unidade: "PCT", Fator: decimal.RequireFromString("2.5")},
+        },
+    }
+
+    casos := []struct {
+        nome       string
+        quantidade string
+        unidade    string
+        esperado   string
+        erro       error
+    }{
+        {"unidade vazia é a base", "3", "", "3", nil},
+        {"unidade base", "3.25", "UN", "3.25", nil},
+        {"caixa", "2", "CX", "24", nil},
+        {"fator fracionário", "3", "PCT", "7.5", nil},
+        {"menor quantidade aceita", "1", "KG", "0.0001", nil},
+        {"unidade desconhecida", "1", "DZ", "", ErrUnidadeInvalida},
+        {"unidade em minúsculas", "1", "cx", "", ErrUnidadeInvalida},
+        {"conversão além das casas decimais", "0.5", "KG", "", ErrQuantidadeInvalida},
+        {"zero", "0", "CX", "", ErrQuantidadeInvalida},
+        {"negativa", "-1", "", "", ErrQuantidadeInvalida},
+    }
+    for _, c := range casos {
+        t.Run(c.nome, func(t *testing.T) {
+            q, err := p.ParaUnidadeBase(decimal.RequireFromString(c.quantidade), c.unidade)
+            if !errors.Is(err, c.erro) {
+                t.Fatalf("erro = %v, esperado %v", err, c.erro)
+            }
+            if c.erro == nil && !q.Equal(decimal.RequireFromString(c.esperado)) {
+                t.Errorf("quantidade = %s, esperada %s", q, c.esperado)
+            }
+        })
+    }
+}
+
+func TestValidarQuantidadeESaldo(t *testing.T) {
+    casos := []struct {
+        valor      string
+        quantidade error
+        saldo      error
+    }{
+        {"1", nil, nil},
+        {"0.0001", nil, nil},
+        {"123456.7890", nil, nil},
+        {"0", ErrQuantidadeInvalida, nil},
+        {"0.00001", ErrQuantidadeInvalida, ErrQuantidadeInvalida},
+        {"-1", ErrQuantidadeInvalida, ErrSaldoNegativo},
+        {"-0.00001", ErrQuantidadeInvalida, ErrSaldoNegativo},
+    }
+    for _, c := range casos {
+        v := decimal.RequireFromString(c.valor)
+        if err := ValidarQuantidade(v); !errors.Is(err, c.quantidade) {
+            t.Errorf("ValidarQuantidade(%s) = %v, esperado %v", c.valor, err, c.quantidade)
+        }
+        if err := ValidarSaldo(v); !errors.Is(err, c.saldo) {
+            t.Errorf("ValidarSaldo(%s) = %v, esperado %v", c.valor, err, c.saldo)
+        }
+    }
+}
+
+func TestValidarConversoes(t *testing.T) {
+    doze := decimal.NewFromInt(12)
+    casos := []struct {
+        nome       string
+        conversoes []ConversaoUnidade
+        erro       error
+    }{
+        {"sem conversões", nil, nil},
+        {"válidas", []ConversaoUnidade{{Unidade: "CX", Fator: doze}, {Unidade: "DZ", Fator: doze}}, nil},
+        {"unidade vazia", []ConversaoUnidade{{Unidade: "", Fator: doze}}, ErrUnidadeInvalida},
+        {"igual à base", []ConversaoUnidade{{Unidade: "UN", Fator: doze}}, ErrUnidadeInvalida},
+        {"repetida", []ConversaoUnidade{{Unidade: "CX", Fator: doze}, {Unidade: "CX", Fator: decimal.NewFromInt(6)}}, ErrUnidadeInvalida},
+        {"fator zero", []ConversaoUnidade{{Unidade: "CX", Fator: decimal.Zero}}, ErrUnidadeInvalida},
+        {"fator negativo", []ConversaoUnidade{{Unidade: "CX", Fator: doze.Neg()}}, ErrUnidadeInvalida},
+    }
+    for _, c := range casos {
+        t.Run(c.nome, func(t *testing.T) {
+            if err := ValidarConversoes("UN", c.conversoes); !errors.Is(err, c.erro) {
+                t.Errorf("ValidarConversoes = %v, esperado %v", err, c.erro)
+            }
+        })
+    }
+}
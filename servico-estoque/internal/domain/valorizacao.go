@@ -0,0 +1,140 @@
+// internal/domain/valorizacao.go
+package domain
+
+import (
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+)
+
+// CasasCusto é a precisão dos custos unitários
+const CasasCusto = 6
+
+// CasasValor é a precisão dos valores monetários totais
+const CasasValor = 2
+
+// EntradaComCusto aplica ao custo médio ponderado do produto a entrada de
+// quantidade ao custo unitário informado. Deve ser chamada antes de somar a
+// quantidade ao saldo. Com saldo zerado (ou negativo) o custo da entrada
+// passa a ser o custo médio.
+func (p *Produto) EntradaComCusto(quantidade, custoUnitario decimal.Decimal) {
+    total := p.Saldo.Add(quantidade)
+    if !p.Saldo.IsPositive() || !total.IsPositive() {
+        p.CustoMedio = custoUnitario
+        return
+    }
+    p.CustoMedio = p.Saldo.Mul(p.CustoMedio).
+        Add(quantidade.Mul(custoUnitario)).
+        DivRound(total, CasasCusto)
+}
+
+// ValidarCusto exige um custo unitário não negativo com até CasasCusto
+func ValidarCusto(c decimal.Decimal) error {
+    if c.IsNegative() || !c.Equal(c.Truncate(CasasCusto)) {
+        return ErrDadosInvalidos
+    }
+    return nil
+}
+
+// PosicaoEstoque é o saldo de um produto em um depósito com o custo médio do
+// produto, em uma data
+type PosicaoEstoque struct {
+    ProdutoID      uuid.UUID
+    Codigo         string
+    Descricao      string
+    DepositoID     uuid.UUID
+    DepositoCodigo string
+    Saldo          decimal.Decimal
+    CustoMedio     decimal.Decimal
+}
+
+// Valorizacao é o valor do estoque (saldo × custo médio) por produto e por
+// depósito. Data é nil para a posição atual.
+type Valorizacao struct {
+    Data      *time.Time            `json:"data,omitempty"`
+    Total     decimal.Decimal       `json:"total"`
+    Produtos  []ValorizacaoProduto  `json:"produtos"`
+    Depositos []ValorizacaoDeposito `json:"depositos"`
+}
+
+type ValorizacaoProduto struct {
+    ProdutoID  uuid.UUID          `json:"produtoId"`
+    Codigo     string             `json:"codigo"`
+    Descricao  string             `json:"descricao"`
+    CustoMedio decimal.Decimal    `json:"custoMedio"`
+    Saldo      decimal.Decimal    `json:"saldo"`
+    Valor      decimal.Decimal    `json:"valor"`
+    Depositos  []ValorizacaoSaldo `json:"depositos"`
+}
+
+type ValorizacaoSaldo struct {
+    DepositoID uuid.UUID       `json:"depositoId"`
+    Codigo     string          `json:"codigo"`
+    Saldo      decimal.Decimal `json:"saldo"`
+    Valor      decimal.Decimal `json:"valor"`
+}
+
+type ValorizacaoDeposito struct {
+    DepositoID uuid.UUID       `json:"depositoId"`
+    Codigo     string          `json:"codigo"`
+    Valor      decimal.Decimal `json:"valor"`
+}
+
+// Valorizar monta a valorização a partir das posições, que devem vir
+// ordenadas por produto
+func Valorizar(posicoes []PosicaoEstoque, data *time.Time) *Valorizacao {
+    v := &Valorizacao{
+        Data:      data,
+        Produtos:  []ValorizacaoProduto{},
+        Depositos: []ValorizacaoDeposito{},
+    }
+    depositos := map[uuid.UUID]int{}
+
+    for _, pos := range posicoes {
+        valor := pos.Saldo.Mul(pos.CustoMedio).Round(CasasValor)
+
+        n := len(v.Produtos)
+        if n == 0 || v.Produtos[n-1].ProdutoID != pos.ProdutoID {
+            v.Produtos = append(v.Produtos, ValorizacaoProduto{
+                ProdutoID:  pos.ProdutoID,
+                Codigo:     pos.Codigo,
+                Descricao:  pos.Descricao,
+                CustoMedio: pos.CustoMedio,
+            })
+            n++
+        }
+        p := &v.Produtos[n-1]
+        p.Saldo = p.Saldo.Add(pos.Saldo)
+        p.Valor = p.Valor.Add(valor)
+        p.Depositos = append(p.Depositos, ValorizacaoSaldo{
+            DepositoID: pos.DepositoID,
+            Codigo:     pos.DepositoCodigo,
+            Saldo:      pos.Saldo,
+            Valor:      valor,
+        })
+
+        i, ok := depositos[pos.DepositoID]
+        if !ok {
+            i = len(v.Depositos)
+            depositos[pos.DepositoID] = i
+            v.Depositos = append(v.Depositos, ValorizacaoDeposito{
+                DepositoID: pos.DepositoID,
+                Codigo:     pos.DepositoCodigo,
+            })
+        }
+        v.Depositos[i].Valor = v.Depositos[i].Valor.Add(valor)
+
+        v.Total = v.Total.Add(valor)
+    }
+    return v
+}
+
+// TotalEstoque é a soma das quantidades dos produtos de uma empresa, em
+// todas as unidades, para acompanhamento: o reservado e o disponível
+// (saldo menos reservado)
+type TotalEstoque struct {
+    TenantID   string
+    Reservado  decimal.Decimal
+    Disponivel decimal.Decimal
+}
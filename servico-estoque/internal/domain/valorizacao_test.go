@@ -0,0 +1,113 @@
+// internal/domain/valorizacao_test.go
+package domain
+
+import (
+    "testing"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+)
+
+func TestEntradaComCusto(t *testing.T) {
+    casos := []struct {
+        nome       string
+        saldo      string
+        custoMedio string
+        quantidade string
+        custo      string
+        esperado   string
+    }{
+        {"primeira entrada", "0", "0", "10", "5.5", "5.5"},
+        {"mesmo custo", "10", "5", "10", "5", "5"},
+        {"média ponderada", "10", "5", "30", "9", "8"},
+        {"arredonda em CasasCusto", "1", "1", "2", "2", "1.666667"},
+        {"saldo negativo assume o custo da entrada", "-4", "7", "10", "3", "3"},
+        {"entrada que não zera o negativo", "-10", "7", "4", "3", "3"},
+        {"entrada sem custo", "10", "4", "10", "0", "2"},
+    }
+    for _, c := range casos {
+        t.Run(c.nome, func(t *testing.T) {
+            p := &Produto{
+                Saldo:      decimal.RequireFromString(c.saldo),
+                CustoMedio: decimal.RequireFromString(c.custoMedio),
+            }
+            p.EntradaComCusto(decimal.RequireFromString(c.quantidade), decimal.RequireFromString(c.custo))
+            if !p.CustoMedio.Equal(decimal.RequireFromString(c.esperado)) {
+                t.Errorf("custo médio = %s, esperado %s", p.CustoMedio, c.esperado)
+            }
+        })
+    }
+}
+
+func TestValidarCusto(t *testing.T) {
+    casos := []struct {
+        custo  string
+        valido bool
+    }{
+        {"0", true},
+        {"12.5", true},
+        {"0.000001", true},
+        {"0.0000001", false},
+        {"-0.01", false},
+    }
+    for _, c := range casos {
+        if err := ValidarCusto(decimal.RequireFromString(c.custo)); (err == nil) != c.valido {
+            t.Errorf("ValidarCusto(%s) = %v, válido esperado %v", c.custo, err, c.valido)
+        }
+    }
+}
+
+func TestValorizar(t *testing.T) {
+    produtoA, produtoB := uuid.New(), uuid.New()
+    loja, central := uuid.New(), uuid.New()
+    d := decimal.RequireFromString
+
+    posicoes := []PosicaoEstoque{
+        {ProdutoID: produtoA, Codigo: "A", DepositoID: central, DepositoCodigo: "CENTRAL", Saldo: d("10"), CustoMedio: d("2.5")},
+        {ProdutoID: produtoA, Codigo: "A", DepositoID: loja, DepositoCodigo: "LOJA", Saldo: d("3"), CustoMedio: d("2.5")},
+        {ProdutoID: produtoB, Codigo: "B", DepositoID: central, DepositoCodigo: "CENTRAL", Saldo: d("3"), CustoMedio: d("0.333333")},
+    }
+    data := time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+    v := Valorizar(posicoes, &data)
+
+    if v.Data != &data {
+        t.Errorf("data não repassada")
+    }
+    if !v.Total.Equal(d("33.5")) {
+        t.Errorf("total = %s, esperado 33.5", v.Total)
+    }
+
+    if len(v.Produtos) != 2 {
+        t.Fatalf("produtos = %d, esperados 2", len(v.Produtos))
+    }
+    a, b := v.Produtos[0], v.Produtos[1]
+    if a.ProdutoID != produtoA || !a.Saldo.Equal(d("13")) || !a.Valor.Equal(d("32.5")) || len(a.Depositos) != 2 {
+        t.Errorf("produto A = %+v", a)
+    }
+    // 3 × 0,333333 = 0,999999, arredondado em CasasValor
+    if b.ProdutoID != produtoB || !b.Saldo.Equal(d("3")) || !b.Valor.Equal(d("1")) {
+        t.Errorf("produto B = %+v", b)
+    }
+
+    esperados := []ValorizacaoDeposito{
+        {DepositoID: central, Codigo: "CENTRAL", Valor: d("26")},
+        {DepositoID: loja, Codigo: "LOJA", Valor: d("7.5")},
+    }
+    if len(v.Depositos) != len(esperados) {
+        t.Fatalf("depósitos = %+v, esperados %+v", v.Depositos, esperados)
+    }
+    for i, e := range esperados {
+        g := v.Depositos[i]
+        if g.DepositoID != e.DepositoID || g.Codigo != e.Codigo || !g.Valor.Equal(e.Valor) {
+            t.Errorf("depósito %d = %+v, esperado %+v", i, g, e)
+        }
+    }
+}
+
+func TestValorizarSemPosicoes(t *testing.T) {
+    v := Valorizar(nil, nil)
+    if v.Data != nil || !v.Total.IsZero() || v.Produtos == nil || v.Depositos == nil {
+        t.Errorf("valorização vazia = %+v", v)
+    }
+}
@@ -0,0 +1,61 @@
+// internal/handler/categoria_handler.go
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"servico-estoque/internal/domain"
+	"servico-estoque/internal/service"
+)
+
+type CategoriaHandler struct {
+	service *service.EstoqueService
+	logger  *zap.Logger
+}
+
+func NewCategoriaHandler(service *service.EstoqueService, logger *zap.Logger) *CategoriaHandler {
+	return &CategoriaHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// RegisterRoutes registra as rotas de categorias no grupo informado
+func (h *CategoriaHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	categorias := rg.Group("/categorias")
+	categorias.GET("", permiteLeitura, h.ListarCategorias)
+	categorias.POST("", permiteGestao, h.CriarCategoria)
+}
+
+// ListarCategorias retorna a árvore de categorias com os totais de estoque
+// GET /api/categorias
+func (h *CategoriaHandler) ListarCategorias(c *gin.Context) {
+	arvore, err := h.service.ListarCategorias(c.Request.Context())
+	if err != nil {
+		writeError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, arvore)
+}
+
+// CriarCategoria cria uma nova categoria
+// POST /api/categorias
+func (h *CategoriaHandler) CriarCategoria(c *gin.Context) {
+	var req domain.CriarCategoriaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_REQUEST", err.Error()))
+		return
+	}
+
+	categoria, err := h.service.CriarCategoria(c.Request.Context(), req)
+	if err != nil {
+		writeError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, categoria)
+}
@@ -0,0 +1,100 @@
+// internal/handler/chave_api_handler.go
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"servico-estoque/internal/domain"
+	"servico-estoque/internal/service"
+)
+
+type ChaveAPIHandler struct {
+	service *service.ChaveAPIService
+	logger  *zap.Logger
+}
+
+func NewChaveAPIHandler(service *service.ChaveAPIService, logger *zap.Logger) *ChaveAPIHandler {
+	return &ChaveAPIHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// RegisterRoutes registra a administração de chaves de API, restrita ao gestor
+func (h *ChaveAPIHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	chaves := rg.Group("/chaves-api", permiteGestao)
+	chaves.GET("", h.ListarChaves)
+	chaves.POST("", h.CriarChave)
+	chaves.POST("/:id/rotacionar", h.RotacionarChave)
+	chaves.DELETE("/:id", h.RevogarChave)
+}
+
+// ListarChaves retorna todas as chaves, sem os segredos
+// GET /api/chaves-api
+func (h *ChaveAPIHandler) ListarChaves(c *gin.Context) {
+	chaves, err := h.service.ListarChaves(c.Request.Context())
+	if err != nil {
+		writeError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, chaves)
+}
+
+// CriarChave cria uma chave e retorna o segredo, que não é exibido de novo
+// POST /api/chaves-api
+func (h *ChaveAPIHandler) CriarChave(c *gin.Context) {
+	var req domain.CriarChaveAPIRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_REQUEST", err.Error()))
+		return
+	}
+
+	chave, err := h.service.CriarChave(c.Request.Context(), req)
+	if err != nil {
+		writeError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, chave)
+}
+
+// RotacionarChave troca o segredo da chave; o anterior deixa de valer
+// POST /api/chaves-api/:id/rotacionar
+func (h *ChaveAPIHandler) RotacionarChave(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_ID", "ID inválido"))
+		return
+	}
+
+	chave, err := h.service.RotacionarChave(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, chave)
+}
+
+// RevogarChave revoga a chave; a linha é mantida para auditoria
+// DELETE /api/chaves-api/:id
+func (h *ChaveAPIHandler) RevogarChave(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_ID", "ID inválido"))
+		return
+	}
+
+	chave, err := h.service.RevogarChave(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, chave)
+}
@@ -0,0 +1,61 @@
+// internal/handler/deposito_handler.go
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"servico-estoque/internal/domain"
+	"servico-estoque/internal/service"
+)
+
+type DepositoHandler struct {
+	service *service.EstoqueService
+	logger  *zap.Logger
+}
+
+func NewDepositoHandler(service *service.EstoqueService, logger *zap.Logger) *DepositoHandler {
+	return &DepositoHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// RegisterRoutes registra as rotas de depósitos no grupo informado
+func (h *DepositoHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	depositos := rg.Group("/depositos")
+	depositos.GET("", permiteLeitura, h.ListarDepositos)
+	depositos.POST("", permiteGestao, h.CriarDeposito)
+}
+
+// ListarDepositos retorna todos os depósitos
+// GET /api/depositos
+func (h *DepositoHandler) ListarDepositos(c *gin.Context) {
+	depositos, err := h.service.ListarDepositos(c.Request.Context())
+	if err != nil {
+		writeError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, depositos)
+}
+
+// CriarDeposito cria um novo depósito
+// POST /api/depositos
+func (h *DepositoHandler) CriarDeposito(c *gin.Context) {
+	var req domain.CriarDepositoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_REQUEST", err.Error()))
+		return
+	}
+
+	deposito, err := h.service.CriarDeposito(c.Request.Context(), req)
+	if err != nil {
+		writeError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, deposito)
+}
@@ -0,0 +1,102 @@
+// internal/handler/errors.go
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"servico-estoque/internal/domain"
+)
+
+// writeError traduz erros de domínio em respostas HTTP
+func writeError(c *gin.Context, logger *zap.Logger, err error) {
+	logger.Error("Erro no handler", zap.Error(err))
+
+	var itensErr *domain.ReservaItensError
+	if errors.As(err, &itensErr) {
+		resp := domain.NewErrorResponse("INSUFFICIENT_STOCK", itensErr.Error())
+		resp["itens"] = itensErr.Itens
+		c.JSON(http.StatusConflict, resp)
+		return
+	}
+
+	// Erros de planilha trazem o detalhe do problema na mensagem
+	if errors.Is(err, domain.ErrPlanilhaInvalida) {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_FILE", err.Error()))
+		return
+	}
+
+	switch err {
+	case domain.ErrProdutoNaoEncontrado:
+		c.JSON(http.StatusNotFound, domain.NewErrorResponse("NOT_FOUND", err.Error()))
+	case domain.ErrCodigoDuplicado:
+		c.JSON(http.StatusConflict, domain.NewErrorResponse("DUPLICATE_CODE", err.Error()))
+	case domain.ErrEstoqueInsuficiente:
+		c.JSON(http.StatusConflict, domain.NewErrorResponse("INSUFFICIENT_STOCK", err.Error()))
+	case domain.ErrReservaNaoEncontrada:
+		c.JSON(http.StatusNotFound, domain.NewErrorResponse("RESERVATION_NOT_FOUND", err.Error()))
+	case domain.ErrReservaExpirada:
+		c.JSON(http.StatusGone, domain.NewErrorResponse("RESERVATION_EXPIRED", err.Error()))
+	case domain.ErrReservaJaConfirmada:
+		c.JSON(http.StatusConflict, domain.NewErrorResponse("RESERVATION_ALREADY_CONFIRMED", err.Error()))
+	case domain.ErrReservaJaCancelada:
+		c.JSON(http.StatusConflict, domain.NewErrorResponse("RESERVATION_ALREADY_CANCELLED", err.Error()))
+	case domain.ErrSaldoNegativo:
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("NEGATIVE_BALANCE", err.Error()))
+	case domain.ErrQuantidadeInvalida:
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_QUANTITY", err.Error()))
+	case domain.ErrDadosInvalidos:
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_DATA", err.Error()))
+	case domain.ErrOperacaoNaoPermitida:
+		c.JSON(http.StatusForbidden, domain.NewErrorResponse("OPERATION_NOT_ALLOWED", err.Error()))
+	case domain.ErrDepositoNaoEncontrado:
+		c.JSON(http.StatusNotFound, domain.NewErrorResponse("WAREHOUSE_NOT_FOUND", err.Error()))
+	case domain.ErrLoteVencido:
+		c.JSON(http.StatusConflict, domain.NewErrorResponse("LOT_EXPIRED", err.Error()))
+	case domain.ErrLoteObrigatorio:
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("LOT_REQUIRED", err.Error()))
+	case domain.ErrProdutoSemLote:
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("PRODUCT_NOT_LOT_CONTROLLED", err.Error()))
+	case domain.ErrSerieObrigatoria:
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("SERIAL_REQUIRED", err.Error()))
+	case domain.ErrProdutoSemSerie:
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("PRODUCT_NOT_SERIAL_CONTROLLED", err.Error()))
+	case domain.ErrSerieNaoEncontrada:
+		c.JSON(http.StatusNotFound, domain.NewErrorResponse("SERIAL_NOT_FOUND", err.Error()))
+	case domain.ErrSerieIndisponivel:
+		c.JSON(http.StatusConflict, domain.NewErrorResponse("SERIAL_UNAVAILABLE", err.Error()))
+	case domain.ErrSerieDuplicada:
+		c.JSON(http.StatusConflict, domain.NewErrorResponse("DUPLICATE_SERIAL", err.Error()))
+	case domain.ErrUnidadeInvalida:
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_UNIT", err.Error()))
+	case domain.ErrNCMInvalido:
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_NCM", err.Error()))
+	case domain.ErrGTINInvalido:
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_GTIN", err.Error()))
+	case domain.ErrDadosFiscaisInvalidos:
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_FISCAL_DATA", err.Error()))
+	case domain.ErrCategoriaNaoEncontrada:
+		c.JSON(http.StatusNotFound, domain.NewErrorResponse("CATEGORY_NOT_FOUND", err.Error()))
+	case domain.ErrCursorInvalido:
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_CURSOR", err.Error()))
+	case domain.ErrBaixaNaoEncontrada:
+		c.JSON(http.StatusNotFound, domain.NewErrorResponse("WRITE_OFF_NOT_FOUND", err.Error()))
+	case domain.ErrIdempotenciaDivergente:
+		c.JSON(http.StatusUnprocessableEntity, domain.NewErrorResponse("IDEMPOTENCY_KEY_REUSED", err.Error()))
+	case domain.ErrVersaoDivergente:
+		c.JSON(http.StatusPreconditionFailed, domain.NewErrorResponse("PRECONDITION_FAILED", err.Error()))
+	case domain.ErrChaveAPINaoEncontrada:
+		c.JSON(http.StatusNotFound, domain.NewErrorResponse("API_KEY_NOT_FOUND", err.Error()))
+	case domain.ErrEscopoInvalido:
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_SCOPE", err.Error()))
+	case domain.ErrChaveAPIRevogada:
+		c.JSON(http.StatusConflict, domain.NewErrorResponse("API_KEY_REVOKED", err.Error()))
+	case domain.ErrOperacaoEmAndamento:
+		c.JSON(http.StatusConflict, domain.NewErrorResponse("OPERATION_IN_PROGRESS", err.Error()))
+	default:
+		c.JSON(http.StatusInternalServerError, domain.NewErrorResponse("INTERNAL_ERROR", "Erro interno do servidor"))
+	}
+}
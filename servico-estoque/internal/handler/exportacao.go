@@ -0,0 +1,81 @@
+// internal/handler/exportacao.go
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"servico-estoque/internal/domain"
+)
+
+// linhaExportacao é um item exportável: Valores dá os campos da linha no CSV,
+// e o próprio item é serializado na linha do JSON Lines
+type linhaExportacao interface {
+	Valores() []string
+}
+
+// exportador grava as linhas de uma exportação direto na resposta, sem
+// acumulá-las. Os cabeçalhos HTTP só são enviados na primeira linha (ou ao
+// concluir uma exportação vazia), para que um erro logo no início ainda
+// possa ser respondido com o status adequado.
+type exportador struct {
+	c        *gin.Context
+	formato  string
+	arquivo  string
+	colunas  []string
+	iniciado bool
+	csv      *csv.Writer
+	json     *json.Encoder
+}
+
+func novoExportador(c *gin.Context, formato, arquivo string, colunas []string) *exportador {
+	return &exportador{c: c, formato: formato, arquivo: arquivo, colunas: colunas}
+}
+
+func (e *exportador) iniciar() error {
+	if e.iniciado {
+		return nil
+	}
+	e.iniciado = true
+
+	tipo := "text/csv; charset=utf-8"
+	if e.formato == domain.ExportacaoJSONL {
+		tipo = "application/x-ndjson"
+	}
+	e.c.Header("Content-Type", tipo)
+	e.c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, e.arquivo, e.formato))
+	e.c.Status(http.StatusOK)
+
+	if e.formato == domain.ExportacaoJSONL {
+		e.json = json.NewEncoder(e.c.Writer)
+		return nil
+	}
+	e.csv = csv.NewWriter(e.c.Writer)
+	return e.csv.Write(e.colunas)
+}
+
+func (e *exportador) escrever(linha linhaExportacao) error {
+	if err := e.iniciar(); err != nil {
+		return err
+	}
+	if e.json != nil {
+		return e.json.Encode(linha)
+	}
+	return e.csv.Write(linha.Valores())
+}
+
+// concluir envia o que restou no buffer do CSV
+func (e *exportador) concluir() error {
+	if err := e.iniciar(); err != nil {
+		return err
+	}
+	if e.csv != nil {
+		e.csv.Flush()
+		return e.csv.Error()
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+// internal/handler/permissoes.go
+package handler
+
+import (
+	"servico-estoque/internal/auth"
+	"servico-estoque/internal/domain"
+	"servico-estoque/internal/middleware"
+)
+
+// Quem pode executar cada tipo de operação. O gestor pode tudo; o serviço
+// (integrações como o faturamento) só consulta e movimenta por reserva ou
+// baixa; o operador cadastra e movimenta, mas não exclui nem estorna. Chaves
+// de API valem apenas nas rotas que aceitam algum dos seus escopos.
+var (
+	// consultas, inclusive exportações
+	permiteLeitura = middleware.Exigir(auth.Permissao{
+		Papeis:  []string{auth.PapelLeitura, auth.PapelOperador, auth.PapelGestor, auth.PapelServico},
+		Escopos: []string{domain.EscopoProdutosLeitura},
+	})
+	// cadastro de produtos e entradas de lote e série
+	permiteCadastro = middleware.Exigir(auth.Permissao{
+		Papeis: []string{auth.PapelOperador, auth.PapelGestor},
+	})
+	// criação, confirmação e cancelamento de reservas
+	permiteReserva = middleware.Exigir(auth.Permissao{
+		Papeis:  []string{auth.PapelOperador, auth.PapelGestor, auth.PapelServico},
+		Escopos: []string{domain.EscopoReservasEscrita},
+	})
+	// baixa direta de estoque
+	permiteBaixa = middleware.Exigir(auth.Permissao{
+		Papeis:  []string{auth.PapelOperador, auth.PapelGestor, auth.PapelServico},
+		Escopos: []string{domain.EscopoEstoqueBaixar},
+	})
+	// exclusões, estornos, estrutura (depósitos e categorias), relatórios de
+	// custo e chaves de API
+	permiteGestao = middleware.Exigir(auth.Permissao{
+		Papeis: []string{auth.PapelGestor},
+	})
+)
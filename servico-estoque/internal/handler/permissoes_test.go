@@ -0,0 +1,188 @@
+// internal/handler/permissoes_test.go
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"servico-estoque/internal/auth"
+	"servico-estoque/internal/domain"
+	"servico-estoque/internal/middleware"
+)
+
+const segredoTeste = "segredo-de-teste-com-pelo-menos-32-caracteres"
+
+// rotaTeste é uma chamada que a própria rota recusa com 400 antes de usar o
+// serviço: quem tem permissão recebe 400, quem não tem recebe 403
+type rotaTeste struct {
+	metodo, caminho, corpo string
+	// permissão exigida: leitura, cadastro, reserva, baixa ou gestao
+	permissao string
+}
+
+var rotasTeste = []rotaTeste{
+	{http.MethodGet, "/api/produtos/x", "", "leitura"},
+	{http.MethodGet, "/api/produtos/baixas/x", "", "leitura"},
+	{http.MethodPost, "/api/produtos", "{", "cadastro"},
+	{http.MethodPut, "/api/produtos/x", "{}", "cadastro"},
+	{http.MethodPost, "/api/produtos/reservar", "{", "reserva"},
+	{http.MethodPost, "/api/produtos/cancelar-reserva", "{", "reserva"},
+	{http.MethodPost, "/api/produtos/baixar", "{", "baixa"},
+	{http.MethodDelete, "/api/produtos/x", "", "gestao"},
+	{http.MethodPost, "/api/produtos/baixas/x/estornar", "", "gestao"},
+	{http.MethodGet, "/api/relatorios/valorizacao?data=x", "", "gestao"},
+	{http.MethodPost, "/api/depositos", "{", "gestao"},
+	{http.MethodPost, "/api/categorias", "{", "gestao"},
+	{http.MethodPost, "/api/chaves-api", "{", "gestao"},
+}
+
+// permissoesDoPapel lista o que cada papel pode fazer, conforme permissoes.go
+var permissoesDoPapel = map[string][]string{
+	auth.PapelLeitura:  {"leitura"},
+	auth.PapelOperador: {"leitura", "cadastro", "reserva", "baixa"},
+	auth.PapelServico:  {"leitura", "reserva", "baixa"},
+	auth.PapelGestor:   {"leitura", "cadastro", "reserva", "baixa", "gestao"},
+	"":                 nil,
+}
+
+// permissoesDoEscopo lista o que cada escopo de chave de API permite
+var permissoesDoEscopo = map[string][]string{
+	domain.EscopoProdutosLeitura: {"leitura"},
+	domain.EscopoReservasEscrita: {"reserva"},
+	domain.EscopoEstoqueBaixar:   {"baixa"},
+}
+
+// chavesTeste valida como chave de API o próprio nome de um escopo, que
+// vira o único escopo da chave
+type chavesTeste struct{}
+
+func (chavesTeste) ValidarChave(ctx context.Context, texto string) (*domain.ChaveAPI, error) {
+	if _, ok := permissoesDoEscopo[texto]; !ok {
+		return nil, domain.ErrNaoAutenticado
+	}
+	return &domain.ChaveAPI{ID: uuid.New(), TenantID: "11222333000181", Escopos: []string{texto}}, nil
+}
+
+// novoRoteadorTeste registra todas as rotas, sem serviços, atrás da
+// autenticação por JWT ou chave de API
+func novoRoteadorTeste(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	validador, err := auth.NovoValidadorJWT(auth.Opcoes{Segredo: segredoTeste})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := zap.NewNop()
+	r := gin.New()
+	api := r.Group("/api")
+	api.Use(middleware.NewAutenticacao(validador, chavesTeste{}, logger).Handler())
+	NewProdutoHandler(nil, logger).RegisterRoutes(api)
+	NewDepositoHandler(nil, logger).RegisterRoutes(api)
+	NewCategoriaHandler(nil, logger).RegisterRoutes(api)
+	NewRelatorioHandler(nil, logger).RegisterRoutes(api)
+	NewChaveAPIHandler(nil, logger).RegisterRoutes(api)
+	return r
+}
+
+func tokenComPapel(t *testing.T, papel string) string {
+	t.Helper()
+	claims := jwt.MapClaims{
+		"sub": "usuario-1",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	if papel != "" {
+		claims["roles"] = []string{papel}
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(segredoTeste))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "Bearer " + token
+}
+
+func chamar(r http.Handler, rota rotaTeste, cabecalhos map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(rota.metodo, rota.caminho, strings.NewReader(rota.corpo))
+	if rota.corpo != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range cabecalhos {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// conferirPermissao confere que a rota respondeu 400 quando permitida e 403
+// OPERATION_NOT_ALLOWED quando não
+func conferirPermissao(t *testing.T, w *httptest.ResponseRecorder, permitida bool) {
+	t.Helper()
+	if permitida {
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, esperado 400 (permitida): %s", w.Code, w.Body.String())
+		}
+		return
+	}
+	var corpo struct {
+		Code string `json:"code"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &corpo)
+	if w.Code != http.StatusForbidden || corpo.Code != "OPERATION_NOT_ALLOWED" {
+		t.Errorf("resposta = %d %s, esperado 403 OPERATION_NOT_ALLOWED", w.Code, w.Body.String())
+	}
+}
+
+func TestPermissoesPorPapel(t *testing.T) {
+	r := novoRoteadorTeste(t)
+
+	for papel, permissoes := range permissoesDoPapel {
+		token := tokenComPapel(t, papel)
+		for _, rota := range rotasTeste {
+			nome := papel
+			if nome == "" {
+				nome = "sem-papel"
+			}
+			t.Run(nome+" "+rota.metodo+" "+rota.caminho, func(t *testing.T) {
+				w := chamar(r, rota, map[string]string{"Authorization": token})
+				conferirPermissao(t, w, slices.Contains(permissoes, rota.permissao))
+			})
+		}
+	}
+}
+
+func TestPermissoesPorEscopoDeChaveAPI(t *testing.T) {
+	r := novoRoteadorTeste(t)
+
+	for escopo, permissoes := range permissoesDoEscopo {
+		for _, rota := range rotasTeste {
+			t.Run(escopo+" "+rota.metodo+" "+rota.caminho, func(t *testing.T) {
+				w := chamar(r, rota, map[string]string{middleware.CabecalhoChaveAPI: escopo})
+				conferirPermissao(t, w, slices.Contains(permissoes, rota.permissao))
+			})
+		}
+	}
+}
+
+func TestRotasSemAutenticacaoRecusamCom401(t *testing.T) {
+	r := novoRoteadorTeste(t)
+
+	for _, rota := range rotasTeste {
+		if w := chamar(r, rota, nil); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s: status = %d, esperado 401", rota.metodo, rota.caminho, w.Code)
+		}
+		if w := chamar(r, rota, map[string]string{middleware.CabecalhoChaveAPI: "chave-revogada"}); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s com chave recusada: status = %d, esperado 401", rota.metodo, rota.caminho, w.Code)
+		}
+	}
+}
@@ -2,15 +2,22 @@
 package handler
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 
+	"servico-estoque/internal/auth"
 	"servico-estoque/internal/domain"
+	"servico-estoque/internal/middleware"
 	"servico-estoque/internal/service"
+	"servico-estoque/pkg/planilha"
 )
 
 type ProdutoHandler struct {
@@ -25,19 +32,151 @@ func NewProdutoHandler(service *service.EstoqueService, logger *zap.Logger) *Pro
 	}
 }
 
-// ListarProdutos retorna todos os produtos
-// GET /api/produtos
+// RegisterRoutes registra as rotas de produtos no grupo informado, cada uma
+// com os papéis que podem chamá-la
+func (h *ProdutoHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	produtos := rg.Group("/produtos")
+	produtos.GET("", permiteLeitura, h.ListarProdutos)
+	produtos.POST("", permiteCadastro, h.CriarProduto)
+	produtos.GET("/busca", permiteLeitura, h.BuscarProdutos)
+	produtos.GET("/sugestoes", permiteLeitura, h.Sugestoes)
+	produtos.GET("/export", permiteLeitura, h.ExportarProdutos)
+	produtos.GET("/export/movimentos", permiteLeitura, h.ExportarMovimentos)
+	produtos.POST("/importar", permiteCadastro, h.ImportarProdutos)
+	produtos.POST("/reservar", permiteReserva, h.ReservarEstoque)
+	produtos.POST("/confirmar-reserva", permiteReserva, h.ConfirmarReserva)
+	produtos.POST("/cancelar-reserva", permiteReserva, h.CancelarReserva)
+	produtos.POST("/baixar", permiteBaixa, h.BaixarEstoque)
+	produtos.GET("/baixas/:id", permiteLeitura, h.ObterBaixa)
+	produtos.POST("/baixas/:id/estornar", permiteGestao, h.EstornarBaixa)
+	produtos.GET("/:id", permiteLeitura, h.ObterProduto)
+	produtos.PUT("/:id", permiteCadastro, h.AtualizarProduto)
+	produtos.DELETE("/:id", permiteGestao, h.DeletarProduto)
+	produtos.GET("/:id/disponibilidade", permiteLeitura, h.VerificarDisponibilidade)
+	produtos.GET("/:id/movimentos", permiteLeitura, h.ListarMovimentos)
+	produtos.GET("/:id/lotes", permiteLeitura, h.ListarLotes)
+	produtos.POST("/:id/lotes", permiteCadastro, h.EntradaLote)
+	produtos.POST("/:id/series", permiteCadastro, h.EntradaSeries)
+	produtos.GET("/:id/series/:numero", permiteLeitura, h.ObterSerie)
+}
+
+// ListarProdutos retorna uma página de produtos. Aceita paginação por
+// page/size ou por cursor (nextCursor da página anterior), ordenação por
+// codigo, descricao, saldo, disponivel ou updatedAt e os filtros categoria
+// (incluindo as descendentes), disponivelAte, semDisponivel,
+// atualizadoDesde e comReservas.
+// GET /api/produtos?page=1&size=10&ordem=disponivel&direcao=desc&disponivelAte=5
 func (h *ProdutoHandler) ListarProdutos(c *gin.Context) {
-	produtos, err := h.service.ListarProdutos(c.Request.Context())
+	filtro, ok := lerFiltroProdutos(c)
+	if !ok {
+		return
+	}
+	var err error
+	if filtro.Page, filtro.Size, err = parsePaginacao(c); err != nil {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_PAGINATION", err.Error()))
+		return
+	}
+	if v := c.Query("cursor"); v != "" {
+		if filtro.Cursor, err = domain.LerCursor(v, filtro); err != nil {
+			h.handleError(c, err)
+			return
+		}
+	}
+
+	pagina, err := h.service.ListarProdutos(c.Request.Context(), filtro)
 	if err != nil {
 		h.handleError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, produtos)
+	c.JSON(http.StatusOK, pagina)
+}
+
+// ExportarProdutos transmite todos os produtos que atendem aos filtros e à
+// ordenação da listagem, em CSV ou JSON Lines, com o disponível calculado e,
+// com valorizacao=true (apenas gestor), o custo médio e o valor do estoque
+// GET /api/produtos/export?format=csv&categoria=...&valorizacao=true
+func (h *ProdutoHandler) ExportarProdutos(c *gin.Context) {
+	formato := c.DefaultQuery("format", domain.ExportacaoCSV)
+	if !domain.FormatoExportacaoValido(formato) {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_FORMAT", "Formato deve ser csv ou jsonl"))
+		return
+	}
+	filtro, ok := lerFiltroProdutos(c)
+	if !ok {
+		return
+	}
+	valorizar, err := parseBool(c.Query("valorizacao"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_REQUEST", "valorizacao deve ser true ou false"))
+		return
+	}
+	// Custos ficam restritos ao gestor, como o relatório de valorização
+	if valorizar && !middleware.PrincipalDe(c).TemAlgum(auth.PapelGestor) {
+		writeError(c, h.logger, domain.ErrOperacaoNaoPermitida)
+		return
+	}
+
+	e := novoExportador(c, formato, "produtos", domain.ColunasProdutoExportado(valorizar))
+	err = h.service.ExportarProdutos(c.Request.Context(), filtro, valorizar, func(p domain.ProdutoExportado) error {
+		return e.escrever(p)
+	})
+	h.concluirExportacao(c, e, err)
 }
 
-// ObterProduto retorna um produto por ID
+// ExportarMovimentos transmite o kardex em ordem cronológica, de todos os
+// produtos ou só do informado, em CSV ou JSON Lines
+// GET /api/produtos/export/movimentos?format=jsonl&de=2025-01-01&ate=2025-01-31&produto=...
+func (h *ProdutoHandler) ExportarMovimentos(c *gin.Context) {
+	formato := c.DefaultQuery("format", domain.ExportacaoCSV)
+	if !domain.FormatoExportacaoValido(formato) {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_FORMAT", "Formato deve ser csv ou jsonl"))
+		return
+	}
+
+	var filtro domain.FiltroMovimentos
+	var err error
+	if v := c.Query("produto"); v != "" {
+		if filtro.ProdutoID, err = uuid.Parse(v); err != nil {
+			c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_ID", "Produto inválido"))
+			return
+		}
+	}
+	if filtro.De, err = parseData(c.Query("de"), false); err != nil {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_DATE", "Data inicial inválida"))
+		return
+	}
+	if filtro.Ate, err = parseData(c.Query("ate"), true); err != nil {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_DATE", "Data final inválida"))
+		return
+	}
+
+	e := novoExportador(c, formato, "movimentos", domain.ColunasMovimentoExportado())
+	err = h.service.ExportarMovimentos(c.Request.Context(), filtro, func(m domain.MovimentoExportado) error {
+		return e.escrever(m)
+	})
+	h.concluirExportacao(c, e, err)
+}
+
+// concluirExportacao fecha o arquivo exportado. Um erro antes da primeira
+// linha ainda vira uma resposta de erro normal; depois dela o status já foi
+// enviado e resta registrar o erro e interromper o arquivo.
+func (h *ProdutoHandler) concluirExportacao(c *gin.Context, e *exportador, err error) {
+	if err == nil {
+		err = e.concluir()
+	}
+	if err == nil {
+		return
+	}
+	if !e.iniciado {
+		h.handleError(c, err)
+		return
+	}
+	h.logger.Error("Exportação interrompida", zap.String("path", c.FullPath()), zap.Error(err))
+	c.Abort()
+}
+
+// ObterProduto retorna um produto por ID, com a versão no cabeçalho ETag
 // GET /api/produtos/:id
 func (h *ProdutoHandler) ObterProduto(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
@@ -52,25 +191,111 @@ func (h *ProdutoHandler) ObterProduto(c *gin.Context) {
 		return
 	}
 
+	c.Header("ETag", etagProduto(produto))
 	c.JSON(http.StatusOK, produto)
 }
 
-// BuscarProdutos busca produtos por termo
-// GET /api/produtos/busca?q=termo
+// BuscarProdutos busca produtos por código, GTIN ou descrição, em ordem de
+// relevância. limit é sinônimo de size.
+// GET /api/produtos/busca?q=termo&limit=20&page=1
 func (h *ProdutoHandler) BuscarProdutos(c *gin.Context) {
-	query := c.Query("q")
-	if query == "" {
-		c.JSON(http.StatusOK, []domain.Produto{})
+	filtro := domain.FiltroBusca{Termo: strings.TrimSpace(c.Query("q"))}
+	var err error
+	if filtro.Page, filtro.Size, err = parsePaginacao(c); err != nil {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_PAGINATION", err.Error()))
+		return
+	}
+	if v := c.Query("limit"); v != "" {
+		if filtro.Size, err = strconv.Atoi(v); err != nil || filtro.Size < 1 || filtro.Size > tamanhoPaginaMaximo {
+			c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_PAGINATION",
+				fmt.Sprintf("limit deve estar entre 1 e %d", tamanhoPaginaMaximo)))
+			return
+		}
+	}
+
+	if filtro.Termo == "" {
+		c.JSON(http.StatusOK, domain.Pagina[domain.ResultadoBusca]{
+			Itens: []domain.ResultadoBusca{},
+			Page:  filtro.Page,
+			Size:  filtro.Size,
+		})
+		return
+	}
+
+	resultados, err := h.service.BuscarProdutos(c.Request.Context(), filtro)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resultados)
+}
+
+const (
+	sugestoesPadrao = 10
+	sugestoesMaximo = 50
+)
+
+// Sugestoes retorna produtos para o autocomplete, pelo prefixo do código ou
+// de palavras da descrição
+// GET /api/produtos/sugestoes?prefix=para&limit=10
+func (h *ProdutoHandler) Sugestoes(c *gin.Context) {
+	limite := sugestoesPadrao
+	if v := c.Query("limit"); v != "" {
+		var err error
+		if limite, err = strconv.Atoi(v); err != nil || limite < 1 || limite > sugestoesMaximo {
+			c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_PAGINATION",
+				fmt.Sprintf("limit deve estar entre 1 e %d", sugestoesMaximo)))
+			return
+		}
+	}
+
+	sugestoes, err := h.service.Sugestoes(c.Request.Context(), c.Query("prefix"), limite)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sugestoes)
+}
+
+// ImportarProdutos cadastra produtos em massa a partir de um CSV ou XLSX
+// enviado no campo "arquivo". O formato vem da extensão do arquivo ou do
+// parâmetro formato. Retorna o resultado de cada linha.
+// POST /api/produtos/importar?dryRun=true&upsert=true
+func (h *ProdutoHandler) ImportarProdutos(c *gin.Context) {
+	var opcoes domain.OpcoesImportacao
+	var err error
+	if opcoes.DryRun, err = parseBool(c.Query("dryRun")); err != nil {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_REQUEST", "dryRun deve ser true ou false"))
+		return
+	}
+	if opcoes.Upsert, err = parseBool(c.Query("upsert")); err != nil {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_REQUEST", "upsert deve ser true ou false"))
+		return
+	}
+
+	arquivo, err := c.FormFile("arquivo")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_REQUEST", "Envie o arquivo no campo \"arquivo\""))
+		return
+	}
+	formato := c.DefaultQuery("formato", planilha.FormatoDoArquivo(arquivo.Filename))
+
+	conteudo, err := arquivo.Open()
+	if err != nil {
+		h.handleError(c, err)
 		return
 	}
+	defer conteudo.Close()
 
-	produtos, err := h.service.BuscarProdutos(c.Request.Context(), query)
+	resultado, err := h.service.ImportarProdutos(c.Request.Context(), conteudo, formato, opcoes)
 	if err != nil {
 		h.handleError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, produtos)
+	c.JSON(http.StatusOK, resultado)
 }
 
 // CriarProduto cria um novo produto
@@ -88,10 +313,12 @@ func (h *ProdutoHandler) CriarProduto(c *gin.Context) {
 		return
 	}
 
+	c.Header("ETag", etagProduto(produto))
 	c.JSON(http.StatusCreated, produto)
 }
 
-// AtualizarProduto atualiza um produto
+// AtualizarProduto atualiza um produto. Exige If-Match com o ETag lido em
+// GET /api/produtos/:id e responde 412 se o produto mudou desde então.
 // PUT /api/produtos/:id
 func (h *ProdutoHandler) AtualizarProduto(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
@@ -99,6 +326,10 @@ func (h *ProdutoHandler) AtualizarProduto(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_ID", "ID inválido"))
 		return
 	}
+	versao, ok := versaoIfMatch(c)
+	if !ok {
+		return
+	}
 
 	var req domain.AtualizarProdutoRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -106,16 +337,18 @@ func (h *ProdutoHandler) AtualizarProduto(c *gin.Context) {
 		return
 	}
 
-	produto, err := h.service.AtualizarProduto(c.Request.Context(), id, req)
+	produto, err := h.service.AtualizarProduto(c.Request.Context(), id, versao, req)
 	if err != nil {
 		h.handleError(c, err)
 		return
 	}
 
+	c.Header("ETag", etagProduto(produto))
 	c.JSON(http.StatusOK, produto)
 }
 
-// DeletarProduto deleta um produto
+// DeletarProduto deleta um produto. Exige If-Match, como a atualização, e
+// responde 403 enquanto houver reservas pendentes do produto.
 // DELETE /api/produtos/:id
 func (h *ProdutoHandler) DeletarProduto(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
@@ -123,8 +356,12 @@ func (h *ProdutoHandler) DeletarProduto(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_ID", "ID inválido"))
 		return
 	}
+	versao, ok := versaoIfMatch(c)
+	if !ok {
+		return
+	}
 
-	if err := h.service.DeletarProduto(c.Request.Context(), id); err != nil {
+	if err := h.service.DeletarProduto(c.Request.Context(), id, versao); err != nil {
 		h.handleError(c, err)
 		return
 	}
@@ -133,7 +370,7 @@ func (h *ProdutoHandler) DeletarProduto(c *gin.Context) {
 }
 
 // VerificarDisponibilidade verifica disponibilidade de estoque
-// GET /api/produtos/:id/disponibilidade?quantidade=10
+// GET /api/produtos/:id/disponibilidade?quantidade=10&unidade=CX
 func (h *ProdutoHandler) VerificarDisponibilidade(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
@@ -142,13 +379,13 @@ func (h *ProdutoHandler) VerificarDisponibilidade(c *gin.Context) {
 	}
 
 	quantidadeStr := c.Query("quantidade")
-	quantidade, err := strconv.Atoi(quantidadeStr)
-	if err != nil || quantidade <= 0 {
+	quantidade, err := decimal.NewFromString(quantidadeStr)
+	if err != nil || !quantidade.IsPositive() {
 		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_QUANTITY", "Quantidade inválida"))
 		return
 	}
 
-	disponivel, err := h.service.VerificarDisponibilidade(c.Request.Context(), id, quantidade)
+	disponivel, err := h.service.VerificarDisponibilidade(c.Request.Context(), id, quantidade, c.Query("unidade"))
 	if err != nil {
 		h.handleError(c, err)
 		return
@@ -157,6 +394,122 @@ func (h *ProdutoHandler) VerificarDisponibilidade(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"disponivel": disponivel})
 }
 
+// ListarMovimentos retorna o kardex do produto
+// GET /api/produtos/:id/movimentos?de=2025-01-01&ate=2025-01-31&page=1&size=50
+func (h *ProdutoHandler) ListarMovimentos(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_ID", "ID inválido"))
+		return
+	}
+
+	filtro := domain.FiltroMovimentos{ProdutoID: id}
+	if filtro.De, err = parseData(c.Query("de"), false); err != nil {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_DATE", "Data inicial inválida"))
+		return
+	}
+	if filtro.Ate, err = parseData(c.Query("ate"), true); err != nil {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_DATE", "Data final inválida"))
+		return
+	}
+	if filtro.Page, filtro.Size, err = parsePaginacao(c); err != nil {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_PAGINATION", err.Error()))
+		return
+	}
+
+	pagina, err := h.service.ListarMovimentos(c.Request.Context(), filtro)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, pagina)
+}
+
+// ListarLotes retorna os lotes do produto em ordem de validade
+// GET /api/produtos/:id/lotes
+func (h *ProdutoHandler) ListarLotes(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_ID", "ID inválido"))
+		return
+	}
+
+	lotes, err := h.service.ListarLotes(c.Request.Context(), id)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, lotes)
+}
+
+// EntradaLote dá entrada de estoque em um lote do produto
+// POST /api/produtos/:id/lotes
+func (h *ProdutoHandler) EntradaLote(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_ID", "ID inválido"))
+		return
+	}
+
+	var req domain.EntradaLoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_REQUEST", err.Error()))
+		return
+	}
+
+	lote, err := h.service.EntradaLote(c.Request.Context(), id, req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, lote)
+}
+
+// EntradaSeries dá entrada de unidades de um produto controlado por série
+// POST /api/produtos/:id/series
+func (h *ProdutoHandler) EntradaSeries(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_ID", "ID inválido"))
+		return
+	}
+
+	var req domain.EntradaSeriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_REQUEST", err.Error()))
+		return
+	}
+
+	series, err := h.service.EntradaSeries(c.Request.Context(), id, req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, series)
+}
+
+// ObterSerie retorna um número de série com seu histórico completo
+// GET /api/produtos/:id/series/:numero
+func (h *ProdutoHandler) ObterSerie(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_ID", "ID inválido"))
+		return
+	}
+
+	serie, err := h.service.ObterSerie(c.Request.Context(), id, c.Param("numero"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, serie)
+}
+
 // ReservarEstoque reserva estoque para nota fiscal
 // POST /api/produtos/reservar
 func (h *ProdutoHandler) ReservarEstoque(c *gin.Context) {
@@ -209,7 +562,8 @@ func (h *ProdutoHandler) CancelarReserva(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Reserva cancelada com sucesso"})
 }
 
-// BaixarEstoque baixa estoque diretamente (chamado pelo serviço de faturamento)
+// BaixarEstoque baixa estoque diretamente (chamado pelo serviço de faturamento).
+// Repetir a mesma operacaoId (ou notaFiscalId) devolve a baixa original.
 // POST /api/produtos/baixar
 func (h *ProdutoHandler) BaixarEstoque(c *gin.Context) {
 	var req domain.BaixarEstoqueRequest
@@ -217,106 +571,197 @@ func (h *ProdutoHandler) BaixarEstoque(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_REQUEST", err.Error()))
 		return
 	}
+	if _, ok := req.Operacao(); !ok {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_REQUEST", "operacaoId ou notaFiscalId é obrigatório"))
+		return
+	}
 
-	if err := h.service.BaixarEstoque(c.Request.Context(), req.Itens); err != nil {
+	baixa, err := h.service.BaixarEstoque(c.Request.Context(), req)
+	if err != nil {
 		h.handleError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Estoque baixado com sucesso"})
+	c.JSON(http.StatusOK, baixa)
 }
 
-// handleError trata erros de forma centralizada
-func (h *ProdutoHandler) handleError(c *gin.Context, err error) {
-	h.logger.Error("Erro no handler", zap.Error(err))
-
-	switch err {
-	case domain.ErrProdutoNaoEncontrado:
-		c.JSON(http.StatusNotFound, domain.NewErrorResponse("NOT_FOUND", err.Error()))
-	case domain.ErrCodigoDuplicado:
-		c.JSON(http.StatusConflict, domain.NewErrorResponse("DUPLICATE_CODE", err.Error()))
-	case domain.ErrEstoqueInsuficiente:
-		c.JSON(http.StatusConflict, domain.NewErrorResponse("INSUFFICIENT_STOCK", err.Error()))
-	case domain.ErrReservaNaoEncontrada:
-		c.JSON(http.StatusNotFound, domain.NewErrorResponse("RESERVATION_NOT_FOUND", err.Error()))
-	case domain.ErrReservaExpirada:
-		c.JSON(http.StatusGone, domain.NewErrorResponse("RESERVATION_EXPIRED", err.Error()))
-	case domain.ErrReservaJaConfirmada:
-		c.JSON(http.StatusConflict, domain.NewErrorResponse("RESERVATION_ALREADY_CONFIRMED", err.Error()))
-	case domain.ErrReservaJaCancelada:
-		c.JSON(http.StatusConflict, domain.NewErrorResponse("RESERVATION_ALREADY_CANCELLED", err.Error()))
-	case domain.ErrSaldoNegativo:
-		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("NEGATIVE_BALANCE", err.Error()))
-	case domain.ErrQuantidadeInvalida:
-		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_QUANTITY", err.Error()))
-	case domain.ErrDadosInvalidos:
-		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_DATA", err.Error()))
-	case domain.ErrOperacaoNaoPermitida:
-		c.JSON(http.StatusForbidden, domain.NewErrorResponse("OPERATION_NOT_ALLOWED", err.Error()))
-	default:
-		c.JSON(http.StatusInternalServerError, domain.NewErrorResponse("INTERNAL_ERROR", "Erro interno do servidor"))
+// ObterBaixa retorna uma baixa direta com o que saiu de cada depósito
+// GET /api/produtos/baixas/:id
+func (h *ProdutoHandler) ObterBaixa(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_ID", "ID inválido"))
+		return
 	}
+
+	baixa, err := h.service.ObterBaixa(c.Request.Context(), id)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, baixa)
 }
 
-// pkg/lock/distributed_lock.go
-package lock
+// EstornarBaixa devolve ao estoque o que uma baixa direta tirou
+// POST /api/produtos/baixas/:id/estornar
+func (h *ProdutoHandler) EstornarBaixa(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_ID", "ID inválido"))
+		return
+	}
 
-import (
-	"context"
-	"fmt"
-	"time"
+	var req domain.EstornarBaixaRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_REQUEST", err.Error()))
+			return
+		}
+	}
 
-	"github.com/go-redis/redis/v8"
-	"github.com/google/uuid"
-)
+	baixa, err := h.service.EstornarBaixa(c.Request.Context(), id, req.Motivo)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
 
-type DistributedLock struct {
-	redis *redis.Client
+	c.JSON(http.StatusOK, baixa)
 }
 
-func NewDistributedLock(redis *redis.Client) *DistributedLock {
-	return &DistributedLock{redis: redis}
+// handleError trata erros de forma centralizada
+func (h *ProdutoHandler) handleError(c *gin.Context, err error) {
+	writeError(c, h.logger, err)
 }
 
-// AcquireLock tenta adquirir um lock distribuído
-func (l *DistributedLock) AcquireLock(ctx context.Context, resource string, ttl time.Duration) (string, error) {
-	lockKey := fmt.Sprintf("lock:%s", resource)
-	lockValue := uuid.New().String()
+const (
+	tamanhoPaginaPadrao = 50
+	tamanhoPaginaMaximo = 500
+)
+
+// etagProduto monta o ETag de um produto a partir da sua versão
+func etagProduto(p *domain.Produto) string {
+	return `"` + strconv.FormatInt(p.Versao, 10) + `"`
+}
 
-	// SET NX EX - apenas se não existir, com expiração
-	success, err := l.redis.SetNX(ctx, lockKey, lockValue, ttl).Result()
+// versaoIfMatch lê a versão esperada do cabeçalho If-Match. Sem o cabeçalho
+// responde 428; com um valor que não é um ETag de produto responde 412,
+// como um ETag que não confere. Nos dois casos retorna false.
+func versaoIfMatch(c *gin.Context) (int64, bool) {
+	v := strings.TrimSpace(c.GetHeader("If-Match"))
+	if v == "" {
+		c.JSON(http.StatusPreconditionRequired, domain.NewErrorResponse("PRECONDITION_REQUIRED", "Cabeçalho If-Match obrigatório"))
+		return 0, false
+	}
+	var versao int64
+	var err error
+	if len(v) < 2 || v[0] != '"' || v[len(v)-1] != '"' {
+		err = strconv.ErrSyntax
+	} else {
+		versao, err = strconv.ParseInt(v[1:len(v)-1], 10, 64)
+	}
 	if err != nil {
-		return "", err
+		c.JSON(http.StatusPreconditionFailed, domain.NewErrorResponse("PRECONDITION_FAILED", "If-Match não corresponde à versão do produto"))
+		return 0, false
 	}
+	return versao, true
+}
 
-	if !success {
-		return "", fmt.Errorf("não foi possível adquirir lock para %s", resource)
+// lerFiltroProdutos lê os filtros e a ordenação da listagem de produtos,
+// compartilhados pela listagem e pela exportação. Em caso de erro, já
+// responde 400 e retorna false.
+func lerFiltroProdutos(c *gin.Context) (domain.FiltroProdutos, bool) {
+	var filtro domain.FiltroProdutos
+	var err error
+
+	filtro.Ordem = c.DefaultQuery("ordem", domain.OrdemCodigo)
+	if !domain.OrdemProdutosValida(filtro.Ordem) {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_SORT", "Ordenação inválida"))
+		return filtro, false
+	}
+	switch c.DefaultQuery("direcao", "asc") {
+	case "asc":
+	case "desc":
+		filtro.Decrescente = true
+	default:
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_SORT", "Direção deve ser asc ou desc"))
+		return filtro, false
 	}
 
-	return lockValue, nil
+	if v := c.Query("categoria"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_ID", "Categoria inválida"))
+			return filtro, false
+		}
+		filtro.CategoriaID = &id
+	}
+	if v := c.Query("disponivelAte"); v != "" {
+		limite, err := decimal.NewFromString(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_QUANTITY", "Quantidade inválida"))
+			return filtro, false
+		}
+		filtro.DisponivelAte = &limite
+	}
+	if filtro.SemDisponivel, err = parseBool(c.Query("semDisponivel")); err != nil {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_REQUEST", "semDisponivel deve ser true ou false"))
+		return filtro, false
+	}
+	if filtro.AtualizadoDesde, err = parseData(c.Query("atualizadoDesde"), false); err != nil {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_DATE", "Data inválida"))
+		return filtro, false
+	}
+	if v := c.Query("comReservas"); v != "" {
+		comReservas, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_REQUEST", "comReservas deve ser true ou false"))
+			return filtro, false
+		}
+		filtro.ComReservas = &comReservas
+	}
+	return filtro, true
 }
 
-// ReleaseLock libera um lock distribuído
-func (l *DistributedLock) ReleaseLock(ctx context.Context, resource, lockValue string) error {
-	lockKey := fmt.Sprintf("lock:%s", resource)
+// parsePaginacao lê os parâmetros page e size da query string
+func parsePaginacao(c *gin.Context) (page, size int, err error) {
+	page, size = 1, tamanhoPaginaPadrao
+	if v := c.Query("page"); v != "" {
+		if page, err = strconv.Atoi(v); err != nil || page < 1 {
+			return 0, 0, fmt.Errorf("page deve ser um inteiro maior que zero")
+		}
+	}
+	if v := c.Query("size"); v != "" {
+		if size, err = strconv.Atoi(v); err != nil || size < 1 || size > tamanhoPaginaMaximo {
+			return 0, 0, fmt.Errorf("size deve estar entre 1 e %d", tamanhoPaginaMaximo)
+		}
+	}
+	return page, size, nil
+}
 
-	// Lua script para garantir atomicidade (só libera se o valor for o mesmo)
-	script := `
-		if redis.call("get", KEYS[1]) == ARGV[1] then
-			return redis.call("del", KEYS[1])
-		else
-			return 0
-		end
-	`
+// parseBool lê um parâmetro booleano opcional; vazio é falso
+func parseBool(v string) (bool, error) {
+	if v == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(v)
+}
 
-	result, err := l.redis.Eval(ctx, script, []string{lockKey}, lockValue).Result()
+// parseData aceita datas no formato RFC3339 ou AAAA-MM-DD. Quando fimDoDia é
+// verdadeiro, uma data sem horário cobre o dia inteiro.
+func parseData(v string, fimDoDia bool) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return &t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", v, time.Local)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	if result.(int64) == 0 {
-		return fmt.Errorf("lock não pertence a este cliente")
+	if fimDoDia {
+		t = t.Add(24*time.Hour - time.Nanosecond)
 	}
-
-	return nil
-}
\ No newline at end of file
+	return &t, nil
+}
@@ -0,0 +1,48 @@
+// internal/handler/relatorio_handler.go
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"servico-estoque/internal/domain"
+	"servico-estoque/internal/service"
+)
+
+type RelatorioHandler struct {
+	service *service.EstoqueService
+	logger  *zap.Logger
+}
+
+func NewRelatorioHandler(service *service.EstoqueService, logger *zap.Logger) *RelatorioHandler {
+	return &RelatorioHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// RegisterRoutes registra as rotas de relatórios no grupo informado
+func (h *RelatorioHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	relatorios := rg.Group("/relatorios")
+	relatorios.GET("/valorizacao", permiteGestao, h.Valorizacao)
+}
+
+// Valorizacao retorna o valor do estoque pelo custo médio, atual ou em uma data
+// GET /api/relatorios/valorizacao?data=2024-01-31
+func (h *RelatorioHandler) Valorizacao(c *gin.Context) {
+	data, err := parseData(c.Query("data"), true)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_DATE", "Data inválida"))
+		return
+	}
+
+	valorizacao, err := h.service.Valorizacao(c.Request.Context(), data)
+	if err != nil {
+		writeError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, valorizacao)
+}
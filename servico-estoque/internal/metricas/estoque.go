@@ -0,0 +1,63 @@
+// internal/metricas/estoque.go
+package metricas
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"servico-estoque/internal/domain"
+	"servico-estoque/internal/tenant"
+)
+
+// tempoConsultaTotais limita a consulta ao banco feita a cada coleta
+const tempoConsultaTotais = 5 * time.Second
+
+// FonteTotais fornece os totais de estoque por empresa
+type FonteTotais interface {
+	TotaisEstoque(ctx context.Context) ([]domain.TotalEstoque, error)
+}
+
+// ColetorEstoque expõe o total reservado e o disponível de cada empresa,
+// consultados no banco a cada coleta. As quantidades são somadas em todas
+// as unidades dos produtos e servem para acompanhar tendências.
+type ColetorEstoque struct {
+	fonte      FonteTotais
+	logger     *zap.Logger
+	reservado  *prometheus.Desc
+	disponivel *prometheus.Desc
+}
+
+func NovoColetorEstoque(fonte FonteTotais, logger *zap.Logger) *ColetorEstoque {
+	return &ColetorEstoque{
+		fonte:  fonte,
+		logger: logger,
+		reservado: prometheus.NewDesc("estoque_unidades_reservadas",
+			"Soma das quantidades reservadas dos produtos, por empresa.", []string{"tenant"}, nil),
+		disponivel: prometheus.NewDesc("estoque_unidades_disponiveis",
+			"Soma das quantidades disponíveis (saldo menos reservado) dos produtos, por empresa.", []string{"tenant"}, nil),
+	}
+}
+
+func (c *ColetorEstoque) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.reservado
+	ch <- c.disponivel
+}
+
+// Collect consulta os totais; se a consulta falhar, a coleta sai sem eles
+func (c *ColetorEstoque) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(tenant.Sistema(context.Background()), tempoConsultaTotais)
+	defer cancel()
+
+	totais, err := c.fonte.TotaisEstoque(ctx)
+	if err != nil {
+		c.logger.Warn("Erro ao consultar totais de estoque", zap.Error(err))
+		return
+	}
+	for _, t := range totais {
+		ch <- prometheus.MustNewConstMetric(c.reservado, prometheus.GaugeValue, t.Reservado.InexactFloat64(), t.TenantID)
+		ch <- prometheus.MustNewConstMetric(c.disponivel, prometheus.GaugeValue, t.Disponivel.InexactFloat64(), t.TenantID)
+	}
+}
@@ -0,0 +1,75 @@
+// internal/metricas/metricas.go
+package metricas
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Métricas expostas em /metrics. Os nomes levam o prefixo do serviço e os
+// rótulos têm poucos valores possíveis: rotas pelo padrão registrado no gin
+// (com :id), nunca pelo caminho concreto.
+var (
+	// DuracaoHTTP mede as requisições HTTP por método, rota e status
+	DuracaoHTTP = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "estoque_http_requisicao_duracao_segundos",
+		Help:    "Duração das requisições HTTP por método, rota e status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"metodo", "rota", "status"})
+
+	// Reservas conta os eventos do ciclo de vida das reservas. Todos os
+	// eventos contam reservas de item (uma por produto e depósito da nota),
+	// de modo que criadas menos confirmadas, canceladas e expiradas são as
+	// pendentes.
+	Reservas = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "estoque_reservas_total",
+		Help: "Reservas de item (uma por produto e depósito da nota) por evento: criada, confirmada, cancelada ou expirada.",
+	}, []string{"evento"})
+
+	// Baixas conta as baixas diretas realizadas e estornadas
+	Baixas = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "estoque_baixas_total",
+		Help: "Baixas diretas por evento: realizada ou estornada.",
+	}, []string{"evento"})
+
+	// EstoqueInsuficiente conta as operações recusadas por falta de estoque
+	EstoqueInsuficiente = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "estoque_insuficiente_total",
+		Help: "Operações recusadas por estoque insuficiente, por operação (reserva ou baixa).",
+	}, []string{"operacao"})
+
+	// Cache conta os acertos e as faltas do cache por consulta
+	Cache = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "estoque_cache_consultas_total",
+		Help: "Consultas ao cache por consulta e resultado (acerto ou falta).",
+	}, []string{"consulta", "resultado"})
+)
+
+// Eventos das reservas e das baixas
+const (
+	ReservaCriada     = "criada"
+	ReservaConfirmada = "confirmada"
+	ReservaCancelada  = "cancelada"
+	ReservaExpirada   = "expirada"
+
+	BaixaRealizada = "realizada"
+	BaixaEstornada = "estornada"
+)
+
+// Consultas com cache e resultados
+const (
+	CacheObterProduto   = "obter_produto"
+	CacheListarProdutos = "listar_produtos"
+
+	CacheAcerto = "acerto"
+	CacheFalta  = "falta"
+)
+
+// ConsultaCache registra o resultado de uma consulta ao cache
+func ConsultaCache(consulta string, acerto bool) {
+	resultado := CacheFalta
+	if acerto {
+		resultado = CacheAcerto
+	}
+	Cache.WithLabelValues(consulta, resultado).Inc()
+}
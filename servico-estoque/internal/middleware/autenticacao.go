@@ -0,0 +1,125 @@
+// internal/middleware/autenticacao.go
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"servico-estoque/internal/auth"
+	"servico-estoque/internal/domain"
+)
+
+// chavePrincipal guarda o principal autenticado no contexto do Gin
+const chavePrincipal = "principal"
+
+// CabecalhoChaveAPI é o cabeçalho das chaves de API das integrações
+const CabecalhoChaveAPI = "X-API-Key"
+
+// ValidadorChaveAPI confere uma chave de API e retorna a chave ativa
+type ValidadorChaveAPI interface {
+	ValidarChave(ctx context.Context, chave string) (*domain.ChaveAPI, error)
+}
+
+// Autenticacao exige um token JWT no cabeçalho Authorization ou uma chave de
+// API no cabeçalho X-API-Key
+type Autenticacao struct {
+	validador *auth.ValidadorJWT
+	chaves    ValidadorChaveAPI
+	logger    *zap.Logger
+}
+
+func NewAutenticacao(validador *auth.ValidadorJWT, chaves ValidadorChaveAPI, logger *zap.Logger) *Autenticacao {
+	return &Autenticacao{
+		validador: validador,
+		chaves:    chaves,
+		logger:    logger,
+	}
+}
+
+// Handler retorna o middleware. Com X-API-Key, vale a chave (e o
+// Authorization é ignorado); sem ela, é exigido "Authorization: Bearer
+// <token>". Credenciais ausentes ou inválidas recebem 401; as demais
+// requisições seguem com o principal disponível em PrincipalDe.
+func (a *Autenticacao) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if chave := c.GetHeader(CabecalhoChaveAPI); chave != "" {
+			a.autenticarChave(c, chave)
+			return
+		}
+
+		token, ok := tokenBearer(c.GetHeader("Authorization"))
+		if !ok {
+			naoAutenticado(c)
+			return
+		}
+		principal, err := a.validador.Validar(token)
+		if err != nil {
+			a.logger.Info("Token recusado", zap.String("caminho", c.FullPath()), zap.Error(err))
+			naoAutenticado(c)
+			return
+		}
+		c.Set(chavePrincipal, principal)
+		c.Next()
+	}
+}
+
+func (a *Autenticacao) autenticarChave(c *gin.Context, texto string) {
+	chave, err := a.chaves.ValidarChave(c.Request.Context(), texto)
+	if err == domain.ErrNaoAutenticado {
+		a.logger.Info("Chave de API recusada", zap.String("caminho", c.FullPath()))
+		naoAutenticado(c)
+		return
+	}
+	if err != nil {
+		a.logger.Error("Erro ao validar chave de API", zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, domain.NewErrorResponse("INTERNAL_ERROR", "Erro interno do servidor"))
+		return
+	}
+	c.Set(chavePrincipal, auth.NovoPrincipalChave(chave.ID.String(), chave.TenantID, chave.Escopos))
+	c.Next()
+}
+
+// Exigir permite a rota apenas a quem atender à permissão; os demais
+// recebem 403 OPERATION_NOT_ALLOWED
+func Exigir(perm auth.Permissao) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := PrincipalDe(c)
+		if principal == nil {
+			naoAutenticado(c)
+			return
+		}
+		if !principal.Permite(perm) {
+			c.AbortWithStatusJSON(http.StatusForbidden, domain.NewErrorResponse("OPERATION_NOT_ALLOWED", domain.ErrOperacaoNaoPermitida.Error()))
+			return
+		}
+		c.Next()
+	}
+}
+
+// PrincipalDe retorna o principal autenticado da requisição, ou nil
+func PrincipalDe(c *gin.Context) *auth.Principal {
+	if v, ok := c.Get(chavePrincipal); ok {
+		if p, ok := v.(*auth.Principal); ok {
+			return p
+		}
+	}
+	return nil
+}
+
+func tokenBearer(cabecalho string) (string, bool) {
+	esquema, token, ok := strings.Cut(strings.TrimSpace(cabecalho), " ")
+	if !ok || !strings.EqualFold(esquema, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func naoAutenticado(c *gin.Context) {
+	c.Header("WWW-Authenticate", `Bearer realm="servico-estoque"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, domain.NewErrorResponse("UNAUTHORIZED", domain.ErrNaoAutenticado.Error()))
+}
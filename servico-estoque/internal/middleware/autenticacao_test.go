@@ -0,0 +1,165 @@
+// internal/middleware/autenticacao_test.go
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+
+	"servico-estoque/internal/auth"
+)
+
+const (
+	segredoTeste   = "segredo-de-teste-com-pelo-menos-32-caracteres"
+	emissorTeste   = "https://auth.korp.test"
+	audienciaTeste = "servico-estoque"
+)
+
+func tokenTeste(t *testing.T, segredo string, alterar func(jwt.MapClaims)) string {
+	t.Helper()
+	claims := jwt.MapClaims{
+		"sub":   "usuario-1",
+		"iss":   emissorTeste,
+		"aud":   audienciaTeste,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"roles": []string{"operador"},
+	}
+	if alterar != nil {
+		alterar(claims)
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(segredo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "Bearer " + token
+}
+
+// novoRoteadorAutenticado registra GET /protegido, que exige o papel de
+// gestor, e GET /aberto, que só exige autenticação
+func novoRoteadorAutenticado(t *testing.T, chaves ValidadorChaveAPI) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	validador, err := auth.NovoValidadorJWT(auth.Opcoes{Segredo: segredoTeste, Emissor: emissorTeste, Audiencia: audienciaTeste})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.Use(NewAutenticacao(validador, chaves, zap.NewNop()).Handler())
+	r.GET("/aberto", func(c *gin.Context) {
+		c.JSON(http.StatusOK, PrincipalDe(c))
+	})
+	r.GET("/protegido", Exigir(auth.Permissao{Papeis: []string{auth.PapelGestor}}), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	return r
+}
+
+// codigoErro retorna o campo code do corpo de erro da resposta
+func codigoErro(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var corpo struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &corpo); err != nil {
+		t.Fatalf("corpo de erro inválido: %s", w.Body.String())
+	}
+	return corpo.Code
+}
+
+func TestAutenticacaoRecusaTokensInvalidos(t *testing.T) {
+	r := novoRoteadorAutenticado(t, nil)
+
+	casos := []struct {
+		nome          string
+		authorization string
+	}{
+		{"sem cabeçalho", ""},
+		{"esquema Basic", "Basic dXN1YXJpbzpzZW5oYQ=="},
+		{"Bearer vazio", "Bearer "},
+		{"malformado", "Bearer abc.def.ghi"},
+		{"assinatura errada", tokenTeste(t, segredoTeste+"-outro", nil)},
+		{"emissor errado", tokenTeste(t, segredoTeste, func(c jwt.MapClaims) { c["iss"] = "https://outro.test" })},
+		{"audiência errada", tokenTeste(t, segredoTeste, func(c jwt.MapClaims) { c["aud"] = "servico-faturamento" })},
+		{"expirado", tokenTeste(t, segredoTeste, func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() })},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			w := enviar(r, http.MethodGet, "/aberto", "", map[string]string{"Authorization": c.authorization})
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("status = %d, esperado 401", w.Code)
+			}
+			if w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("resposta 401 sem WWW-Authenticate")
+			}
+			if codigo := codigoErro(t, w); codigo != "UNAUTHORIZED" {
+				t.Errorf("código = %q, esperado UNAUTHORIZED", codigo)
+			}
+		})
+	}
+}
+
+func TestAutenticacaoAceitaTokenValido(t *testing.T) {
+	r := novoRoteadorAutenticado(t, nil)
+
+	w := enviar(r, http.MethodGet, "/aberto", "", map[string]string{"Authorization": tokenTeste(t, segredoTeste, nil)})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, esperado 200: %s", w.Code, w.Body.String())
+	}
+	var principal auth.Principal
+	if err := json.Unmarshal(w.Body.Bytes(), &principal); err != nil {
+		t.Fatal(err)
+	}
+	if principal.Sujeito != "usuario-1" || !principal.TemAlgum(auth.PapelOperador) {
+		t.Errorf("principal = %+v", principal)
+	}
+}
+
+func TestExigirRecusaPapelSemPermissao(t *testing.T) {
+	r := novoRoteadorAutenticado(t, nil)
+
+	casos := []struct {
+		nome   string
+		papeis []string
+		status int
+	}{
+		{"gestor", []string{"gestor"}, http.StatusNoContent},
+		{"operador", []string{"operador"}, http.StatusForbidden},
+		{"leitura", []string{"leitura"}, http.StatusForbidden},
+		{"servico", []string{"servico"}, http.StatusForbidden},
+		{"sem papel", nil, http.StatusForbidden},
+		{"papel desconhecido", []string{"admin"}, http.StatusForbidden},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			token := tokenTeste(t, segredoTeste, func(claims jwt.MapClaims) { claims["roles"] = c.papeis })
+			w := enviar(r, http.MethodGet, "/protegido", "", map[string]string{"Authorization": token})
+			if w.Code != c.status {
+				t.Fatalf("status = %d, esperado %d", w.Code, c.status)
+			}
+			if c.status != http.StatusForbidden {
+				return
+			}
+			if codigo := codigoErro(t, w); codigo != "OPERATION_NOT_ALLOWED" {
+				t.Errorf("código = %q, esperado OPERATION_NOT_ALLOWED", codigo)
+			}
+		})
+	}
+}
+
+func TestExigirSemPrincipalRecusaCom401(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/protegido", Exigir(auth.Permissao{Papeis: []string{auth.PapelGestor}}), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	if w := enviar(r, http.MethodGet, "/protegido", "", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, esperado 401", w.Code)
+	}
+}
@@ -0,0 +1,311 @@
+// internal/middleware/idempotencia.go
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"servico-estoque/internal/domain"
+	"servico-estoque/internal/tenant"
+)
+
+// CabecalhoIdempotencia é o cabeçalho com a chave escolhida pelo cliente
+const CabecalhoIdempotencia = "Idempotency-Key"
+
+// tamanhoMaximoChave limita a chave de idempotência, como nos provedores de
+// pagamento que popularizaram o cabeçalho
+const tamanhoMaximoChave = 255
+
+// intervaloEspera é o intervalo entre as consultas de quem espera a execução
+// de uma requisição duplicada
+const intervaloEspera = 100 * time.Millisecond
+
+// Estados de uma chave de idempotência
+const (
+	estadoEmExecucao = "em_execucao"
+	estadoConcluido  = "concluido"
+)
+
+// cabecalhosGuardados são os cabeçalhos da resposta repetidos na reexecução
+var cabecalhosGuardados = []string{"Content-Type", "ETag", "Location"}
+
+// registroIdempotencia é o que fica no Redis para cada chave: primeiro só o
+// marcador de execução, depois a resposta completa
+type registroIdempotencia struct {
+	Estado     string            `json:"estado"`
+	Token      string            `json:"token"`
+	Impressao  string            `json:"impressao"`
+	Status     int               `json:"status,omitempty"`
+	Cabecalhos map[string]string `json:"cabecalhos,omitempty"`
+	Corpo      []byte            `json:"corpo,omitempty"`
+}
+
+// concluirRegistro substitui o registro só se ele ainda pertencer à execução
+// (mesmo token): um marcador expirado e retomado por outra requisição não é
+// sobrescrito. Sem ARGV[2] o registro é removido.
+var concluirRegistro = redis.NewScript(`
+local atual = redis.call("GET", KEYS[1])
+if not atual or cjson.decode(atual).token ~= ARGV[1] then
+	return 0
+end
+if ARGV[2] == nil or ARGV[2] == "" then
+	return redis.call("DEL", KEYS[1])
+end
+redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+return 1
+`)
+
+// IdempotenciaOptions configura a retenção das respostas e a espera por
+// requisições duplicadas em execução
+type IdempotenciaOptions struct {
+	// TTL é por quanto tempo a resposta fica guardada para reexecuções
+	TTL time.Duration
+	// ExecucaoTTL é a validade do marcador de execução, que libera a chave
+	// se o processo morrer no meio da requisição
+	ExecucaoTTL time.Duration
+	// Espera é quanto uma duplicada aguarda a original antes de desistir
+	Espera time.Duration
+	// CorpoMaximo limita, em bytes, o corpo lido para a impressão e guardado
+	// no Redis; corpos maiores recebem 413
+	CorpoMaximo int64
+}
+
+// Idempotencia torna idempotentes as requisições que alteram estado e trazem
+// o cabeçalho Idempotency-Key
+type Idempotencia struct {
+	redis  *redis.Client
+	opts   IdempotenciaOptions
+	logger *zap.Logger
+}
+
+func NewIdempotencia(redis *redis.Client, opts IdempotenciaOptions, logger *zap.Logger) *Idempotencia {
+	return &Idempotencia{
+		redis:  redis,
+		opts:   opts,
+		logger: logger,
+	}
+}
+
+// Handler retorna o middleware. Para POST, PUT, PATCH e DELETE com
+// Idempotency-Key:
+//   - a primeira requisição grava um marcador de execução e, ao terminar,
+//     a resposta (status, cabeçalhos principais e corpo);
+//   - repetições com a mesma requisição recebem a resposta guardada, com o
+//     cabeçalho Idempotent-Replayed;
+//   - repetições que chegam durante a execução esperam a original terminar
+//     e, se ela demorar mais que Espera, recebem 409 OPERATION_IN_PROGRESS;
+//   - a mesma chave com outro método, caminho ou corpo recebe 422;
+//   - corpos maiores que CorpoMaximo recebem 413, sem executar a requisição.
+//
+// Respostas 5xx, 401, 403, 409, 412, 428 e 429 não são guardadas: a chave é
+// liberada e uma nova tentativa executa a requisição de novo. 401 e 403 (as
+// permissões de rota são verificadas depois deste middleware) e 412 e 428
+// dependem de credenciais e do If-Match, que ficam fora da impressão, e não
+// podem ser repetidos para quem ganhou a permissão ou corrigiu o cabeçalho. Sem o cabeçalho Idempotency-Key, a requisição
+// segue normalmente. Se o Redis falhar, a requisição também segue, sem
+// garantia de idempotência.
+func (i *Idempotencia) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		chave := c.GetHeader(CabecalhoIdempotencia)
+		if chave == "" || !alteraEstado(c.Request.Method) {
+			c.Next()
+			return
+		}
+		if len(chave) > tamanhoMaximoChave {
+			c.AbortWithStatusJSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_IDEMPOTENCY_KEY", "Idempotency-Key deve ter até 255 caracteres"))
+			return
+		}
+
+		corpo, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, i.opts.CorpoMaximo))
+		if err != nil {
+			var excedido *http.MaxBytesError
+			if errors.As(err, &excedido) {
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, domain.NewErrorResponse("PAYLOAD_TOO_LARGE", domain.ErrCorpoMuitoGrande.Error()))
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_REQUEST", "Erro ao ler a requisição"))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(corpo))
+
+		ctx := c.Request.Context()
+		// A chave vale por empresa e usuário: a mesma chave enviada por outro
+		// principal não recebe a resposta guardada de quem a usou primeiro
+		redisKey := "idempotencia:" + chave
+		if p := PrincipalDe(c); p != nil {
+			redisKey = "idempotencia:" + p.Sujeito + ":" + chave
+		}
+		redisKey = tenant.Chave(ctx, redisKey)
+		registro := registroIdempotencia{
+			Estado:    estadoEmExecucao,
+			Token:     uuid.NewString(),
+			Impressao: impressaoRequisicao(c.Request, corpo),
+		}
+
+		existente, err := i.reservar(ctx, redisKey, registro)
+		if err != nil {
+			i.logger.Warn("Erro ao verificar idempotência", zap.String("chave", chave), zap.Error(err))
+			c.Next()
+			return
+		}
+		if existente != nil {
+			i.responderExistente(c, existente, registro.Impressao)
+			return
+		}
+
+		gravador := &gravadorResposta{ResponseWriter: c.Writer}
+		c.Writer = gravador
+		c.Next()
+
+		i.concluir(context.WithoutCancel(ctx), redisKey, registro, gravador)
+	}
+}
+
+// reservar grava o marcador de execução da chave. Se a chave já existir,
+// espera até que ela seja concluída (ou liberada, quando tenta de novo) e
+// retorna o registro encontrado.
+func (i *Idempotencia) reservar(ctx context.Context, redisKey string, registro registroIdempotencia) (*registroIdempotencia, error) {
+	marcador, err := json.Marshal(registro)
+	if err != nil {
+		return nil, err
+	}
+
+	limite := time.Now().Add(i.opts.Espera)
+	for {
+		ok, err := i.redis.SetNX(ctx, redisKey, marcador, i.opts.ExecucaoTTL).Result()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return nil, nil
+		}
+
+		bruto, err := i.redis.Get(ctx, redisKey).Bytes()
+		if err == redis.Nil {
+			continue // liberada entre o SETNX e o GET
+		}
+		if err != nil {
+			return nil, err
+		}
+		var existente registroIdempotencia
+		if err := json.Unmarshal(bruto, &existente); err != nil {
+			return nil, err
+		}
+		if existente.Estado == estadoConcluido ||
+			existente.Impressao != registro.Impressao ||
+			!time.Now().Before(limite) {
+			return &existente, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(intervaloEspera):
+		}
+	}
+}
+
+// responderExistente responde a uma requisição cuja chave já está em uso
+func (i *Idempotencia) responderExistente(c *gin.Context, existente *registroIdempotencia, impressao string) {
+	if existente.Impressao != impressao {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, domain.NewErrorResponse("IDEMPOTENCY_KEY_REUSED", domain.ErrIdempotenciaDivergente.Error()))
+		return
+	}
+	if existente.Estado != estadoConcluido {
+		c.Header("Retry-After", "1")
+		c.AbortWithStatusJSON(http.StatusConflict, domain.NewErrorResponse("OPERATION_IN_PROGRESS", domain.ErrOperacaoEmAndamento.Error()))
+		return
+	}
+
+	for k, v := range existente.Cabecalhos {
+		c.Header(k, v)
+	}
+	c.Header("Idempotent-Replayed", "true")
+	c.Status(existente.Status)
+	c.Writer.Write(existente.Corpo)
+	c.Abort()
+}
+
+// concluir guarda a resposta produzida ou libera a chave quando a resposta
+// não deve ser repetida
+func (i *Idempotencia) concluir(ctx context.Context, redisKey string, registro registroIdempotencia, gravador *gravadorResposta) {
+	if !guardarResposta(gravador.Status()) {
+		if err := concluirRegistro.Run(ctx, i.redis, []string{redisKey}, registro.Token).Err(); err != nil {
+			i.logger.Warn("Erro ao liberar chave de idempotência", zap.String("chave", redisKey), zap.Error(err))
+		}
+		return
+	}
+
+	registro.Estado = estadoConcluido
+	registro.Status = gravador.Status()
+	registro.Corpo = gravador.corpo.Bytes()
+	registro.Cabecalhos = map[string]string{}
+	for _, k := range cabecalhosGuardados {
+		if v := gravador.Header().Get(k); v != "" {
+			registro.Cabecalhos[k] = v
+		}
+	}
+	resposta, err := json.Marshal(registro)
+	if err == nil {
+		err = concluirRegistro.Run(ctx, i.redis, []string{redisKey}, registro.Token, resposta, i.opts.TTL.Milliseconds()).Err()
+	}
+	if err != nil {
+		i.logger.Warn("Erro ao guardar resposta idempotente", zap.String("chave", redisKey), zap.Error(err))
+	}
+}
+
+// guardarResposta indica se a resposta com o status informado é guardada
+// para reexecuções
+func guardarResposta(status int) bool {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden,
+		http.StatusConflict, http.StatusPreconditionFailed, http.StatusPreconditionRequired, http.StatusTooManyRequests:
+		return false
+	}
+	return status < http.StatusInternalServerError
+}
+
+// impressaoRequisicao identifica a requisição pelo método, caminho, query e
+// corpo, para detectar a mesma chave usada em outra requisição
+func impressaoRequisicao(r *http.Request, corpo []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery+"\n")
+	h.Write(corpo)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func alteraEstado(metodo string) bool {
+	switch metodo {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// gravadorResposta copia o corpo da resposta enquanto ele é enviado
+type gravadorResposta struct {
+	gin.ResponseWriter
+	corpo bytes.Buffer
+}
+
+func (g *gravadorResposta) Write(b []byte) (int, error) {
+	g.corpo.Write(b)
+	return g.ResponseWriter.Write(b)
+}
+
+func (g *gravadorResposta) WriteString(s string) (int, error) {
+	g.corpo.WriteString(s)
+	return g.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,269 @@
+// internal/middleware/idempotencia_test.go
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+
+	"servico-estoque/internal/auth"
+	"servico-estoque/internal/tenant"
+)
+
+// cabecalhoSujeito escolhe, nos testes, o sujeito do principal autenticado
+const cabecalhoSujeito = "X-Teste-Sujeito"
+
+func novoRedisTeste(t *testing.T) (*redis.Client, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return rdb, mr
+}
+
+// autenticarTeste faz o papel da autenticação, com o sujeito do cabeçalho
+func autenticarTeste(c *gin.Context) {
+	sujeito := c.GetHeader(cabecalhoSujeito)
+	if sujeito == "" {
+		sujeito = "usuario-1"
+	}
+	c.Set(chavePrincipal, auth.NovoPrincipal(sujeito, nil, nil))
+	c.Next()
+}
+
+func enviar(r http.Handler, metodo, caminho, corpo string, cabecalhos map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(metodo, caminho, strings.NewReader(corpo))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range cabecalhos {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+var opcoesIdempotenciaTeste = IdempotenciaOptions{
+	TTL:         time.Hour,
+	ExecucaoTTL: time.Minute,
+	CorpoMaximo: 64,
+}
+
+// novoRoteadorIdempotente registra POST /reservas respondendo com o status
+// informado e conta quantas vezes a rota foi executada
+func novoRoteadorIdempotente(t *testing.T, rdb *redis.Client, status int) (*gin.Engine, *atomic.Int32) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	execucoes := &atomic.Int32{}
+	r := gin.New()
+	r.Use(autenticarTeste, NewIdempotencia(rdb, opcoesIdempotenciaTeste, zap.NewNop()).Handler())
+	r.POST("/reservas", func(c *gin.Context) {
+		n := execucoes.Add(1)
+		c.Header("ETag", `"1"`)
+		c.Header("X-Nao-Guardado", "sim")
+		c.JSON(status, gin.H{"execucao": n})
+	})
+	return r, execucoes
+}
+
+func chave(k string) map[string]string {
+	return map[string]string{CabecalhoIdempotencia: k}
+}
+
+func TestImpressaoRequisicao(t *testing.T) {
+	impressao := func(metodo, url, corpo string) string {
+		return impressaoRequisicao(httptest.NewRequest(metodo, url, nil), []byte(corpo))
+	}
+	base := impressao(http.MethodPost, "/api/produtos/reservar?a=1", `{"x":1}`)
+
+	casos := []struct {
+		nome   string
+		metodo string
+		url    string
+		corpo  string
+		igual  bool
+	}{
+		{"mesma requisição", http.MethodPost, "/api/produtos/reservar?a=1", `{"x":1}`, true},
+		{"host ignorado", http.MethodPost, "http://outro/api/produtos/reservar?a=1", `{"x":1}`, true},
+		{"outro método", http.MethodPut, "/api/produtos/reservar?a=1", `{"x":1}`, false},
+		{"outro caminho", http.MethodPost, "/api/produtos/baixar?a=1", `{"x":1}`, false},
+		{"outra query", http.MethodPost, "/api/produtos/reservar?a=2", `{"x":1}`, false},
+		{"sem query", http.MethodPost, "/api/produtos/reservar", `{"x":1}`, false},
+		{"outro corpo", http.MethodPost, "/api/produtos/reservar?a=1", `{"x":2}`, false},
+	}
+	for _, c := range casos {
+		if got := impressao(c.metodo, c.url, c.corpo) == base; got != c.igual {
+			t.Errorf("%s: impressão igual = %v, esperado %v", c.nome, got, c.igual)
+		}
+	}
+}
+
+func TestGuardarResposta(t *testing.T) {
+	casos := []struct {
+		status  int
+		guardar bool
+	}{
+		{http.StatusOK, true},
+		{http.StatusCreated, true},
+		{http.StatusNoContent, true},
+		{http.StatusBadRequest, true},
+		{http.StatusNotFound, true},
+		{http.StatusUnprocessableEntity, true},
+		{http.StatusUnauthorized, false},
+		{http.StatusForbidden, false},
+		{http.StatusConflict, false},
+		{http.StatusPreconditionFailed, false},
+		{http.StatusPreconditionRequired, false},
+		{http.StatusTooManyRequests, false},
+		{http.StatusInternalServerError, false},
+		{http.StatusServiceUnavailable, false},
+	}
+	for _, c := range casos {
+		if got := guardarResposta(c.status); got != c.guardar {
+			t.Errorf("guardarResposta(%d) = %v, esperado %v", c.status, got, c.guardar)
+		}
+	}
+}
+
+func TestIdempotenciaRepeteRespostaGuardada(t *testing.T) {
+	rdb, _ := novoRedisTeste(t)
+	r, execucoes := novoRoteadorIdempotente(t, rdb, http.StatusCreated)
+
+	primeira := enviar(r, http.MethodPost, "/reservas", `{"notaId":"N1"}`, chave("k1"))
+	repetida := enviar(r, http.MethodPost, "/reservas", `{"notaId":"N1"}`, chave("k1"))
+
+	if n := execucoes.Load(); n != 1 {
+		t.Fatalf("execuções = %d, esperada 1", n)
+	}
+	if repetida.Code != http.StatusCreated || repetida.Body.String() != primeira.Body.String() {
+		t.Errorf("repetição = %d %s, esperado %d %s", repetida.Code, repetida.Body, primeira.Code, primeira.Body)
+	}
+	if repetida.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("repetição sem Idempotent-Replayed")
+	}
+	if repetida.Header().Get("ETag") != `"1"` || repetida.Header().Get("X-Nao-Guardado") != "" {
+		t.Errorf("cabeçalhos repetidos = %v", repetida.Header())
+	}
+	if primeira.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("primeira execução marcada como repetida")
+	}
+}
+
+func TestIdempotenciaRecusaChaveReusadaEmOutraRequisicao(t *testing.T) {
+	rdb, _ := novoRedisTeste(t)
+	r, execucoes := novoRoteadorIdempotente(t, rdb, http.StatusCreated)
+
+	enviar(r, http.MethodPost, "/reservas", `{"notaId":"N1"}`, chave("k1"))
+	w := enviar(r, http.MethodPost, "/reservas", `{"notaId":"N2"}`, chave("k1"))
+
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "IDEMPOTENCY_KEY_REUSED") {
+		t.Errorf("resposta = %d %s, esperado 422 IDEMPOTENCY_KEY_REUSED", w.Code, w.Body)
+	}
+	if n := execucoes.Load(); n != 1 {
+		t.Errorf("execuções = %d, esperada 1", n)
+	}
+}
+
+func TestIdempotenciaSeparaChavesPorPrincipal(t *testing.T) {
+	rdb, _ := novoRedisTeste(t)
+	r, execucoes := novoRoteadorIdempotente(t, rdb, http.StatusCreated)
+
+	enviar(r, http.MethodPost, "/reservas", `{}`, map[string]string{CabecalhoIdempotencia: "k1", cabecalhoSujeito: "usuario-1"})
+	w := enviar(r, http.MethodPost, "/reservas", `{}`, map[string]string{CabecalhoIdempotencia: "k1", cabecalhoSujeito: "usuario-2"})
+
+	if n := execucoes.Load(); n != 2 || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("execuções = %d, replayed = %q; esperada uma execução por principal", n, w.Header().Get("Idempotent-Replayed"))
+	}
+}
+
+func TestIdempotenciaLiberaChaveDeRespostasNaoGuardadas(t *testing.T) {
+	for _, status := range []int{
+		http.StatusUnauthorized,
+		http.StatusForbidden,
+		http.StatusConflict,
+		http.StatusPreconditionFailed,
+		http.StatusPreconditionRequired,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+	} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			rdb, mr := novoRedisTeste(t)
+			r, execucoes := novoRoteadorIdempotente(t, rdb, status)
+
+			enviar(r, http.MethodPost, "/reservas", `{}`, chave("k1"))
+			if len(mr.Keys()) != 0 {
+				t.Errorf("chaves após %d = %v, esperada nenhuma", status, mr.Keys())
+			}
+			w := enviar(r, http.MethodPost, "/reservas", `{}`, chave("k1"))
+			if n := execucoes.Load(); n != 2 || w.Header().Get("Idempotent-Replayed") != "" {
+				t.Errorf("execuções = %d, replayed = %q; esperada nova execução", n, w.Header().Get("Idempotent-Replayed"))
+			}
+		})
+	}
+}
+
+func TestIdempotenciaRecusaCorpoMaiorQueOLimite(t *testing.T) {
+	rdb, mr := novoRedisTeste(t)
+	r, execucoes := novoRoteadorIdempotente(t, rdb, http.StatusCreated)
+
+	corpo := `{"notaId":"` + strings.Repeat("x", int(opcoesIdempotenciaTeste.CorpoMaximo)) + `"}`
+	w := enviar(r, http.MethodPost, "/reservas", corpo, chave("k1"))
+
+	if w.Code != http.StatusRequestEntityTooLarge || !strings.Contains(w.Body.String(), "PAYLOAD_TOO_LARGE") {
+		t.Errorf("resposta = %d %s, esperado 413 PAYLOAD_TOO_LARGE", w.Code, w.Body)
+	}
+	if n := execucoes.Load(); n != 0 || len(mr.Keys()) != 0 {
+		t.Errorf("execuções = %d, chaves = %v; esperado nada executado nem guardado", n, mr.Keys())
+	}
+}
+
+func TestIdempotenciaIgnoraRequisicoesSemChave(t *testing.T) {
+	rdb, mr := novoRedisTeste(t)
+	r, execucoes := novoRoteadorIdempotente(t, rdb, http.StatusCreated)
+	r.GET("/reservas", func(c *gin.Context) { execucoes.Add(1) })
+
+	enviar(r, http.MethodPost, "/reservas", `{}`, nil)
+	enviar(r, http.MethodPost, "/reservas", `{}`, nil)
+	enviar(r, http.MethodGet, "/reservas", "", chave("k1"))
+	enviar(r, http.MethodGet, "/reservas", "", chave("k1"))
+
+	if n := execucoes.Load(); n != 4 || len(mr.Keys()) != 0 {
+		t.Errorf("execuções = %d, chaves = %v; esperadas 4 execuções sem chaves", n, mr.Keys())
+	}
+
+	w := enviar(r, http.MethodPost, "/reservas", `{}`, chave(strings.Repeat("k", tamanhoMaximoChave+1)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("chave longa = %d, esperado 400", w.Code)
+	}
+}
+
+func TestIdempotenciaDuplicadaDuranteAExecucao(t *testing.T) {
+	rdb, mr := novoRedisTeste(t)
+	r, execucoes := novoRoteadorIdempotente(t, rdb, http.StatusCreated)
+
+	// Marcador de uma execução da mesma requisição que ainda não terminou
+	req := httptest.NewRequest(http.MethodPost, "/reservas", nil)
+	marcador, _ := json.Marshal(registroIdempotencia{
+		Estado:    estadoEmExecucao,
+		Token:     "outra-execucao",
+		Impressao: impressaoRequisicao(req, []byte(`{}`)),
+	})
+	mr.Set(tenant.Chave(context.Background(), "idempotencia:usuario-1:k1"), string(marcador))
+
+	w := enviar(r, http.MethodPost, "/reservas", `{}`, chave("k1"))
+	if w.Code != http.StatusConflict || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("resposta = %d, Retry-After = %q; esperado 409 com Retry-After", w.Code, w.Header().Get("Retry-After"))
+	}
+	if n := execucoes.Load(); n != 0 {
+		t.Errorf("execuções = %d, esperada nenhuma", n)
+	}
+}
@@ -0,0 +1,164 @@
+// internal/middleware/limite.go
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"servico-estoque/internal/domain"
+	"servico-estoque/internal/tenant"
+)
+
+// rotasMovimentacao e rotasMassa classificam as rotas pelo caminho
+// registrado no gin (c.FullPath)
+var (
+	rotasMovimentacao = []string{
+		"/api/produtos/reservar",
+		"/api/produtos/confirmar-reserva",
+		"/api/produtos/cancelar-reserva",
+		"/api/produtos/baixar",
+	}
+	rotasMassa = []string{
+		"/api/produtos/importar",
+		"/api/produtos/export",
+		"/api/produtos/export/movimentos",
+		"/api/relatorios/",
+	}
+)
+
+// janelaDeslizante registra a requisição em um sorted set com o instante de
+// cada chamada da janela e recusa quando já há limite chamadas nela. Retorna
+// {permitida, restantes, ms até liberar a próxima vaga}.
+var janelaDeslizante = redis.NewScript(`
+local agora = tonumber(ARGV[1])
+local janela = tonumber(ARGV[2])
+local limite = tonumber(ARGV[3])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", agora - janela)
+local usadas = redis.call("ZCARD", KEYS[1])
+local permitida = 0
+if usadas < limite then
+	redis.call("ZADD", KEYS[1], agora, ARGV[4])
+	redis.call("PEXPIRE", KEYS[1], janela)
+	usadas = usadas + 1
+	permitida = 1
+end
+local primeira = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+local libera = janela
+if primeira[2] then
+	libera = tonumber(primeira[2]) + janela - agora
+end
+return {permitida, limite - usadas, libera}
+`)
+
+// GrupoDaRota classifica a rota no grupo que define o seu limite
+func GrupoDaRota(metodo, rota string) string {
+	for _, r := range rotasMovimentacao {
+		if rota == r {
+			return domain.GrupoMovimentacao
+		}
+	}
+	for _, r := range rotasMassa {
+		if rota == r || strings.HasSuffix(r, "/") && strings.HasPrefix(rota, r) {
+			return domain.GrupoMassa
+		}
+	}
+	if metodo == http.MethodGet || metodo == http.MethodHead {
+		return domain.GrupoLeitura
+	}
+	return domain.GrupoEscrita
+}
+
+// LimiteOptions configura os limites de requisições
+type LimiteOptions struct {
+	// Janela é o período em que as requisições são contadas
+	Janela time.Duration
+	// Limites é o máximo de requisições por cliente na janela, por grupo de
+	// rotas (domain.Grupo*); grupos ausentes ou com zero não têm limite
+	Limites map[string]int
+	// Clientes substitui o limite do grupo para clientes específicos. A
+	// chave é "grupo:sujeito", com o sujeito do principal (o de uma chave de
+	// API é "chave-api:<id>").
+	Clientes map[string]int
+}
+
+// Limite restringe quantas requisições cada cliente (usuário ou chave de
+// API) faz por janela em cada grupo de rotas. A contagem fica no Redis e
+// vale para todas as réplicas.
+type Limite struct {
+	redis  *redis.Client
+	opts   LimiteOptions
+	logger *zap.Logger
+}
+
+func NewLimite(redis *redis.Client, opts LimiteOptions, logger *zap.Logger) *Limite {
+	return &Limite{
+		redis:  redis,
+		opts:   opts,
+		logger: logger,
+	}
+}
+
+// Handler retorna o middleware, que deve vir depois da autenticação e da
+// escolha da empresa. Toda resposta limitada traz os cabeçalhos
+// RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset e RateLimit-Policy;
+// acima do limite a resposta é 429 RATE_LIMITED com Retry-After. Se o Redis
+// falhar, a requisição segue sem limite.
+func (l *Limite) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := PrincipalDe(c)
+		if principal == nil {
+			naoAutenticado(c)
+			return
+		}
+
+		grupo := GrupoDaRota(c.Request.Method, c.FullPath())
+		limite, ok := l.opts.Clientes[grupo+":"+principal.Sujeito]
+		if !ok {
+			limite = l.opts.Limites[grupo]
+		}
+		if limite <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		redisKey := tenant.Chave(ctx, "limite:"+grupo+":"+principal.Sujeito)
+		resultado, err := janelaDeslizante.Run(ctx, l.redis, []string{redisKey},
+			time.Now().UnixMilli(), l.opts.Janela.Milliseconds(), limite, uuid.NewString()).Int64Slice()
+		if err != nil || len(resultado) != 3 {
+			l.logger.Warn("Erro ao verificar limite de requisições", zap.String("grupo", grupo), zap.Error(err))
+			c.Next()
+			return
+		}
+		permitida, restantes, libera := resultado[0] == 1, resultado[1], segundosAcima(resultado[2])
+
+		c.Header("RateLimit-Limit", strconv.Itoa(limite))
+		c.Header("RateLimit-Remaining", strconv.FormatInt(restantes, 10))
+		c.Header("RateLimit-Reset", strconv.FormatInt(libera, 10))
+		c.Header("RateLimit-Policy", strconv.Itoa(limite)+";w="+strconv.FormatInt(segundosAcima(l.opts.Janela.Milliseconds()), 10))
+		if !permitida {
+			l.logger.Info("Limite de requisições excedido",
+				zap.String("sujeito", principal.Sujeito), zap.String("grupo", grupo))
+			c.Header("Retry-After", strconv.FormatInt(libera, 10))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, domain.NewErrorResponse("RATE_LIMITED", domain.ErrLimiteExcedido.Error()))
+			return
+		}
+		c.Next()
+	}
+}
+
+// segundosAcima converte milissegundos em segundos arredondando para cima,
+// com o mínimo de 1: um cliente que espera o indicado sempre encontra vaga
+func segundosAcima(ms int64) int64 {
+	if ms <= 0 {
+		return 1
+	}
+	return (ms + 999) / 1000
+}
@@ -0,0 +1,152 @@
+// internal/middleware/limite_test.go
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"servico-estoque/internal/domain"
+)
+
+func TestGrupoDaRota(t *testing.T) {
+	casos := []struct {
+		metodo string
+		rota   string
+		grupo  string
+	}{
+		{http.MethodPost, "/api/produtos/reservar", domain.GrupoMovimentacao},
+		{http.MethodPost, "/api/produtos/baixar", domain.GrupoMovimentacao},
+		{http.MethodPost, "/api/produtos/importar", domain.GrupoMassa},
+		{http.MethodGet, "/api/produtos/export", domain.GrupoMassa},
+		{http.MethodGet, "/api/relatorios/valorizacao", domain.GrupoMassa},
+		{http.MethodGet, "/api/produtos", domain.GrupoLeitura},
+		{http.MethodHead, "/api/produtos/:id", domain.GrupoLeitura},
+		{http.MethodPost, "/api/produtos", domain.GrupoEscrita},
+		{http.MethodDelete, "/api/produtos/:id", domain.GrupoEscrita},
+		{http.MethodGet, "/api/relatorios", domain.GrupoLeitura},
+	}
+	for _, c := range casos {
+		if got := GrupoDaRota(c.metodo, c.rota); got != c.grupo {
+			t.Errorf("GrupoDaRota(%s, %s) = %s, esperado %s", c.metodo, c.rota, got, c.grupo)
+		}
+	}
+}
+
+func TestSegundosAcima(t *testing.T) {
+	casos := map[int64]int64{-5: 1, 0: 1, 1: 1, 999: 1, 1000: 1, 1001: 2, 60000: 60}
+	for ms, esperado := range casos {
+		if got := segundosAcima(ms); got != esperado {
+			t.Errorf("segundosAcima(%d) = %d, esperado %d", ms, got, esperado)
+		}
+	}
+}
+
+func TestJanelaDeslizante(t *testing.T) {
+	rdb, mr := novoRedisTeste(t)
+	ctx := context.Background()
+	const janela, limite = 1000, 2
+
+	// Cada passo é uma chamada no instante agora (ms)
+	passos := []struct {
+		agora     int64
+		permitida int64
+		restantes int64
+		libera    int64
+	}{
+		{10000, 1, 1, 1000},
+		{10400, 1, 0, 600},
+		{10500, 0, 0, 500},
+		{10999, 0, 0, 1},
+		// A chamada de 10000 sai da janela em 11000
+		{11000, 1, 0, 400},
+		{11300, 0, 0, 100},
+		// Passada uma janela inteira, todas as vagas voltam
+		{13000, 1, 1, 1000},
+	}
+	for i, p := range passos {
+		resultado, err := janelaDeslizante.Run(ctx, rdb, []string{"limite:teste"},
+			p.agora, janela, limite, i).Int64Slice()
+		if err != nil {
+			t.Fatal(err)
+		}
+		esperado := []int64{p.permitida, p.restantes, p.libera}
+		if len(resultado) != 3 || resultado[0] != esperado[0] || resultado[1] != esperado[1] || resultado[2] != esperado[2] {
+			t.Errorf("chamada em %d = %v, esperado %v", p.agora, resultado, esperado)
+		}
+	}
+	if ttl := mr.TTL("limite:teste"); ttl <= 0 || ttl > janela*time.Millisecond {
+		t.Errorf("TTL = %s, esperado até a janela", ttl)
+	}
+}
+
+func TestLimiteRecusaAcimaDoLimite(t *testing.T) {
+	rdb, _ := novoRedisTeste(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(autenticarTeste, NewLimite(rdb, LimiteOptions{
+		Janela:   time.Minute,
+		Limites:  map[string]int{domain.GrupoMovimentacao: 2, domain.GrupoLeitura: 0},
+		Clientes: map[string]int{domain.GrupoMovimentacao + ":integracao": 3},
+	}, zap.NewNop()).Handler())
+	r.POST("/api/produtos/reservar", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/api/produtos", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	reservar := func(sujeito string) *httptest.ResponseRecorder {
+		return enviar(r, http.MethodPost, "/api/produtos/reservar", `{}`, map[string]string{cabecalhoSujeito: sujeito})
+	}
+
+	for i, esperado := range []string{"1", "0"} {
+		w := reservar("usuario-1")
+		if w.Code != http.StatusOK || w.Header().Get("RateLimit-Remaining") != esperado {
+			t.Fatalf("chamada %d = %d, restantes %q; esperado 200 com %s", i+1, w.Code, w.Header().Get("RateLimit-Remaining"), esperado)
+		}
+	}
+	w := reservar("usuario-1")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("acima do limite = %d, esperado 429", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" || w.Header().Get("RateLimit-Policy") != "2;w=60" {
+		t.Errorf("cabeçalhos = %v", w.Header())
+	}
+
+	// Cada cliente tem a sua contagem, e o limite por cliente substitui o do grupo
+	for i := 0; i < 3; i++ {
+		if w := reservar("integracao"); w.Code != http.StatusOK {
+			t.Fatalf("cliente com limite próprio, chamada %d = %d", i+1, w.Code)
+		}
+	}
+	if w := reservar("integracao"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("cliente com limite próprio acima do limite = %d, esperado 429", w.Code)
+	}
+
+	// Grupo com limite zero não é limitado
+	for i := 0; i < 5; i++ {
+		if w := enviar(r, http.MethodGet, "/api/produtos", "", nil); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" {
+			t.Fatalf("leitura sem limite = %d, %v", w.Code, w.Header())
+		}
+	}
+}
+
+func TestLimiteSegueQuandoORedisFalha(t *testing.T) {
+	rdb, mr := novoRedisTeste(t)
+	mr.Close()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(autenticarTeste, NewLimite(rdb, LimiteOptions{
+		Janela:  time.Minute,
+		Limites: map[string]int{domain.GrupoEscrita: 1},
+	}, zap.NewNop()).Handler())
+	r.POST("/api/produtos", func(c *gin.Context) { c.Status(http.StatusCreated) })
+
+	for i := 0; i < 2; i++ {
+		if w := enviar(r, http.MethodPost, "/api/produtos", `{}`, nil); w.Code != http.StatusCreated {
+			t.Fatalf("chamada %d sem Redis = %d, esperado 201", i+1, w.Code)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+// internal/middleware/metricas.go
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"servico-estoque/internal/metricas"
+)
+
+// rotaDesconhecida agrupa as requisições que não casam com nenhuma rota,
+// para que caminhos arbitrários não criem séries novas
+const rotaDesconhecida = "desconhecida"
+
+// Metricas mede a duração de cada requisição por método, rota (o padrão
+// registrado, como /api/produtos/:id) e status
+func Metricas() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		inicio := time.Now()
+		c.Next()
+
+		rota := c.FullPath()
+		if rota == "" {
+			rota = rotaDesconhecida
+		}
+		metricas.DuracaoHTTP.
+			WithLabelValues(c.Request.Method, rota, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(inicio).Seconds())
+	}
+}
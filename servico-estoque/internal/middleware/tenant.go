@@ -0,0 +1,81 @@
+// internal/middleware/tenant.go
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"servico-estoque/internal/domain"
+	"servico-estoque/internal/tenant"
+)
+
+// CabecalhoTenant escolhe a empresa (CNPJ) de quem tem acesso a várias
+const CabecalhoTenant = "X-Tenant-ID"
+
+// PreparadorTenant prepara uma empresa antes do primeiro uso (depósito
+// padrão, índices em cache etc.). Deve ser idempotente e barato depois da
+// primeira chamada.
+type PreparadorTenant interface {
+	PrepararTenant(ctx context.Context) error
+}
+
+// Tenant resolve a empresa da requisição a partir do principal autenticado
+// e do cabeçalho X-Tenant-ID, e a coloca no contexto da requisição
+type Tenant struct {
+	preparador PreparadorTenant
+	logger     *zap.Logger
+}
+
+func NewTenant(preparador PreparadorTenant, logger *zap.Logger) *Tenant {
+	return &Tenant{
+		preparador: preparador,
+		logger:     logger,
+	}
+}
+
+// Handler retorna o middleware, que deve vir depois da autenticação. Um CNPJ
+// inválido no cabeçalho recebe 400 INVALID_TENANT; uma empresa fora das do
+// principal, 403 OPERATION_NOT_ALLOWED; e quem tem várias sem informar o
+// cabeçalho, 400 TENANT_REQUIRED.
+func (t *Tenant) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := PrincipalDe(c)
+		if principal == nil {
+			naoAutenticado(c)
+			return
+		}
+
+		var pedido string
+		if v := c.GetHeader(CabecalhoTenant); v != "" {
+			cnpj, err := domain.NormalizarCNPJ(v)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, domain.NewErrorResponse("INVALID_TENANT", err.Error()))
+				return
+			}
+			pedido = cnpj
+		}
+
+		id, err := principal.Tenant(pedido)
+		switch err {
+		case nil:
+		case domain.ErrTenantObrigatorio:
+			c.AbortWithStatusJSON(http.StatusBadRequest, domain.NewErrorResponse("TENANT_REQUIRED", err.Error()))
+			return
+		default:
+			c.AbortWithStatusJSON(http.StatusForbidden, domain.NewErrorResponse("OPERATION_NOT_ALLOWED", err.Error()))
+			return
+		}
+
+		ctx := tenant.Com(c.Request.Context(), id)
+		if err := t.preparador.PrepararTenant(ctx); err != nil {
+			t.logger.Error("Erro ao preparar empresa", zap.String("tenant", id), zap.Error(err))
+			c.AbortWithStatusJSON(http.StatusInternalServerError, domain.NewErrorResponse("INTERNAL_ERROR", "Erro interno do servidor"))
+			return
+		}
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -0,0 +1,256 @@
+// internal/repository/baixa_repository.go
+package repository
+
+import (
+    "context"
+    "time"
+
+    "servico-estoque/internal/domain"
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+)
+
+// BaixarEstoque baixa todos os itens de forma atômica e registra a baixa com
+// o que saiu de cada depósito, lote e série. O registro é gravado antes de
+// tudo: se já existir uma baixa com o mesmo ID, nada é movimentado e ela é
+// retornada com repetida=true (uma repetição concorrente espera no índice
+// único até a original terminar). Itens sem depósito são alocados pela
+// estratégia configurada; lotes vencidos, quantidades reservadas em lotes e
+// séries reservadas não saem em baixa direta.
+func (r *produtoRepository) BaixarEstoque(ctx context.Context, baixa *domain.BaixaEstoque, itens []domain.ItemReserva) (*domain.BaixaEstoque, bool, error) {
+    ids, grupos := agruparItens(itens)
+
+    var existente *domain.BaixaEstoque
+    err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+        baixa.Status = domain.StatusBaixaEfetivada
+        res := tx.Omit(clause.Associations).Clauses(clause.OnConflict{DoNothing: true}).Create(baixa)
+        if res.Error != nil {
+            return res.Error
+        }
+        if res.RowsAffected == 0 {
+            var err error
+            existente, err = buscarBaixa(tx, baixa.ID)
+            return err
+        }
+
+        estoque, err := travarEstoque(tx, ids)
+        if err != nil {
+            return err
+        }
+
+        agora := time.Now()
+        var falhas []domain.ItemIndisponivel
+        alocados := make([]*domain.SaldoDeposito, len(grupos))
+        lotes := make([][]domain.ReservaLote, len(grupos))
+        series := make([][]string, len(grupos))
+        for i, g := range grupos {
+            var saldo *domain.SaldoDeposito
+            var falha *domain.ItemIndisponivel
+            switch {
+            case estoque.controlaSerie(g.produtoID):
+                var separadas []domain.NumeroSerie
+                saldo, separadas, falha, err = r.separarSeries(tx, estoque, g)
+                if err != nil {
+                    return err
+                }
+                if falha == nil {
+                    if err := marcarSeries(tx, separadas, domain.SerieVendida, nil, baixa.NotaFiscalID, "baixa direta"); err != nil {
+                        return err
+                    }
+                    series[i] = numerosDe(separadas)
+                }
+            case len(g.series) > 0:
+                falha = novaFalha(g, domain.ErrProdutoSemSerie.Error(), g.depositoID, decimal.Zero)
+            case estoque.controlaLote(g.produtoID):
+                saldo, falha = r.alocar(estoque, g, func(s *domain.SaldoDeposito) decimal.Decimal { return estoque.disponivelLotes(s, agora) })
+                if falha != nil && estoque.apenasVencidos(falha, agora) {
+                    falha.Motivo = domain.ErrLoteVencido.Error()
+                }
+                if falha == nil {
+                    lotes[i], err = estoque.consumirLotes(tx, saldo, g.quantidade, agora, func(l *domain.Lote, q decimal.Decimal) {
+                        l.Saldo = l.Saldo.Sub(q)
+                    })
+                    if err != nil {
+                        return err
+                    }
+                }
+            default:
+                saldo, falha = r.alocar(estoque, g, func(s *domain.SaldoDeposito) decimal.Decimal { return s.Saldo })
+            }
+            if falha != nil {
+                falhas = append(falhas, *falha)
+                continue
+            }
+            // Desconta já em memória para que os próximos itens do mesmo
+            // produto enxerguem o saldo restante
+            saldo.Saldo = saldo.Saldo.Sub(g.quantidade)
+            alocados[i] = saldo
+        }
+        if len(falhas) > 0 {
+            return &domain.ReservaItensError{Itens: falhas}
+        }
+
+        for i, g := range grupos {
+            saldo := alocados[i]
+            p := estoque.produtos[g.produtoID]
+            saldoAnterior := p.Saldo
+            p.Saldo = p.Saldo.Sub(g.quantidade)
+            if err := tx.Omit(clause.Associations).Save(p).Error; err != nil {
+                return err
+            }
+            if err := tx.Save(saldo).Error; err != nil {
+                return err
+            }
+
+            item := domain.ItemBaixa{
+                ID:            uuid.New(),
+                BaixaID:       baixa.ID,
+                ProdutoID:     g.produtoID,
+                DepositoID:    saldo.DepositoID,
+                Quantidade:    g.quantidade,
+                CustoUnitario: p.CustoMedio,
+                Lotes:         domain.LotesBaixados(lotes[i]),
+                Series:        series[i],
+            }
+            if err := tx.Create(&item).Error; err != nil {
+                return err
+            }
+            if err := registrarMovimento(tx, p, saldoAnterior, domain.MovimentoEstoque{
+                Tipo:         domain.MovimentoSaidaNota,
+                Quantidade:   g.quantidade,
+                DepositoID:   &saldo.DepositoID,
+                NotaFiscalID: baixa.NotaFiscalID,
+                Motivo:       "baixa direta",
+            }); err != nil {
+                return err
+            }
+            baixa.Itens = append(baixa.Itens, item)
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, false, err
+    }
+    if existente != nil {
+        return existente, true, nil
+    }
+    return baixa, false, nil
+}
+
+// EstornarBaixa devolve ao estoque tudo o que a baixa tirou: o saldo de
+// cada depósito ao custo em que saiu, os lotes consumidos e as séries
+// vendidas, registrando um movimento de estorno por item. Estornar uma baixa
+// já estornada não movimenta nada e retorna a baixa como está.
+func (r *produtoRepository) EstornarBaixa(ctx context.Context, id uuid.UUID, motivo string) (*domain.BaixaEstoque, error) {
+    var baixa *domain.BaixaEstoque
+    err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+        var err error
+        baixa, err = buscarBaixa(tx.Clauses(clause.Locking{Strength: "UPDATE"}), id)
+        if err != nil {
+            return err
+        }
+        if baixa.Status == domain.StatusBaixaEstornada {
+            return nil
+        }
+
+        var ids []uuid.UUID
+        vistos := map[uuid.UUID]bool{}
+        for _, item := range baixa.Itens {
+            if !vistos[item.ProdutoID] {
+                vistos[item.ProdutoID] = true
+                ids = append(ids, item.ProdutoID)
+            }
+        }
+        estoque, err := travarEstoque(tx, ids)
+        if err != nil {
+            return err
+        }
+
+        for _, item := range baixa.Itens {
+            p, ok := estoque.produtos[item.ProdutoID]
+            if !ok {
+                return domain.ErrProdutoNaoEncontrado
+            }
+            var saldo *domain.SaldoDeposito
+            for _, s := range estoque.saldos[item.ProdutoID] {
+                if s.DepositoID == item.DepositoID {
+                    saldo = s
+                }
+            }
+            if saldo == nil {
+                return domain.ErrDepositoNaoEncontrado
+            }
+
+            saldoAnterior := p.Saldo
+            p.EntradaComCusto(item.Quantidade, item.CustoUnitario)
+            p.Saldo = p.Saldo.Add(item.Quantidade)
+            saldo.Saldo = saldo.Saldo.Add(item.Quantidade)
+            if err := tx.Omit(clause.Associations).Save(p).Error; err != nil {
+                return err
+            }
+            if err := tx.Save(saldo).Error; err != nil {
+                return err
+            }
+
+            for _, l := range item.Lotes {
+                if err := tx.Model(&domain.Lote{}).Where("id = ?", l.LoteID).
+                    Update("saldo", gorm.Expr("saldo + ?", l.Quantidade)).Error; err != nil {
+                    return err
+                }
+            }
+            if len(item.Series) > 0 {
+                var series []domain.NumeroSerie
+                if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+                    Where("produto_id = ? AND numero IN ?", item.ProdutoID, item.Series).
+                    Find(&series).Error; err != nil {
+                    return err
+                }
+                for i := range series {
+                    series[i].NotaFiscalID = nil
+                }
+                if err := marcarSeries(tx, series, domain.SerieDisponivel, nil, baixa.NotaFiscalID, "estorno de baixa"); err != nil {
+                    return err
+                }
+            }
+
+            if err := registrarMovimento(tx, p, saldoAnterior, domain.MovimentoEstoque{
+                Tipo:          domain.MovimentoEstorno,
+                Quantidade:    item.Quantidade,
+                CustoUnitario: item.CustoUnitario,
+                DepositoID:    &item.DepositoID,
+                NotaFiscalID:  baixa.NotaFiscalID,
+                Motivo:        motivo,
+            }); err != nil {
+                return err
+            }
+        }
+
+        agora := time.Now()
+        baixa.Status = domain.StatusBaixaEstornada
+        baixa.EstornadaEm = &agora
+        baixa.MotivoEstorno = motivo
+        return tx.Omit(clause.Associations).Save(baixa).Error
+    })
+    if err != nil {
+        return nil, err
+    }
+    return baixa, nil
+}
+
+// FindBaixa retorna uma baixa com seus itens
+func (r *produtoRepository) FindBaixa(ctx context.Context, id uuid.UUID) (*domain.BaixaEstoque, error) {
+    return buscarBaixa(r.db.WithContext(ctx), id)
+}
+
+func buscarBaixa(tx *gorm.DB, id uuid.UUID) (*domain.BaixaEstoque, error) {
+    var baixa domain.BaixaEstoque
+    if err := tx.Preload("Itens.Lotes").First(&baixa, "id = ?", id).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            return nil, domain.ErrBaixaNaoEncontrada
+        }
+        return nil, err
+    }
+    return &baixa, nil
+}
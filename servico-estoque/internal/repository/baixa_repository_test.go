@@ -0,0 +1,88 @@
+// internal/repository/baixa_repository_test.go
+package repository
+
+import (
+    "errors"
+    "testing"
+
+    "servico-estoque/internal/domain"
+    "github.com/google/uuid"
+)
+
+func TestBaixarEstoqueRepetidaNaoMovimentaDeNovo(t *testing.T) {
+    db, ctx := abrirBanco(t)
+    repo := novoRepositorio(t, db)
+    p := criarProduto(t, ctx, repo, "P1", 10)
+    operacao := uuid.New()
+    itens := []domain.ItemReserva{{ProdutoID: p.ID, Quantidade: dec(t, "4")}}
+
+    baixa, repetida, err := repo.BaixarEstoque(ctx, &domain.BaixaEstoque{ID: operacao, Impressao: "i1"}, itens)
+    if err != nil || repetida {
+        t.Fatalf("primeira baixa: repetida = %v, erro = %v", repetida, err)
+    }
+    if baixa.Status != domain.StatusBaixaEfetivada || len(baixa.Itens) != 1 {
+        t.Errorf("baixa = %+v", baixa)
+    }
+
+    original, repetida, err := repo.BaixarEstoque(ctx, &domain.BaixaEstoque{ID: operacao, Impressao: "i2"}, itens)
+    if err != nil || !repetida {
+        t.Fatalf("repetição: repetida = %v, erro = %v", repetida, err)
+    }
+    if original.Impressao != "i1" || len(original.Itens) != 1 {
+        t.Errorf("repetição devolveu %+v, esperada a baixa original", original)
+    }
+    if lido, _ := repo.FindByID(ctx, p.ID); !lido.Saldo.Equal(dec(t, "6")) {
+        t.Errorf("saldo = %s, esperado 6", lido.Saldo)
+    }
+}
+
+func TestBaixarEstoqueSemSaldoNaoRegistraABaixa(t *testing.T) {
+    db, ctx := abrirBanco(t)
+    repo := novoRepositorio(t, db)
+    p1 := criarProduto(t, ctx, repo, "P1", 10)
+    p2 := criarProduto(t, ctx, repo, "P2", 1)
+    operacao := uuid.New()
+
+    _, _, err := repo.BaixarEstoque(ctx, &domain.BaixaEstoque{ID: operacao, Impressao: "i1"}, []domain.ItemReserva{
+        {ProdutoID: p1.ID, Quantidade: dec(t, "2")},
+        {ProdutoID: p2.ID, Quantidade: dec(t, "2")},
+    })
+    if !errors.Is(err, domain.ErrEstoqueInsuficiente) {
+        t.Fatalf("erro = %v, esperado ErrEstoqueInsuficiente", err)
+    }
+    if _, err := repo.FindBaixa(ctx, operacao); !errors.Is(err, domain.ErrBaixaNaoEncontrada) {
+        t.Errorf("FindBaixa = %v, esperado ErrBaixaNaoEncontrada", err)
+    }
+    if lido, _ := repo.FindByID(ctx, p1.ID); !lido.Saldo.Equal(dec(t, "10")) {
+        t.Errorf("P1 saldo = %s após baixa recusada, esperado 10", lido.Saldo)
+    }
+}
+
+func TestEstornarBaixaDevolveOSaldoUmaVez(t *testing.T) {
+    db, ctx := abrirBanco(t)
+    repo := novoRepositorio(t, db)
+    p := criarProduto(t, ctx, repo, "P1", 10)
+    operacao := uuid.New()
+
+    if _, _, err := repo.BaixarEstoque(ctx, &domain.BaixaEstoque{ID: operacao, Impressao: "i1"},
+        []domain.ItemReserva{{ProdutoID: p.ID, Quantidade: dec(t, "4")}}); err != nil {
+        t.Fatal(err)
+    }
+
+    for i := 0; i < 2; i++ {
+        baixa, err := repo.EstornarBaixa(ctx, operacao, "devolução")
+        if err != nil {
+            t.Fatalf("estorno %d: %v", i+1, err)
+        }
+        if baixa.Status != domain.StatusBaixaEstornada || baixa.MotivoEstorno != "devolução" {
+            t.Errorf("estorno %d: status %s, motivo %q", i+1, baixa.Status, baixa.MotivoEstorno)
+        }
+        if lido, _ := repo.FindByID(ctx, p.ID); !lido.Saldo.Equal(dec(t, "10")) {
+            t.Errorf("estorno %d: saldo = %s, esperado 10", i+1, lido.Saldo)
+        }
+    }
+
+    if _, err := repo.EstornarBaixa(ctx, uuid.New(), ""); !errors.Is(err, domain.ErrBaixaNaoEncontrada) {
+        t.Errorf("estornar baixa inexistente = %v, esperado ErrBaixaNaoEncontrada", err)
+    }
+}
@@ -0,0 +1,96 @@
+// internal/repository/banco_test.go
+package repository
+
+import (
+    "context"
+    "fmt"
+    "math/rand"
+    "os"
+    "testing"
+
+    "servico-estoque/internal/domain"
+    "servico-estoque/internal/tenant"
+    "github.com/shopspring/decimal"
+    "gorm.io/driver/postgres"
+    "gorm.io/gorm"
+    "gorm.io/gorm/logger"
+)
+
+// abrirBanco conecta ao PostgreSQL de TEST_DATABASE_URL, migra o esquema e
+// devolve um contexto de uma empresa nova, com depósito padrão, para que
+// cada teste enxergue só os próprios dados. Sem a variável o teste é pulado.
+func abrirBanco(t *testing.T) (*gorm.DB, context.Context) {
+    t.Helper()
+    dsn := os.Getenv("TEST_DATABASE_URL")
+    if dsn == "" {
+        t.Skip("TEST_DATABASE_URL não definida")
+    }
+
+    db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+    if err != nil {
+        t.Fatalf("conectar ao banco: %v", err)
+    }
+    if err := db.Use(IsolamentoTenant{}); err != nil {
+        t.Fatalf("configurar isolamento: %v", err)
+    }
+    if err := db.WithContext(tenant.Sistema(context.Background())).AutoMigrate(modelosTenant...); err != nil {
+        t.Fatalf("migrar banco: %v", err)
+    }
+    if err := MigrarTenants(context.Background(), db, ""); err != nil {
+        t.Fatalf("migrar empresas: %v", err)
+    }
+    t.Cleanup(func() {
+        if sqlDB, err := db.DB(); err == nil {
+            sqlDB.Close()
+        }
+    })
+
+    return db, novaEmpresa(t, db)
+}
+
+// novaEmpresa devolve o contexto de uma empresa nova, já com o depósito
+// padrão
+func novaEmpresa(t *testing.T, db *gorm.DB) context.Context {
+    t.Helper()
+    ctx := tenant.Com(context.Background(), fmt.Sprintf("%014d", rand.Int63n(1e14)))
+    if err := NewDepositoRepository(db).Inicializar(ctx); err != nil {
+        t.Fatalf("inicializar empresa: %v", err)
+    }
+    return ctx
+}
+
+// novoRepositorio cria o repositório de produtos com a alocação pelo
+// depósito padrão
+func novoRepositorio(t *testing.T, db *gorm.DB) ProdutoRepository {
+    t.Helper()
+    alocacao, err := domain.NovaEstrategiaAlocacao(domain.AlocacaoPadrao, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return NewProdutoRepository(db, alocacao)
+}
+
+// criarProduto grava um produto com o saldo inicial no depósito padrão
+func criarProduto(t *testing.T, ctx context.Context, repo ProdutoRepository, codigo string, saldo int64) *domain.Produto {
+    t.Helper()
+    p := &domain.Produto{
+        Codigo:    codigo,
+        Descricao: "Produto " + codigo,
+        Saldo:     decimal.NewFromInt(saldo),
+        Unidade:   domain.UnidadePadrao,
+    }
+    if err := repo.Create(ctx, p, nil); err != nil {
+        t.Fatalf("criar produto %s: %v", codigo, err)
+    }
+    return p
+}
+
+// dec converte o texto em decimal, para comparar quantidades nos testes
+func dec(t *testing.T, v string) decimal.Decimal {
+    t.Helper()
+    d, err := decimal.NewFromString(v)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return d
+}
@@ -0,0 +1,162 @@
+// internal/repository/busca_repository.go
+package repository
+
+import (
+    "context"
+
+    "servico-estoque/internal/domain"
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+)
+
+// documentoBusca é a expressão indexada da busca textual. Precisa ser
+// idêntica no índice e nas consultas para que o índice seja usado.
+const documentoBusca = `to_tsvector('portuguese_unaccent', codigo || ' ' || descricao)`
+
+// ddlBusca prepara a busca: unaccent e pg_trgm, a configuração
+// portuguese_unaccent (stemming do português sem acentos), uma versão
+// IMMUTABLE de unaccent para indexar e os índices de texto e de trigramas
+var ddlBusca = []string{
+    `CREATE EXTENSION IF NOT EXISTS unaccent`,
+    `CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+    `DO $$
+    BEGIN
+        IF NOT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = 'portuguese_unaccent') THEN
+            CREATE TEXT SEARCH CONFIGURATION portuguese_unaccent (COPY = portuguese);
+            ALTER TEXT SEARCH CONFIGURATION portuguese_unaccent
+                ALTER MAPPING FOR hword, hword_part, word WITH unaccent, portuguese_stem;
+        END IF;
+    END
+    $$`,
+    `CREATE OR REPLACE FUNCTION f_unaccent(text) RETURNS text
+        LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT
+        AS $$ SELECT public.unaccent('public.unaccent', $1) $$`,
+    `CREATE INDEX IF NOT EXISTS idx_produtos_busca ON produtos USING GIN (` + documentoBusca + `)`,
+    `CREATE INDEX IF NOT EXISTS idx_produtos_descricao_trgm ON produtos USING GIN (f_unaccent(lower(descricao)) gin_trgm_ops)`,
+}
+
+// lockDDLBusca é a chave do advisory lock que serializa ddlBusca entre
+// instâncias que sobem ao mesmo tempo: sem ele, CREATE OR REPLACE FUNCTION e
+// CREATE TEXT SEARCH CONFIGURATION concorrentes falham com "tuple
+// concurrently updated" ou chave duplicada no catálogo
+const lockDDLBusca int64 = 0x65737471_62757363 // "estqbusc"
+
+// encontradosBusca seleciona os produtos da empresa que atendem ao termo:
+// código ou GTIN idênticos, texto (com stemming e sem acentos) ou descrição parecida
+// (trigramas, que toleram erros de digitação)
+const encontradosBusca = `
+WITH termo AS (
+    SELECT websearch_to_tsquery('portuguese_unaccent', @termo) AS consulta,
+           f_unaccent(lower(@termo)) AS normalizado
+), encontrados AS (
+    SELECT p.id, p.codigo, p.descricao,
+           (lower(p.codigo) = lower(@termo) OR p.gtin = @termo) AS exato,
+           ts_rank(` + documentoBusca + `, t.consulta) AS texto,
+           similarity(f_unaccent(lower(p.descricao)), t.normalizado) AS similaridade,
+           t.consulta
+    FROM produtos p, termo t
+    WHERE p.tenant_id = @tenant
+      AND (lower(p.codigo) = lower(@termo)
+       OR p.gtin = @termo
+       OR ` + documentoBusca + ` @@ t.consulta
+       OR f_unaccent(lower(p.descricao)) % t.normalizado)
+)`
+
+// descricaoEscapada é a descrição com os caracteres especiais do HTML
+// trocados por entidades, que o parser de texto não confunde com palavras:
+// assim o destaque só traz as tags <mark> acrescentadas pelo ts_headline
+const descricaoEscapada = `replace(replace(replace(replace(replace(descricao,
+    '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;')`
+
+// consultaBusca pagina os encontrados: os idênticos primeiro, depois em
+// ordem de relevância
+const consultaBusca = encontradosBusca + `
+SELECT id, exato, texto + similaridade AS relevancia,
+       ts_headline('portuguese_unaccent', ` + descricaoEscapada + `, consulta,
+                   'StartSel=<mark>, StopSel=</mark>, HighlightAll=true') AS destaque
+FROM encontrados
+ORDER BY exato DESC, relevancia DESC, codigo, id
+LIMIT @limite OFFSET @deslocamento`
+
+const contagemBusca = encontradosBusca + `
+SELECT COUNT(*) FROM encontrados`
+
+type linhaBusca struct {
+    ID         uuid.UUID
+    Exato      bool
+    Relevancia float64
+    Destaque   string
+}
+
+// Search busca produtos por código, GTIN ou texto da descrição, em ordem de
+// relevância, e retorna também o total de encontrados
+func (r *produtoRepository) Search(ctx context.Context, filtro domain.FiltroBusca) ([]domain.ResultadoBusca, int64, error) {
+    empresa, err := tenantDe(ctx)
+    if err != nil {
+        return nil, 0, err
+    }
+
+    var total int64
+    if err := r.db.WithContext(ctx).Raw(contagemBusca, map[string]any{"termo": filtro.Termo, "tenant": empresa}).
+        Scan(&total).Error; err != nil {
+        return nil, 0, err
+    }
+
+    var linhas []linhaBusca
+    if err := r.db.WithContext(ctx).Raw(consultaBusca, map[string]any{
+        "termo":        filtro.Termo,
+        "tenant":       empresa,
+        "limite":       filtro.Size,
+        "deslocamento": (filtro.Page - 1) * filtro.Size,
+    }).Scan(&linhas).Error; err != nil {
+        return nil, 0, err
+    }
+    if len(linhas) == 0 {
+        return nil, total, nil
+    }
+
+    ids := make([]uuid.UUID, len(linhas))
+    for i, l := range linhas {
+        ids[i] = l.ID
+    }
+    var produtos []domain.Produto
+    if err := comSaldos(r.db.WithContext(ctx)).Where("id IN ?", ids).Find(&produtos).Error; err != nil {
+        return nil, 0, err
+    }
+    porID := make(map[uuid.UUID]domain.Produto, len(produtos))
+    for _, p := range produtos {
+        porID[p.ID] = p
+    }
+
+    resultados := make([]domain.ResultadoBusca, 0, len(linhas))
+    for _, l := range linhas {
+        p, ok := porID[l.ID]
+        if !ok {
+            continue
+        }
+        resultados = append(resultados, domain.ResultadoBusca{
+            Produto:    p,
+            Exato:      l.Exato,
+            Relevancia: l.Relevancia,
+            Destaque:   l.Destaque,
+        })
+    }
+    return resultados, total, nil
+}
+
+// Inicializar prepara as extensões e índices usados pela busca textual. A
+// transação segura um advisory lock até o commit, então só uma instância
+// executa o DDL por vez e as demais o encontram pronto.
+func (r *produtoRepository) Inicializar(ctx context.Context) error {
+    return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+        if err := tx.Exec(`SELECT pg_advisory_xact_lock(?)`, lockDDLBusca).Error; err != nil {
+            return err
+        }
+        for _, ddl := range ddlBusca {
+            if err := tx.Exec(ddl).Error; err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+}
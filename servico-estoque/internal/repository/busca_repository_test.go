@@ -0,0 +1,63 @@
+// internal/repository/busca_repository_test.go
+package repository
+
+import (
+    "strings"
+    "sync"
+    "testing"
+
+    "servico-estoque/internal/domain"
+)
+
+func TestSearchEscapaDescricaoNoDestaque(t *testing.T) {
+    db, ctx := abrirBanco(t)
+    repo := novoRepositorio(t, db)
+    if err := repo.Inicializar(ctx); err != nil {
+        t.Fatalf("Inicializar: %v", err)
+    }
+    p := criarProduto(t, ctx, repo, "P1", 1)
+    p.Descricao = `Cabo <script>alert("x")</script> & conector`
+    if err := db.WithContext(ctx).Model(p).Update("descricao", p.Descricao).Error; err != nil {
+        t.Fatal(err)
+    }
+
+    resultados, _, err := repo.Search(ctx, domain.FiltroBusca{Termo: "cabo", Page: 1, Size: 10})
+    if err != nil {
+        t.Fatalf("Search: %v", err)
+    }
+    if len(resultados) != 1 {
+        t.Fatalf("encontrados %d produtos, esperado 1", len(resultados))
+    }
+    destaque := resultados[0].Destaque
+    for _, trecho := range []string{"<mark>Cabo</mark>", "&lt;script&gt;", "&quot;x&quot;", "&amp; conector"} {
+        if !strings.Contains(destaque, trecho) {
+            t.Errorf("destaque %q não contém %q", destaque, trecho)
+        }
+    }
+    if strings.Contains(destaque, "<script>") {
+        t.Errorf("destaque contém HTML da descrição: %q", destaque)
+    }
+}
+
+func TestInicializarConcorrente(t *testing.T) {
+    db, ctx := abrirBanco(t)
+    repo := novoRepositorio(t, db)
+
+    // Várias instâncias subindo juntas não podem falhar no DDL
+    var wg sync.WaitGroup
+    erros := make(chan error, 5)
+    for i := 0; i < 5; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            erros <- repo.Inicializar(ctx)
+        }()
+    }
+    wg.Wait()
+    close(erros)
+    for err := range erros {
+        if err != nil {
+            t.Errorf("Inicializar: %v", err)
+        }
+    }
+}
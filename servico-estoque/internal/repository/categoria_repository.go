@@ -0,0 +1,85 @@
+// internal/repository/categoria_repository.go
+package repository
+
+import (
+    "context"
+
+    "servico-estoque/internal/domain"
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+)
+
+// subarvoreCategoria seleciona o ID da categoria informada e de todas as
+// suas descendentes
+const subarvoreCategoria = `
+WITH RECURSIVE subarvore AS (
+    SELECT id FROM categorias WHERE id = ?
+    UNION
+    SELECT c.id FROM categorias c JOIN subarvore s ON c.pai_id = s.id
+)
+SELECT id FROM subarvore`
+
+type CategoriaRepository interface {
+    FindAll(ctx context.Context) ([]domain.Categoria, error)
+    FindByID(ctx context.Context, id uuid.UUID) (*domain.Categoria, error)
+    FindByCodigo(ctx context.Context, codigo string) (*domain.Categoria, error)
+    Create(ctx context.Context, c *domain.Categoria) error
+
+    // Totais soma produtos e estoque diretamente ligados a cada categoria
+    Totais(ctx context.Context) ([]domain.TotaisCategoria, error)
+}
+
+type categoriaRepository struct {
+    db *gorm.DB
+}
+
+func NewCategoriaRepository(db *gorm.DB) CategoriaRepository {
+    return &categoriaRepository{db: db}
+}
+
+func (r *categoriaRepository) FindAll(ctx context.Context) ([]domain.Categoria, error) {
+    var categorias []domain.Categoria
+    if err := r.db.WithContext(ctx).Order("codigo").Find(&categorias).Error; err != nil {
+        return nil, err
+    }
+    return categorias, nil
+}
+
+func (r *categoriaRepository) FindByID(ctx context.Context, id uuid.UUID) (*domain.Categoria, error) {
+    var c domain.Categoria
+    if err := r.db.WithContext(ctx).First(&c, "id = ?", id).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            return nil, domain.ErrCategoriaNaoEncontrada
+        }
+        return nil, err
+    }
+    return &c, nil
+}
+
+func (r *categoriaRepository) FindByCodigo(ctx context.Context, codigo string) (*domain.Categoria, error) {
+    var c domain.Categoria
+    if err := r.db.WithContext(ctx).First(&c, "codigo = ?", codigo).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            return nil, nil
+        }
+        return nil, err
+    }
+    return &c, nil
+}
+
+func (r *categoriaRepository) Create(ctx context.Context, c *domain.Categoria) error {
+    return r.db.WithContext(ctx).Create(c).Error
+}
+
+func (r *categoriaRepository) Totais(ctx context.Context) ([]domain.TotaisCategoria, error) {
+    var totais []domain.TotaisCategoria
+    if err := r.db.WithContext(ctx).Model(&domain.Produto{}).
+        Select("categoria_id, COUNT(*) AS produtos, SUM(saldo) AS saldo, " +
+            "SUM(reservado) AS reservado, SUM(saldo - reservado) AS disponivel").
+        Where("categoria_id IS NOT NULL").
+        Group("categoria_id").
+        Scan(&totais).Error; err != nil {
+        return nil, err
+    }
+    return totais, nil
+}
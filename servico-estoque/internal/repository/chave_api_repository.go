@@ -0,0 +1,120 @@
+// internal/repository/chave_api_repository.go
+package repository
+
+import (
+    "context"
+    "time"
+
+    "servico-estoque/internal/domain"
+    "servico-estoque/internal/tenant"
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+)
+
+type ChaveAPIRepository interface {
+    FindAll(ctx context.Context) ([]domain.ChaveAPI, error)
+    FindByID(ctx context.Context, id uuid.UUID) (*domain.ChaveAPI, error)
+    // FindByHash procura a chave em todas as empresas: é por ela que se
+    // descobre a empresa de uma requisição autenticada por chave
+    FindByHash(ctx context.Context, hash string) (*domain.ChaveAPI, error)
+    Create(ctx context.Context, c *domain.ChaveAPI) error
+
+    // Rotacionar troca o prefixo e o hash da chave e retorna o hash antigo
+    Rotacionar(ctx context.Context, id uuid.UUID, prefixo, hash string) (string, *domain.ChaveAPI, error)
+    // Revogar marca a chave como revogada; revogar de novo não altera nada
+    Revogar(ctx context.Context, id uuid.UUID) (*domain.ChaveAPI, error)
+    // RegistrarUso grava o instante do último uso, em qualquer empresa
+    RegistrarUso(ctx context.Context, id uuid.UUID, em time.Time) error
+}
+
+type chaveAPIRepository struct {
+    db *gorm.DB
+}
+
+func NewChaveAPIRepository(db *gorm.DB) ChaveAPIRepository {
+    return &chaveAPIRepository{db: db}
+}
+
+func (r *chaveAPIRepository) FindAll(ctx context.Context) ([]domain.ChaveAPI, error) {
+    var chaves []domain.ChaveAPI
+    if err := r.db.WithContext(ctx).Order("created_at").Find(&chaves).Error; err != nil {
+        return nil, err
+    }
+    return chaves, nil
+}
+
+func (r *chaveAPIRepository) FindByID(ctx context.Context, id uuid.UUID) (*domain.ChaveAPI, error) {
+    return buscarChaveAPI(r.db.WithContext(ctx), "id = ?", id)
+}
+
+func (r *chaveAPIRepository) FindByHash(ctx context.Context, hash string) (*domain.ChaveAPI, error) {
+    return buscarChaveAPI(r.db.WithContext(tenant.Sistema(ctx)), "hash = ?", hash)
+}
+
+func (r *chaveAPIRepository) Create(ctx context.Context, c *domain.ChaveAPI) error {
+    return r.db.WithContext(ctx).Create(c).Error
+}
+
+func (r *chaveAPIRepository) Rotacionar(ctx context.Context, id uuid.UUID, prefixo, hash string) (string, *domain.ChaveAPI, error) {
+    var antigo string
+    var chave *domain.ChaveAPI
+    err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+        var err error
+        chave, err = buscarChaveAPI(tx.Clauses(clause.Locking{Strength: "UPDATE"}), "id = ?", id)
+        if err != nil {
+            return err
+        }
+        if chave.RevogadaEm != nil {
+            return domain.ErrChaveAPIRevogada
+        }
+        agora := time.Now()
+        antigo = chave.Hash
+        chave.Prefixo = prefixo
+        chave.Hash = hash
+        chave.RotacionadaEm = &agora
+        return tx.Save(chave).Error
+    })
+    if err != nil {
+        return "", nil, err
+    }
+    return antigo, chave, nil
+}
+
+func (r *chaveAPIRepository) Revogar(ctx context.Context, id uuid.UUID) (*domain.ChaveAPI, error) {
+    var chave *domain.ChaveAPI
+    err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+        var err error
+        chave, err = buscarChaveAPI(tx.Clauses(clause.Locking{Strength: "UPDATE"}), "id = ?", id)
+        if err != nil {
+            return err
+        }
+        if chave.RevogadaEm != nil {
+            return nil
+        }
+        agora := time.Now()
+        chave.RevogadaEm = &agora
+        return tx.Save(chave).Error
+    })
+    if err != nil {
+        return nil, err
+    }
+    return chave, nil
+}
+
+func (r *chaveAPIRepository) RegistrarUso(ctx context.Context, id uuid.UUID, em time.Time) error {
+    // UpdateColumn não mexe em updated_at: uso não é alteração da chave
+    return r.db.WithContext(tenant.Sistema(ctx)).Model(&domain.ChaveAPI{}).Where("id = ?", id).
+        UpdateColumn("ultimo_uso_em", em).Error
+}
+
+func buscarChaveAPI(tx *gorm.DB, condicao string, valor any) (*domain.ChaveAPI, error) {
+    var c domain.ChaveAPI
+    if err := tx.First(&c, condicao, valor).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            return nil, domain.ErrChaveAPINaoEncontrada
+        }
+        return nil, err
+    }
+    return &c, nil
+}
@@ -0,0 +1,117 @@
+// internal/repository/deposito_repository.go
+package repository
+
+import (
+    "context"
+
+    "servico-estoque/internal/domain"
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+)
+
+type DepositoRepository interface {
+    FindAll(ctx context.Context) ([]domain.Deposito, error)
+    FindByID(ctx context.Context, id uuid.UUID) (*domain.Deposito, error)
+    FindByCodigo(ctx context.Context, codigo string) (*domain.Deposito, error)
+    Create(ctx context.Context, d *domain.Deposito) error
+
+    // Inicializar garante a existência de um depósito padrão da empresa e
+    // migra para ele os saldos de produtos ainda sem detalhamento por depósito
+    Inicializar(ctx context.Context) error
+    // Tenants lista as empresas já inicializadas (com depósito padrão)
+    Tenants(ctx context.Context) ([]string, error)
+}
+
+type depositoRepository struct {
+    db *gorm.DB
+}
+
+func NewDepositoRepository(db *gorm.DB) DepositoRepository {
+    return &depositoRepository{db: db}
+}
+
+func (r *depositoRepository) FindAll(ctx context.Context) ([]domain.Deposito, error) {
+    var depositos []domain.Deposito
+    if err := r.db.WithContext(ctx).Order("codigo").Find(&depositos).Error; err != nil {
+        return nil, err
+    }
+    return depositos, nil
+}
+
+func (r *depositoRepository) FindByID(ctx context.Context, id uuid.UUID) (*domain.Deposito, error) {
+    var d domain.Deposito
+    if err := r.db.WithContext(ctx).First(&d, "id = ?", id).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            return nil, domain.ErrDepositoNaoEncontrado
+        }
+        return nil, err
+    }
+    return &d, nil
+}
+
+func (r *depositoRepository) FindByCodigo(ctx context.Context, codigo string) (*domain.Deposito, error) {
+    var d domain.Deposito
+    if err := r.db.WithContext(ctx).First(&d, "codigo = ?", codigo).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            return nil, nil
+        }
+        return nil, err
+    }
+    return &d, nil
+}
+
+// Create cria o depósito. Se ele for o novo padrão, o anterior deixa de ser.
+func (r *depositoRepository) Create(ctx context.Context, d *domain.Deposito) error {
+    return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+        if d.Padrao {
+            if err := tx.Model(&domain.Deposito{}).
+                Where("padrao = ?", true).
+                Update("padrao", false).Error; err != nil {
+                return err
+            }
+        }
+        return tx.Create(d).Error
+    })
+}
+
+func (r *depositoRepository) Inicializar(ctx context.Context) error {
+    empresa, err := tenantDe(ctx)
+    if err != nil {
+        return err
+    }
+    return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+        var padrao domain.Deposito
+        err := tx.First(&padrao, "padrao = ?", true).Error
+        if err == gorm.ErrRecordNotFound {
+            padrao = domain.Deposito{Codigo: "PRINCIPAL", Nome: "Depósito principal", Padrao: true}
+            err = tx.Create(&padrao).Error
+        }
+        if err != nil {
+            return err
+        }
+
+        if err := tx.Exec(`
+            INSERT INTO saldo_depositos (produto_id, deposito_id, tenant_id, saldo, reservado, updated_at)
+            SELECT p.id, ?, p.tenant_id, p.saldo, p.reservado, NOW()
+            FROM produtos p
+            WHERE p.tenant_id = ?
+              AND NOT EXISTS (SELECT 1 FROM saldo_depositos s WHERE s.produto_id = p.id)`,
+            padrao.ID, empresa).Error; err != nil {
+            return err
+        }
+
+        return tx.Model(&domain.ReservaEstoque{}).
+            Where("deposito_id IS NULL").
+            Update("deposito_id", padrao.ID).Error
+    })
+}
+
+func (r *depositoRepository) Tenants(ctx context.Context) ([]string, error) {
+    var tenants []string
+    if err := r.db.WithContext(ctx).Raw(
+        `SELECT DISTINCT tenant_id FROM depositos WHERE padrao AND tenant_id <> '' ORDER BY tenant_id`,
+    ).Scan(&tenants).Error; err != nil {
+        return nil, err
+    }
+    return tenants, nil
+}
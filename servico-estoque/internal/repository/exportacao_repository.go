@@ -0,0 +1,65 @@
+// internal/repository/exportacao_repository.go
+package repository
+
+import (
+    "context"
+
+    "servico-estoque/internal/domain"
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+)
+
+// ExportarProdutos percorre os produtos que atendem ao filtro da listagem, na
+// ordem pedida, sem paginação. As linhas são lidas do cursor do banco uma a
+// uma e entregues a fn, então a memória usada não depende do tamanho do
+// catálogo; o Preload de saldos por depósito não é feito.
+func (r *produtoRepository) ExportarProdutos(ctx context.Context, filtro domain.FiltroProdutos, fn func(*domain.Produto) error) error {
+    direcao := "ASC"
+    if filtro.Decrescente {
+        direcao = "DESC"
+    }
+    q := filtrarProdutos(r.db.WithContext(ctx).Model(&domain.Produto{}), filtro).
+        Order(colunaOrdem(filtro.Ordem) + " " + direcao + ", id " + direcao)
+    return percorrer(r.db, q, fn)
+}
+
+// ExportarMovimentos percorre o kardex em ordem cronológica, de um produto
+// ou de todos (ProdutoID zero), no período do filtro. Paginação é ignorada.
+func (r *produtoRepository) ExportarMovimentos(ctx context.Context, filtro domain.FiltroMovimentos, fn func(*domain.MovimentoExportado) error) error {
+    q := r.db.WithContext(ctx).Model(&domain.MovimentoEstoque{}).
+        Select("movimento_estoques.*, produtos.codigo AS produto_codigo").
+        Joins("JOIN produtos ON produtos.id = movimento_estoques.produto_id")
+    if filtro.ProdutoID != uuid.Nil {
+        q = q.Where("movimento_estoques.produto_id = ?", filtro.ProdutoID)
+    }
+    if filtro.De != nil {
+        q = q.Where("movimento_estoques.created_at >= ?", *filtro.De)
+    }
+    if filtro.Ate != nil {
+        q = q.Where("movimento_estoques.created_at <= ?", *filtro.Ate)
+    }
+    q = q.Order("movimento_estoques.created_at, movimento_estoques.id")
+    return percorrer(r.db, q, fn)
+}
+
+// percorrer executa q e entrega a fn cada linha do resultado assim que ela
+// chega do banco, sem montar a lista em memória. Um erro de fn interrompe a
+// leitura e é devolvido.
+func percorrer[T any](db *gorm.DB, q *gorm.DB, fn func(*T) error) error {
+    rows, err := q.Rows()
+    if err != nil {
+        return err
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var item T
+        if err := db.ScanRows(rows, &item); err != nil {
+            return err
+        }
+        if err := fn(&item); err != nil {
+            return err
+        }
+    }
+    return rows.Err()
+}
@@ -0,0 +1,69 @@
+// internal/repository/listagem.go
+package repository
+
+import (
+    "time"
+
+    "servico-estoque/internal/domain"
+    "github.com/shopspring/decimal"
+    "gorm.io/gorm"
+)
+
+// colunasOrdem liga cada ordenação da listagem à expressão SQL correspondente
+var colunasOrdem = map[string]string{
+    domain.OrdemCodigo:      "codigo",
+    domain.OrdemDescricao:   "descricao",
+    domain.OrdemSaldo:       "saldo",
+    domain.OrdemDisponivel:  "(saldo - reservado)",
+    domain.OrdemAtualizacao: "updated_at",
+}
+
+func colunaOrdem(ordem string) string {
+    if c, ok := colunasOrdem[ordem]; ok {
+        return c
+    }
+    return colunasOrdem[domain.OrdemCodigo]
+}
+
+// filtrarProdutos aplica os filtros da listagem, sem ordenação nem paginação
+func filtrarProdutos(q *gorm.DB, f domain.FiltroProdutos) *gorm.DB {
+    if f.CategoriaID != nil {
+        q = q.Where("categoria_id IN (?)", gorm.Expr(subarvoreCategoria, *f.CategoriaID))
+    }
+    if f.DisponivelAte != nil {
+        q = q.Where("saldo - reservado <= ?", *f.DisponivelAte)
+    }
+    if f.SemDisponivel {
+        q = q.Where("saldo - reservado <= 0")
+    }
+    if f.AtualizadoDesde != nil {
+        q = q.Where("updated_at >= ?", *f.AtualizadoDesde)
+    }
+    if f.ComReservas != nil {
+        if *f.ComReservas {
+            q = q.Where("reservado > 0")
+        } else {
+            q = q.Where("reservado = 0")
+        }
+    }
+    return q
+}
+
+// valorCursor converte o valor guardado no cursor para o tipo da coluna
+func valorCursor(c *domain.CursorProdutos) (any, error) {
+    switch c.Ordem {
+    case domain.OrdemSaldo, domain.OrdemDisponivel:
+        v, err := decimal.NewFromString(c.Valor)
+        if err != nil {
+            return nil, domain.ErrCursorInvalido
+        }
+        return v, nil
+    case domain.OrdemAtualizacao:
+        v, err := time.Parse(time.RFC3339Nano, c.Valor)
+        if err != nil {
+            return nil, domain.ErrCursorInvalido
+        }
+        return v, nil
+    }
+    return c.Valor, nil
+}
@@ -0,0 +1,134 @@
+// internal/repository/lote_repository.go
+package repository
+
+import (
+    "context"
+    "fmt"
+
+    "servico-estoque/internal/domain"
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+)
+
+// EntradaLote soma a quantidade ao lote (criando-o se preciso) e ao saldo do
+// produto no depósito do lote. l.DepositoID zero indica o depósito padrão.
+func (r *produtoRepository) EntradaLote(ctx context.Context, l *domain.Lote, quantidade decimal.Decimal, custoUnitario *decimal.Decimal, motivo string) error {
+    return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+        p, err := travarProduto(tx, l.ProdutoID)
+        if err != nil {
+            return err
+        }
+        if !p.ControlaLote {
+            return domain.ErrProdutoSemLote
+        }
+
+        var depositoID *uuid.UUID
+        if l.DepositoID != uuid.Nil {
+            depositoID = &l.DepositoID
+        }
+        deposito, err := resolverDeposito(tx, depositoID)
+        if err != nil {
+            return err
+        }
+        l.DepositoID = deposito.ID
+
+        saldo := domain.SaldoDeposito{ProdutoID: p.ID, DepositoID: deposito.ID}
+        if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+            FirstOrCreate(&saldo, "produto_id = ? AND deposito_id = ?", p.ID, deposito.ID).Error; err != nil {
+            return err
+        }
+
+        var existente domain.Lote
+        err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+            First(&existente, "produto_id = ? AND deposito_id = ? AND numero = ?", p.ID, deposito.ID, l.Numero).Error
+        switch {
+        case err == gorm.ErrRecordNotFound:
+            l.Saldo = quantidade
+            if err := tx.Create(l).Error; err != nil {
+                return err
+            }
+        case err != nil:
+            return err
+        default:
+            // Um mesmo lote não pode mudar de validade entre entradas
+            if !existente.Validade.Equal(l.Validade) {
+                return domain.ErrDadosInvalidos
+            }
+            existente.Saldo = existente.Saldo.Add(quantidade)
+            if err := tx.Save(&existente).Error; err != nil {
+                return err
+            }
+            *l = existente
+        }
+
+        saldo.Saldo = saldo.Saldo.Add(quantidade)
+        if err := tx.Save(&saldo).Error; err != nil {
+            return err
+        }
+
+        var custo decimal.Decimal
+        if custoUnitario != nil {
+            custo = *custoUnitario
+            p.EntradaComCusto(quantidade, custo)
+        }
+
+        saldoAnterior := p.Saldo
+        p.Saldo = p.Saldo.Add(quantidade)
+        if err := tx.Omit(clause.Associations).Save(p).Error; err != nil {
+            return err
+        }
+
+        if motivo == "" {
+            motivo = fmt.Sprintf("entrada do lote %s", l.Numero)
+        }
+        return registrarMovimento(tx, p, saldoAnterior, domain.MovimentoEstoque{
+            Tipo:          domain.MovimentoEntrada,
+            Quantidade:    quantidade,
+            CustoUnitario: custo,
+            DepositoID:    &deposito.ID,
+            Motivo:        motivo,
+        })
+    })
+}
+
+// ListarLotes retorna os lotes do produto em ordem FEFO
+func (r *produtoRepository) ListarLotes(ctx context.Context, produtoID uuid.UUID) ([]domain.Lote, error) {
+    var lotes []domain.Lote
+    if err := r.db.WithContext(ctx).
+        Where("produto_id = ?", produtoID).
+        Order("validade, numero").
+        Find(&lotes).Error; err != nil {
+        return nil, err
+    }
+    return lotes, nil
+}
+
+// liberarLotes devolve aos lotes o que a reserva havia separado. Com baixar,
+// a quantidade também sai do saldo de cada lote.
+func liberarLotes(tx *gorm.DB, r *domain.ReservaEstoque, baixar bool) error {
+    var partes []domain.ReservaLote
+    if err := tx.Where("reserva_id = ?", r.ID).Order("lote_id").Find(&partes).Error; err != nil {
+        return err
+    }
+
+    for _, parte := range partes {
+        var l domain.Lote
+        if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+            First(&l, "id = ?", parte.LoteID).Error; err != nil {
+            return err
+        }
+        l.Reservado = decimal.Max(l.Reservado.Sub(parte.Quantidade), decimal.Zero)
+        if baixar {
+            l.Saldo = l.Saldo.Sub(parte.Quantidade)
+            if l.Saldo.IsNegative() {
+                return domain.ErrSaldoNegativo
+            }
+        }
+        if err := tx.Save(&l).Error; err != nil {
+            return err
+        }
+    }
+    return nil
+}
@@ -0,0 +1,51 @@
+// internal/repository/movimento_repository.go
+package repository
+
+import (
+    "context"
+
+    "servico-estoque/internal/domain"
+    "github.com/shopspring/decimal"
+    "gorm.io/gorm"
+)
+
+// registrarMovimento grava no kardex uma movimentação já aplicada a p,
+// completando produto, saldos antes/depois e custos. Sem CustoUnitario o
+// movimento é valorizado pelo custo médio do produto. Deve ser chamado
+// dentro da mesma transação que alterou o produto.
+func registrarMovimento(tx *gorm.DB, p *domain.Produto, saldoAnterior decimal.Decimal, m domain.MovimentoEstoque) error {
+    m.ProdutoID = p.ID
+    m.SaldoAnterior = saldoAnterior
+    m.SaldoPosterior = p.Saldo
+    if m.CustoUnitario.IsZero() {
+        m.CustoUnitario = p.CustoMedio
+    }
+    m.CustoTotal = m.Quantidade.Mul(m.CustoUnitario).Round(domain.CasasValor)
+    m.CustoMedio = p.CustoMedio
+    return tx.Create(&m).Error
+}
+
+func (r *produtoRepository) ListarMovimentos(ctx context.Context, filtro domain.FiltroMovimentos) ([]domain.MovimentoEstoque, int64, error) {
+    q := r.db.WithContext(ctx).Model(&domain.MovimentoEstoque{}).
+        Where("produto_id = ?", filtro.ProdutoID)
+    if filtro.De != nil {
+        q = q.Where("created_at >= ?", *filtro.De)
+    }
+    if filtro.Ate != nil {
+        q = q.Where("created_at <= ?", *filtro.Ate)
+    }
+
+    var total int64
+    if err := q.Count(&total).Error; err != nil {
+        return nil, 0, err
+    }
+
+    var movimentos []domain.MovimentoEstoque
+    if err := q.Order("created_at DESC, id").
+        Offset((filtro.Page - 1) * filtro.Size).
+        Limit(filtro.Size).
+        Find(&movimentos).Error; err != nil {
+        return nil, 0, err
+    }
+    return movimentos, total, nil
+}
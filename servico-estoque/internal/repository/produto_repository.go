@@ -2,39 +2,90 @@
 package repository
 
 import (
+    "bytes"
     "context"
+    "errors"
+    "sort"
+    "time"
 
     "servico-estoque/internal/domain"
     "github.com/google/uuid"
+    "github.com/shopspring/decimal"
     "gorm.io/gorm"
+    "gorm.io/gorm/clause"
 )
 
 type ProdutoRepository interface {
     FindByID(ctx context.Context, id uuid.UUID) (*domain.Produto, error)
     FindByCodigo(ctx context.Context, codigo string) (*domain.Produto, error)
-    FindAll(ctx context.Context) ([]domain.Produto, error)
-    Search(ctx context.Context, query string) ([]domain.Produto, error)
-    Create(ctx context.Context, p *domain.Produto) error
-    Update(ctx context.Context, p *domain.Produto) error
-    Delete(ctx context.Context, id uuid.UUID) error
+    FindByIDs(ctx context.Context, ids []uuid.UUID) ([]domain.Produto, error)
+    FindAll(ctx context.Context, filtro domain.FiltroProdutos) ([]domain.Produto, int64, error)
+    Search(ctx context.Context, filtro domain.FiltroBusca) ([]domain.ResultadoBusca, int64, error)
+    Create(ctx context.Context, p *domain.Produto, depositoID *uuid.UUID) error
+    Update(ctx context.Context, p *domain.Produto, ajuste *AjusteSaldo, conversoes *[]domain.ConversaoUnidade) error
+    Delete(ctx context.Context, id uuid.UUID, versao int64) error
 
-    ReservarEstoque(ctx context.Context, r *domain.ReservaEstoque) error
-    ConfirmarReserva(ctx context.Context, notaID uuid.UUID) error
-    CancelarReserva(ctx context.Context, notaID uuid.UUID) error
-    BaixarEstoque(ctx context.Context, produtoID uuid.UUID, qtd int) error
+    ReservarEstoque(ctx context.Context, notaID uuid.UUID, itens []domain.ItemReserva, expiresAt time.Time) ([]domain.ReservaEstoque, error)
+    // ConfirmarReserva e CancelarReserva retornam quantas reservas (uma por
+    // produto e depósito) da nota foram confirmadas ou canceladas
+    ConfirmarReserva(ctx context.Context, notaID uuid.UUID) (int, error)
+    CancelarReserva(ctx context.Context, notaID uuid.UUID) (int, error)
+    BaixarEstoque(ctx context.Context, baixa *domain.BaixaEstoque, itens []domain.ItemReserva) (*domain.BaixaEstoque, bool, error)
+    EstornarBaixa(ctx context.Context, id uuid.UUID, motivo string) (*domain.BaixaEstoque, error)
+    FindBaixa(ctx context.Context, id uuid.UUID) (*domain.BaixaEstoque, error)
+    ExpirarReservas(ctx context.Context, agora time.Time, limite int) (int, error)
+
+    ListarMovimentos(ctx context.Context, filtro domain.FiltroMovimentos) ([]domain.MovimentoEstoque, int64, error)
+    PosicoesEstoque(ctx context.Context, data *time.Time) ([]domain.PosicaoEstoque, error)
+    // TotaisEstoque soma reservado e disponível de todas as empresas
+    TotaisEstoque(ctx context.Context) ([]domain.TotalEstoque, error)
+
+    ExportarProdutos(ctx context.Context, filtro domain.FiltroProdutos, fn func(*domain.Produto) error) error
+    ExportarMovimentos(ctx context.Context, filtro domain.FiltroMovimentos, fn func(*domain.MovimentoExportado) error) error
+
+    EntradaLote(ctx context.Context, l *domain.Lote, quantidade decimal.Decimal, custoUnitario *decimal.Decimal, motivo string) error
+    ListarLotes(ctx context.Context, produtoID uuid.UUID) ([]domain.Lote, error)
+
+    EntradaSeries(ctx context.Context, produtoID uuid.UUID, depositoID *uuid.UUID, numeros []string, custoUnitario *decimal.Decimal, motivo string) ([]domain.NumeroSerie, error)
+    FindSerie(ctx context.Context, produtoID uuid.UUID, numero string) (*domain.NumeroSerie, error)
+
+    // Inicializar cria as extensões, a configuração de texto e os índices
+    // usados pela busca
+    Inicializar(ctx context.Context) error
+}
+
+// AjusteSaldo define o novo saldo de um produto em um depósito.
+// DepositoID nil indica o depósito padrão. CustoUnitario, se informado,
+// valoriza o acréscimo de saldo e entra no custo médio.
+type AjusteSaldo struct {
+    DepositoID    *uuid.UUID
+    Saldo         decimal.Decimal
+    CustoUnitario *decimal.Decimal
+    Motivo        string
 }
 
 type produtoRepository struct {
-    db *gorm.DB
+    db       *gorm.DB
+    alocacao domain.EstrategiaAlocacao
+}
+
+func NewProdutoRepository(db *gorm.DB, alocacao domain.EstrategiaAlocacao) ProdutoRepository {
+    return &produtoRepository{db: db, alocacao: alocacao}
 }
 
-func NewProdutoRepository(db *gorm.DB) ProdutoRepository {
-    return &produtoRepository{db: db}
+// comSaldos carrega o detalhamento por depósito e as unidades alternativas
+// junto com o produto
+func comSaldos(db *gorm.DB) *gorm.DB {
+    return db.Preload("Depositos", func(db *gorm.DB) *gorm.DB {
+        return db.Order("deposito_id")
+    }).Preload("Depositos.Deposito").Preload("Conversoes", func(db *gorm.DB) *gorm.DB {
+        return db.Order("unidade")
+    })
 }
 
 func (r *produtoRepository) FindByID(ctx context.Context, id uuid.UUID) (*domain.Produto, error) {
     var p domain.Produto
-    if err := r.db.WithContext(ctx).First(&p, "id = ?", id).Error; err != nil {
+    if err := comSaldos(r.db.WithContext(ctx)).First(&p, "id = ?", id).Error; err != nil {
         if err == gorm.ErrRecordNotFound {
             return nil, domain.ErrProdutoNaoEncontrado
         }
@@ -54,139 +105,624 @@ func (r *produtoRepository) FindByCodigo(ctx context.Context, codigo string) (*d
     return &p, nil
 }
 
-func (r *produtoRepository) FindAll(ctx context.Context) ([]domain.Produto, error) {
+// FindByIDs retorna os produtos encontrados entre os IDs, com suas unidades
+// alternativas
+func (r *produtoRepository) FindByIDs(ctx context.Context, ids []uuid.UUID) ([]domain.Produto, error) {
     var produtos []domain.Produto
-    if err := r.db.WithContext(ctx).Find(&produtos).Error; err != nil {
+    if err := r.db.WithContext(ctx).Preload("Conversoes").Where("id IN ?", ids).Find(&produtos).Error; err != nil {
         return nil, err
     }
     return produtos, nil
 }
 
-func (r *produtoRepository) Search(ctx context.Context, query string) ([]domain.Produto, error) {
+// FindAll lista os produtos do filtro e retorna também o total sem paginação
+func (r *produtoRepository) FindAll(ctx context.Context, filtro domain.FiltroProdutos) ([]domain.Produto, int64, error) {
+    q := filtrarProdutos(r.db.WithContext(ctx).Model(&domain.Produto{}), filtro)
+
+    var total int64
+    if err := q.Count(&total).Error; err != nil {
+        return nil, 0, err
+    }
+
+    coluna := colunaOrdem(filtro.Ordem)
+    direcao := "ASC"
+    if filtro.Decrescente {
+        direcao = "DESC"
+    }
+    if filtro.Cursor != nil {
+        valor, err := valorCursor(filtro.Cursor)
+        if err != nil {
+            return nil, 0, err
+        }
+        operador := ">"
+        if filtro.Decrescente {
+            operador = "<"
+        }
+        q = q.Where("("+coluna+", id) "+operador+" (?, ?)", valor, filtro.Cursor.ID)
+    } else {
+        q = q.Offset((filtro.Page - 1) * filtro.Size)
+    }
+
     var produtos []domain.Produto
-    q := "%" + query + "%"
-    if err := r.db.WithContext(ctx).
-        Where("descricao ILIKE ? OR codigo ILIKE ?", q, q).
+    if err := comSaldos(q).
+        Order(coluna + " " + direcao + ", id " + direcao).
+        Limit(filtro.Size).
         Find(&produtos).Error; err != nil {
-        return nil, err
+        return nil, 0, err
     }
-    return produtos, nil
+    return produtos, total, nil
 }
 
-func (r *produtoRepository) Create(ctx context.Context, p *domain.Produto) error {
-    return r.db.WithContext(ctx).Create(p).Error
-}
+func (r *produtoRepository) Create(ctx context.Context, p *domain.Produto, depositoID *uuid.UUID) error {
+    return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+        deposito, err := resolverDeposito(tx, depositoID)
+        if err != nil {
+            return err
+        }
 
-func (r *produtoRepository) Update(ctx context.Context, p *domain.Produto) error {
-    return r.db.WithContext(ctx).Updates(p).Error
-}
+        if err := tx.Omit(clause.Associations).Create(p).Error; err != nil {
+            return err
+        }
+
+        for i := range p.Conversoes {
+            p.Conversoes[i].ProdutoID = p.ID
+        }
+        if len(p.Conversoes) > 0 {
+            if err := tx.Create(&p.Conversoes).Error; err != nil {
+                return err
+            }
+        }
+
+        saldo := domain.SaldoDeposito{ProdutoID: p.ID, DepositoID: deposito.ID, Saldo: p.Saldo}
+        if err := tx.Create(&saldo).Error; err != nil {
+            return err
+        }
+        p.Depositos = []domain.SaldoDeposito{saldo}
 
-func (r *produtoRepository) Delete(ctx context.Context, id uuid.UUID) error {
-    return r.db.WithContext(ctx).Delete(&domain.Produto{}, "id = ?", id).Error
+        if p.Saldo.IsZero() {
+            return nil
+        }
+        return registrarMovimento(tx, p, decimal.Zero, domain.MovimentoEstoque{
+            Tipo:       domain.MovimentoEntrada,
+            Quantidade: p.Saldo,
+            DepositoID: &deposito.ID,
+            Motivo:     "saldo inicial",
+        })
+    })
 }
 
-func (r *produtoRepository) ReservarEstoque(ctx context.Context, reserva *domain.ReservaEstoque) error {
+// Update grava os dados cadastrais de p, o ajuste de saldo pedido e, se
+// conversoes não for nil, substitui as unidades alternativas, tudo na mesma
+// transação e desde que p.Versao ainda seja a versão atual do produto; caso
+// contrário retorna ErrVersaoDivergente. Com sucesso, p.Versao passa a ser a
+// nova versão.
+func (r *produtoRepository) Update(ctx context.Context, p *domain.Produto, ajuste *AjusteSaldo, conversoes *[]domain.ConversaoUnidade) error {
     return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-        var p domain.Produto
-        if err := tx.Clauses(/* lock for update */).WithContext(ctx).
-            First(&p, "id = ?", reserva.ProdutoID).Error; err != nil {
+        atual, err := travarProduto(tx, p.ID)
+        if err != nil {
+            return err
+        }
+        if atual.Versao != p.Versao {
+            return domain.ErrVersaoDivergente
+        }
+
+        // Grava só se a versão lida ainda for a atual
+        esperada := p.Versao
+        p.Versao++
+        res := tx.Model(p).Where("versao = ?", esperada).
+            Select("descricao", "categoria_id", "ncm", "cest", "origem", "cfop",
+                "unidade_comercial", "unidade_tributavel", "gtin", "versao").Updates(p)
+        if res.Error != nil {
+            return res.Error
+        }
+        if res.RowsAffected == 0 {
+            return domain.ErrVersaoDivergente
+        }
+        p.Saldo, p.Reservado = atual.Saldo, atual.Reservado
+
+        if conversoes != nil {
+            if err := substituirConversoes(tx, p.ID, *conversoes); err != nil {
+                return err
+            }
+            p.Conversoes = *conversoes
+        }
+
+        if ajuste == nil {
+            return nil
+        }
+        if atual.ControlaLote {
+            return domain.ErrLoteObrigatorio
+        }
+        if atual.ControlaSerie {
+            return domain.ErrSerieObrigatoria
+        }
+
+        deposito, err := resolverDeposito(tx, ajuste.DepositoID)
+        if err != nil {
+            return err
+        }
+
+        saldo := domain.SaldoDeposito{ProdutoID: p.ID, DepositoID: deposito.ID}
+        if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+            FirstOrCreate(&saldo, "produto_id = ? AND deposito_id = ?", p.ID, deposito.ID).Error; err != nil {
             return err
         }
+        if ajuste.Saldo.LessThan(saldo.Reservado) {
+            return domain.ErrSaldoNegativo
+        }
 
-        if !p.PodeReservar(reserva.Quantidade) {
-            return domain.ErrEstoqueInsuficiente
+        delta := ajuste.Saldo.Sub(saldo.Saldo)
+        if delta.IsZero() {
+            return nil
         }
 
-        p.Reservado += reserva.Quantidade
-        if err := tx.Save(&p).Error; err != nil {
+        saldo.Saldo = ajuste.Saldo
+        if err := tx.Save(&saldo).Error; err != nil {
             return err
         }
 
-        return tx.Create(reserva).Error
+        var custo decimal.Decimal
+        if delta.IsPositive() && ajuste.CustoUnitario != nil {
+            custo = *ajuste.CustoUnitario
+            atual.EntradaComCusto(delta, custo)
+        }
+
+        // atual foi lido antes da gravação dos dados cadastrais: grava só o
+        // saldo e o custo para não desfazê-la
+        saldoAnterior := atual.Saldo
+        atual.Saldo = atual.Saldo.Add(delta)
+        if err := tx.Model(atual).Select("saldo", "custo_medio").Updates(atual).Error; err != nil {
+            return err
+        }
+        p.Saldo, p.CustoMedio = atual.Saldo, atual.CustoMedio
+
+        motivo := ajuste.Motivo
+        if motivo == "" {
+            motivo = "ajuste manual"
+        }
+        return registrarMovimento(tx, atual, saldoAnterior, domain.MovimentoEstoque{
+            Tipo:          domain.MovimentoAjuste,
+            Quantidade:    delta.Abs(),
+            CustoUnitario: custo,
+            DepositoID:    &deposito.ID,
+            Motivo:        motivo,
+        })
     })
 }
 
-func (r *produtoRepository) ConfirmarReserva(ctx context.Context, notaID uuid.UUID) error {
+// Delete remove o produto e seus dados dependentes se a versão informada
+// ainda for a atual. Produtos com reservas pendentes não podem ser removidos
+// (ErrOperacaoNaoPermitida): a reserva precisa ser confirmada, cancelada ou
+// expirar antes.
+func (r *produtoRepository) Delete(ctx context.Context, id uuid.UUID, versao int64) error {
     return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-        var reservas []domain.ReservaEstoque
-        if err := tx.Where("nota_fiscal_id = ? AND status = 'PENDENTE'", notaID).
-            Find(&reservas).Error; err != nil {
+        atual, err := travarProduto(tx, id)
+        if err != nil {
             return err
         }
+        if atual.Versao != versao {
+            return domain.ErrVersaoDivergente
+        }
 
-        for _, r := range reservas {
-            var p domain.Produto
-            if err := tx.First(&p, "id = ?", r.ProdutoID).Error; err != nil {
-                return err
-            }
+        // Reservas travam o produto antes de mudar de status, então a
+        // contagem não muda até o fim desta transação
+        var pendentes int64
+        if err := tx.Model(&domain.ReservaEstoque{}).
+            Where("produto_id = ? AND status = ?", id, domain.StatusReservaPendente).
+            Count(&pendentes).Error; err != nil {
+            return err
+        }
+        if pendentes > 0 {
+            return domain.ErrOperacaoNaoPermitida
+        }
+
+        if err := tx.Delete(&domain.ConversaoUnidade{}, "produto_id = ?", id).Error; err != nil {
+            return err
+        }
+        if err := tx.Delete(&domain.Lote{}, "produto_id = ?", id).Error; err != nil {
+            return err
+        }
+        if err := tx.Where("serie_id IN (?)", tx.Model(&domain.NumeroSerie{}).Select("id").Where("produto_id = ?", id)).
+            Delete(&domain.HistoricoSerie{}).Error; err != nil {
+            return err
+        }
+        if err := tx.Delete(&domain.NumeroSerie{}, "produto_id = ?", id).Error; err != nil {
+            return err
+        }
+        if err := tx.Delete(&domain.SaldoDeposito{}, "produto_id = ?", id).Error; err != nil {
+            return err
+        }
+        res := tx.Delete(&domain.Produto{}, "id = ? AND versao = ?", id, versao)
+        if res.Error != nil {
+            return res.Error
+        }
+        if res.RowsAffected == 0 {
+            return domain.ErrVersaoDivergente
+        }
+        return nil
+    })
+}
 
-            p.Saldo -= r.Quantidade
-            p.Reservado -= r.Quantidade
-            if p.Saldo < 0 {
-                return domain.ErrSaldoNegativo
+// substituirConversoes troca todas as unidades alternativas do produto
+func substituirConversoes(tx *gorm.DB, produtoID uuid.UUID, conversoes []domain.ConversaoUnidade) error {
+    if err := tx.Delete(&domain.ConversaoUnidade{}, "produto_id = ?", produtoID).Error; err != nil {
+        return err
+    }
+    if len(conversoes) == 0 {
+        return nil
+    }
+    for i := range conversoes {
+        conversoes[i].ProdutoID = produtoID
+    }
+    return tx.Create(&conversoes).Error
+}
+
+// ReservarEstoque reserva todos os itens de uma nota de forma atômica. As
+// linhas dos produtos são travadas em ordem de ID para evitar deadlocks entre
+// reservas concorrentes; se qualquer item falhar nada é reservado. Itens sem
+// depósito são alocados pela estratégia configurada. Produtos controlados por
+// lote reservam dos lotes válidos que vencem primeiro (FEFO); produtos
+// controlados por série reservam as séries informadas ou as mais antigas.
+func (r *produtoRepository) ReservarEstoque(ctx context.Context, notaID uuid.UUID, itens []domain.ItemReserva, expiresAt time.Time) ([]domain.ReservaEstoque, error) {
+    ids, grupos := agruparItens(itens)
+
+    var reservas []domain.ReservaEstoque
+    err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+        estoque, err := travarEstoque(tx, ids)
+        if err != nil {
+            return err
+        }
+
+        agora := time.Now()
+        var falhas []domain.ItemIndisponivel
+        alocados := make([]*domain.SaldoDeposito, len(grupos))
+        reservaIDs := make([]uuid.UUID, len(grupos))
+        lotes := make([][]domain.ReservaLote, len(grupos))
+        series := make([][]string, len(grupos))
+        for i, g := range grupos {
+            reservaIDs[i] = uuid.New()
+
+            var saldo *domain.SaldoDeposito
+            var falha *domain.ItemIndisponivel
+            switch {
+            case estoque.controlaSerie(g.produtoID):
+                var separadas []domain.NumeroSerie
+                saldo, separadas, falha, err = r.separarSeries(tx, estoque, g)
+                if err != nil {
+                    return err
+                }
+                if falha == nil {
+                    if err := marcarSeries(tx, separadas, domain.SerieReservada, &reservaIDs[i], &notaID, "reserva"); err != nil {
+                        return err
+                    }
+                    series[i] = numerosDe(separadas)
+                }
+            case len(g.series) > 0:
+                falha = novaFalha(g, domain.ErrProdutoSemSerie.Error(), g.depositoID, decimal.Zero)
+            case estoque.controlaLote(g.produtoID):
+                saldo, falha = r.alocar(estoque, g, func(s *domain.SaldoDeposito) decimal.Decimal { return estoque.disponivelLotes(s, agora) })
+                if falha != nil && estoque.apenasVencidos(falha, agora) {
+                    falha.Motivo = domain.ErrLoteVencido.Error()
+                }
+                if falha == nil {
+                    lotes[i], err = estoque.consumirLotes(tx, saldo, g.quantidade, agora, func(l *domain.Lote, q decimal.Decimal) {
+                        l.Reservado = l.Reservado.Add(q)
+                    })
+                    if err != nil {
+                        return err
+                    }
+                }
+            default:
+                saldo, falha = r.alocar(estoque, g, (*domain.SaldoDeposito).Disponivel)
+            }
+            if falha != nil {
+                falhas = append(falhas, *falha)
+                continue
             }
+            // Desconta já em memória para que os próximos itens do mesmo
+            // produto enxerguem o saldo restante
+            saldo.Reservado = saldo.Reservado.Add(g.quantidade)
+            alocados[i] = saldo
+        }
+        if len(falhas) > 0 {
+            return &domain.ReservaItensError{Itens: falhas}
+        }
 
-            if err := tx.Save(&p).Error; err != nil {
+        for i, g := range grupos {
+            saldo := alocados[i]
+            p := estoque.produtos[g.produtoID]
+            p.Reservado = p.Reservado.Add(g.quantidade)
+            if err := tx.Omit(clause.Associations).Save(p).Error; err != nil {
+                return err
+            }
+            if err := tx.Save(saldo).Error; err != nil {
                 return err
             }
 
-            r.Status = "CONFIRMADO"
-            if err := tx.Save(&r).Error; err != nil {
+            reserva := domain.ReservaEstoque{
+                ID:           reservaIDs[i],
+                ProdutoID:    g.produtoID,
+                NotaFiscalID: notaID,
+                DepositoID:   saldo.DepositoID,
+                Quantidade:   g.quantidade,
+                Lotes:        lotes[i],
+                ExpiresAt:    expiresAt,
+            }
+            if err := tx.Create(&reserva).Error; err != nil {
                 return err
             }
+            reserva.Series = series[i]
+            if err := registrarMovimento(tx, p, p.Saldo, domain.MovimentoEstoque{
+                Tipo:         domain.MovimentoReserva,
+                Quantidade:   reserva.Quantidade,
+                DepositoID:   &saldo.DepositoID,
+                NotaFiscalID: &notaID,
+            }); err != nil {
+                return err
+            }
+            reservas = append(reservas, reserva)
         }
         return nil
     })
+    if err != nil {
+        return nil, err
+    }
+    return reservas, nil
 }
 
-func (r *produtoRepository) CancelarReserva(ctx context.Context, notaID uuid.UUID) error {
-    return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+func (r *produtoRepository) ConfirmarReserva(ctx context.Context, notaID uuid.UUID) (int, error) {
+    var confirmadas int
+    err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
         var reservas []domain.ReservaEstoque
-        if err := tx.Where("nota_fiscal_id = ? AND status = 'PENDENTE'", notaID).
+        if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+            Where("nota_fiscal_id = ?", notaID).
             Find(&reservas).Error; err != nil {
             return err
         }
 
-        for _, r := range reservas {
-            var p domain.Produto
-            if err := tx.First(&p, "id = ?", r.ProdutoID).Error; err != nil {
-                return err
-            }
+        pendentes, err := reservasPendentes(reservas, time.Now())
+        if err != nil {
+            return err
+        }
 
-            p.Reservado -= r.Quantidade
-            if p.Reservado < 0 {
-                p.Reservado = 0
+        for _, r := range pendentes {
+            p, saldoAnterior, err := liberarReserva(tx, &r, true)
+            if err != nil {
+                return err
             }
 
-            if err := tx.Save(&p).Error; err != nil {
+            if err := registrarMovimento(tx, p, saldoAnterior, domain.MovimentoEstoque{
+                Tipo:         domain.MovimentoSaidaNota,
+                Quantidade:   r.Quantidade,
+                DepositoID:   &r.DepositoID,
+                NotaFiscalID: &r.NotaFiscalID,
+                Motivo:       "reserva confirmada",
+            }); err != nil {
                 return err
             }
 
-            r.Status = "CANCELADO"
+            r.Status = domain.StatusReservaConfirmada
             if err := tx.Save(&r).Error; err != nil {
                 return err
             }
         }
+        confirmadas = len(pendentes)
         return nil
     })
+    return confirmadas, err
 }
 
-func (r *produtoRepository) BaixarEstoque(ctx context.Context, produtoID uuid.UUID, qtd int) error {
-    return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-        var p domain.Produto
-        if err := tx.Clauses(/* lock for update */).WithContext(ctx).
-            First(&p, "id = ?", produtoID).Error; err != nil {
+func (r *produtoRepository) CancelarReserva(ctx context.Context, notaID uuid.UUID) (int, error) {
+    var canceladas int
+    err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+        var reservas []domain.ReservaEstoque
+        if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+            Where("nota_fiscal_id = ? AND status = ?", notaID, domain.StatusReservaPendente).
+            Find(&reservas).Error; err != nil {
             return err
         }
 
-        if p.Saldo < qtd {
-            return domain.ErrEstoqueInsuficiente
+        for _, r := range reservas {
+            if err := cancelarReserva(tx, &r, domain.StatusReservaCancelada, "reserva cancelada"); err != nil {
+                return err
+            }
         }
+        canceladas = len(reservas)
+        return nil
+    })
+    return canceladas, err
+}
 
-        p.Saldo -= qtd
-        if err := tx.Save(&p).Error; err != nil {
+func (r *produtoRepository) ExpirarReservas(ctx context.Context, agora time.Time, limite int) (int, error) {
+    var expiradas int
+    err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+        var reservas []domain.ReservaEstoque
+        if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+            Where("status = ? AND expires_at < ?", domain.StatusReservaPendente, agora).
+            Order("expires_at").
+            Limit(limite).
+            Find(&reservas).Error; err != nil {
             return err
         }
+
+        for _, r := range reservas {
+            err := cancelarReserva(tx, &r, domain.StatusReservaExpirada, "reserva expirada")
+            if errors.Is(err, domain.ErrProdutoNaoEncontrado) || errors.Is(err, domain.ErrDepositoNaoEncontrado) {
+                // Reserva órfã (produto ou saldo removidos): não há estoque a
+                // liberar, só encerra a reserva para que ela não volte a cada
+                // execução e trave as demais
+                r.Status = domain.StatusReservaExpirada
+                err = tx.Save(&r).Error
+            }
+            if err != nil {
+                return err
+            }
+        }
+
+        expiradas = len(reservas)
         return nil
     })
-}
\ No newline at end of file
+    return expiradas, err
+}
+
+// cancelarReserva devolve ao disponível a quantidade de uma reserva pendente
+// e a move para o status informado
+func cancelarReserva(tx *gorm.DB, r *domain.ReservaEstoque, status, motivo string) error {
+    p, _, err := liberarReserva(tx, r, false)
+    if err != nil {
+        return err
+    }
+
+    if err := registrarMovimento(tx, p, p.Saldo, domain.MovimentoEstoque{
+        Tipo:         domain.MovimentoCancelamento,
+        Quantidade:   r.Quantidade,
+        DepositoID:   &r.DepositoID,
+        NotaFiscalID: &r.NotaFiscalID,
+        Motivo:       motivo,
+    }); err != nil {
+        return err
+    }
+
+    r.Status = status
+    return tx.Save(r).Error
+}
+
+// liberarReserva retira a quantidade reservada do produto, do depósito, dos
+// lotes e das séries da reserva. Com baixar, a quantidade também sai do
+// saldo. Retorna o produto atualizado e o saldo total anterior.
+func liberarReserva(tx *gorm.DB, r *domain.ReservaEstoque, baixar bool) (*domain.Produto, decimal.Decimal, error) {
+    p, err := travarProduto(tx, r.ProdutoID)
+    if err != nil {
+        return nil, decimal.Zero, err
+    }
+
+    var saldo domain.SaldoDeposito
+    if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+        First(&saldo, "produto_id = ? AND deposito_id = ?", r.ProdutoID, r.DepositoID).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            return nil, decimal.Zero, domain.ErrDepositoNaoEncontrado
+        }
+        return nil, decimal.Zero, err
+    }
+
+    saldoAnterior := p.Saldo
+    p.Reservado = decimal.Max(p.Reservado.Sub(r.Quantidade), decimal.Zero)
+    saldo.Reservado = decimal.Max(saldo.Reservado.Sub(r.Quantidade), decimal.Zero)
+    if baixar {
+        p.Saldo = p.Saldo.Sub(r.Quantidade)
+        saldo.Saldo = saldo.Saldo.Sub(r.Quantidade)
+        if p.Saldo.IsNegative() || saldo.Saldo.IsNegative() {
+            return nil, decimal.Zero, domain.ErrSaldoNegativo
+        }
+    }
+
+    if err := tx.Omit(clause.Associations).Save(p).Error; err != nil {
+        return nil, decimal.Zero, err
+    }
+    if err := tx.Save(&saldo).Error; err != nil {
+        return nil, decimal.Zero, err
+    }
+    if err := liberarLotes(tx, r, baixar); err != nil {
+        return nil, decimal.Zero, err
+    }
+    if err := liberarSeries(tx, r, baixar); err != nil {
+        return nil, decimal.Zero, err
+    }
+    return p, saldoAnterior, nil
+}
+
+// reservasPendentes valida o estado das reservas de uma nota e retorna as
+// que ainda podem ser confirmadas
+func reservasPendentes(reservas []domain.ReservaEstoque, agora time.Time) ([]domain.ReservaEstoque, error) {
+    if len(reservas) == 0 {
+        return nil, domain.ErrReservaNaoEncontrada
+    }
+
+    var pendentes []domain.ReservaEstoque
+    var confirmadas, canceladas, expiradas int
+    for _, r := range reservas {
+        switch {
+        case r.Expirada(agora):
+            if r.Status == domain.StatusReservaPendente {
+                return nil, domain.ErrReservaExpirada
+            }
+            expiradas++
+        case r.Status == domain.StatusReservaPendente:
+            pendentes = append(pendentes, r)
+        case r.Status == domain.StatusReservaConfirmada:
+            confirmadas++
+        case r.Status == domain.StatusReservaCancelada:
+            canceladas++
+        }
+    }
+
+    if len(pendentes) == 0 {
+        switch {
+        case confirmadas > 0:
+            return nil, domain.ErrReservaJaConfirmada
+        case expiradas > 0:
+            return nil, domain.ErrReservaExpirada
+        case canceladas > 0:
+            return nil, domain.ErrReservaJaCancelada
+        }
+    }
+    return pendentes, nil
+}
+
+// itemAgrupado é a quantidade total pedida de um produto em um depósito
+// (depositoID nil quando a escolha fica com a estratégia de alocação), com
+// os números de série informados nos itens
+type itemAgrupado struct {
+    produtoID  uuid.UUID
+    depositoID *uuid.UUID
+    quantidade decimal.Decimal
+    series     []string
+}
+
+// agruparItens soma as quantidades por produto e depósito. Devolve os IDs de
+// produto em ordem crescente, a mesma ordem usada pelo PostgreSQL no
+// ORDER BY id, e os grupos na mesma ordem, com depósitos explícitos antes
+// dos que serão alocados automaticamente.
+func agruparItens(itens []domain.ItemReserva) ([]uuid.UUID, []itemAgrupado) {
+    type chave struct {
+        produto  uuid.UUID
+        deposito uuid.UUID
+    }
+    indices := make(map[chave]int, len(itens))
+    vistos := make(map[uuid.UUID]bool, len(itens))
+    var ids []uuid.UUID
+    var grupos []itemAgrupado
+    for _, item := range itens {
+        k := chave{produto: item.ProdutoID}
+        if item.DepositoID != nil {
+            k.deposito = *item.DepositoID
+        }
+        if i, ok := indices[k]; ok {
+            grupos[i].quantidade = grupos[i].quantidade.Add(item.Quantidade)
+            grupos[i].series = append(grupos[i].series, item.Series...)
+            continue
+        }
+        indices[k] = len(grupos)
+        grupos = append(grupos, itemAgrupado{
+            produtoID:  item.ProdutoID,
+            depositoID: item.DepositoID,
+            quantidade: item.Quantidade,
+            series:     append([]string(nil), item.Series...),
+        })
+        if !vistos[item.ProdutoID] {
+            vistos[item.ProdutoID] = true
+            ids = append(ids, item.ProdutoID)
+        }
+    }
+
+    sort.Slice(ids, func(i, j int) bool {
+        return bytes.Compare(ids[i][:], ids[j][:]) < 0
+    })
+    sort.SliceStable(grupos, func(i, j int) bool {
+        if c := bytes.Compare(grupos[i].produtoID[:], grupos[j].produtoID[:]); c != 0 {
+            return c < 0
+        }
+        return grupos[i].depositoID != nil && grupos[j].depositoID == nil
+    })
+    return ids, grupos
+}
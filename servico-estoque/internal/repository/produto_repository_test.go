@@ -0,0 +1,243 @@
+// internal/repository/produto_repository_test.go
+package repository
+
+import (
+    "errors"
+    "testing"
+    "time"
+
+    "servico-estoque/internal/domain"
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+)
+
+func TestUpdateGravaCadastroEAjusteDeSaldoJuntos(t *testing.T) {
+    db, ctx := abrirBanco(t)
+    repo := novoRepositorio(t, db)
+    p := criarProduto(t, ctx, repo, "P1", 10)
+
+    lido, err := repo.FindByID(ctx, p.ID)
+    if err != nil {
+        t.Fatal(err)
+    }
+    versao := lido.Versao
+    lido.Descricao = "Descrição nova"
+    lido.Fiscal.NCM = "84713012"
+    if err := repo.Update(ctx, lido, &AjusteSaldo{Saldo: dec(t, "25")}, nil); err != nil {
+        t.Fatalf("Update: %v", err)
+    }
+
+    gravado, err := repo.FindByID(ctx, p.ID)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if gravado.Descricao != "Descrição nova" {
+        t.Errorf("descricao = %q, esperada %q", gravado.Descricao, "Descrição nova")
+    }
+    if gravado.Fiscal.NCM != "84713012" {
+        t.Errorf("ncm = %q, esperado %q", gravado.Fiscal.NCM, "84713012")
+    }
+    if !gravado.Saldo.Equal(dec(t, "25")) {
+        t.Errorf("saldo = %s, esperado 25", gravado.Saldo)
+    }
+    if gravado.Versao != versao+1 {
+        t.Errorf("versao = %d, esperada %d", gravado.Versao, versao+1)
+    }
+}
+
+func TestUpdateSubstituiConversoesNaMesmaTransacao(t *testing.T) {
+    db, ctx := abrirBanco(t)
+    repo := novoRepositorio(t, db)
+    p := criarProduto(t, ctx, repo, "P1", 10)
+
+    lido, err := repo.FindByID(ctx, p.ID)
+    if err != nil {
+        t.Fatal(err)
+    }
+    versao := lido.Versao
+    conversoes := []domain.ConversaoUnidade{{Unidade: "CX", Fator: dec(t, "12")}}
+    if err := repo.Update(ctx, lido, nil, &conversoes); err != nil {
+        t.Fatalf("Update: %v", err)
+    }
+
+    gravado, err := repo.FindByID(ctx, p.ID)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if fator, ok := gravado.FatorConversao("CX"); !ok || !fator.Equal(dec(t, "12")) {
+        t.Errorf("conversão CX = %s (%v), esperada 12", fator, ok)
+    }
+
+    // Um ajuste que falha desfaz também a troca de conversões e a versão
+    deposito := uuid.New()
+    outras := []domain.ConversaoUnidade{{Unidade: "PCT", Fator: dec(t, "6")}}
+    err = repo.Update(ctx, gravado, &AjusteSaldo{DepositoID: &deposito, Saldo: dec(t, "5")}, &outras)
+    if !errors.Is(err, domain.ErrDepositoNaoEncontrado) {
+        t.Fatalf("Update com depósito inexistente: erro = %v", err)
+    }
+    depois, err := repo.FindByID(ctx, p.ID)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if depois.Versao != versao+1 {
+        t.Errorf("versao = %d, esperada %d", depois.Versao, versao+1)
+    }
+    if _, ok := depois.FatorConversao("PCT"); ok {
+        t.Error("conversão PCT gravada apesar do erro")
+    }
+    if _, ok := depois.FatorConversao("CX"); !ok {
+        t.Error("conversão CX perdida apesar do erro")
+    }
+
+    // Versão desatualizada não troca as conversões
+    lido.Versao = versao
+    if err := repo.Update(ctx, lido, nil, &outras); !errors.Is(err, domain.ErrVersaoDivergente) {
+        t.Errorf("Update com versão antiga: erro = %v, esperado ErrVersaoDivergente", err)
+    }
+}
+
+func TestReservarEstoqueNaoReservaNadaQuandoUmItemFalta(t *testing.T) {
+    db, ctx := abrirBanco(t)
+    repo := novoRepositorio(t, db)
+    p1 := criarProduto(t, ctx, repo, "P1", 10)
+    p2 := criarProduto(t, ctx, repo, "P2", 3)
+    nota := uuid.New()
+    expira := time.Now().Add(time.Hour)
+
+    _, err := repo.ReservarEstoque(ctx, nota, []domain.ItemReserva{
+        {ProdutoID: p1.ID, Quantidade: dec(t, "5")},
+        {ProdutoID: p2.ID, Quantidade: dec(t, "4")},
+    }, expira)
+    var itensErr *domain.ReservaItensError
+    if !errors.Is(err, domain.ErrEstoqueInsuficiente) || !errors.As(err, &itensErr) {
+        t.Fatalf("erro = %v, esperado ReservaItensError", err)
+    }
+    if len(itensErr.Itens) != 1 || itensErr.Itens[0].ProdutoID != p2.ID || !itensErr.Itens[0].Faltante.Equal(dec(t, "1")) {
+        t.Errorf("itens indisponíveis = %+v, esperado só P2 faltando 1", itensErr.Itens)
+    }
+    if lido, _ := repo.FindByID(ctx, p1.ID); !lido.Reservado.IsZero() {
+        t.Errorf("P1 reservado = %s após reserva recusada", lido.Reservado)
+    }
+
+    reservas, err := repo.ReservarEstoque(ctx, nota, []domain.ItemReserva{
+        {ProdutoID: p1.ID, Quantidade: dec(t, "5")},
+        {ProdutoID: p2.ID, Quantidade: dec(t, "3")},
+    }, expira)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(reservas) != 2 {
+        t.Fatalf("reservas = %d, esperadas 2", len(reservas))
+    }
+    if lido, _ := repo.FindByID(ctx, p1.ID); !lido.Reservado.Equal(dec(t, "5")) || !lido.Saldo.Equal(dec(t, "10")) {
+        t.Errorf("P1 após reserva: saldo %s, reservado %s; esperado 10 e 5", lido.Saldo, lido.Reservado)
+    }
+
+    confirmadas, err := repo.ConfirmarReserva(ctx, nota)
+    if err != nil || confirmadas != 2 {
+        t.Fatalf("ConfirmarReserva = %d, %v; esperadas 2", confirmadas, err)
+    }
+    if lido, _ := repo.FindByID(ctx, p1.ID); !lido.Reservado.IsZero() || !lido.Saldo.Equal(dec(t, "5")) {
+        t.Errorf("P1 após confirmação: saldo %s, reservado %s; esperado 5 e 0", lido.Saldo, lido.Reservado)
+    }
+    if _, err := repo.ConfirmarReserva(ctx, nota); !errors.Is(err, domain.ErrReservaJaConfirmada) {
+        t.Errorf("confirmar de novo = %v, esperado ErrReservaJaConfirmada", err)
+    }
+}
+
+func TestCancelarReservaDevolveODisponivel(t *testing.T) {
+    db, ctx := abrirBanco(t)
+    repo := novoRepositorio(t, db)
+    p := criarProduto(t, ctx, repo, "P1", 10)
+    nota := uuid.New()
+
+    if _, err := repo.ReservarEstoque(ctx, nota, []domain.ItemReserva{{ProdutoID: p.ID, Quantidade: dec(t, "4")}}, time.Now().Add(time.Hour)); err != nil {
+        t.Fatal(err)
+    }
+    canceladas, err := repo.CancelarReserva(ctx, nota)
+    if err != nil || canceladas != 1 {
+        t.Fatalf("CancelarReserva = %d, %v; esperada 1", canceladas, err)
+    }
+    lido, err := repo.FindByID(ctx, p.ID)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !lido.Reservado.IsZero() || !lido.Saldo.Equal(dec(t, "10")) {
+        t.Errorf("após cancelamento: saldo %s, reservado %s; esperado 10 e 0", lido.Saldo, lido.Reservado)
+    }
+    if canceladas, err := repo.CancelarReserva(ctx, nota); err != nil || canceladas != 0 {
+        t.Errorf("cancelar de novo = %d, %v; esperado nenhuma", canceladas, err)
+    }
+}
+
+func TestDeleteRecusaProdutoComReservaPendente(t *testing.T) {
+    db, ctx := abrirBanco(t)
+    repo := novoRepositorio(t, db)
+    p := criarProduto(t, ctx, repo, "P1", 10)
+    nota := uuid.New()
+
+    if _, err := repo.ReservarEstoque(ctx, nota, []domain.ItemReserva{{ProdutoID: p.ID, Quantidade: dec(t, "1")}}, time.Now().Add(time.Hour)); err != nil {
+        t.Fatal(err)
+    }
+    lido, err := repo.FindByID(ctx, p.ID)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := repo.Delete(ctx, p.ID, lido.Versao); !errors.Is(err, domain.ErrOperacaoNaoPermitida) {
+        t.Fatalf("Delete com reserva pendente = %v, esperado ErrOperacaoNaoPermitida", err)
+    }
+
+    if _, err := repo.CancelarReserva(ctx, nota); err != nil {
+        t.Fatal(err)
+    }
+    lido, err = repo.FindByID(ctx, p.ID)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := repo.Delete(ctx, p.ID, lido.Versao); err != nil {
+        t.Errorf("Delete após cancelar a reserva = %v", err)
+    }
+}
+
+func TestExpirarReservasEncerraReservaDeProdutoRemovido(t *testing.T) {
+    db, ctx := abrirBanco(t)
+    repo := novoRepositorio(t, db)
+    orfao := criarProduto(t, ctx, repo, "P1", 10)
+    p := criarProduto(t, ctx, repo, "P2", 10)
+    vencida := time.Now().Add(-time.Minute)
+
+    reservas, err := repo.ReservarEstoque(ctx, uuid.New(), []domain.ItemReserva{
+        {ProdutoID: orfao.ID, Quantidade: dec(t, "1")},
+        {ProdutoID: p.ID, Quantidade: dec(t, "2")},
+    }, vencida)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // Produto removido por fora, como antes da verificação de Delete
+    if err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+        if err := tx.Delete(&domain.SaldoDeposito{}, "produto_id = ?", orfao.ID).Error; err != nil {
+            return err
+        }
+        return tx.Delete(&domain.Produto{}, "id = ?", orfao.ID).Error
+    }); err != nil {
+        t.Fatal(err)
+    }
+
+    n, err := repo.ExpirarReservas(ctx, time.Now(), 10)
+    if err != nil || n != 2 {
+        t.Fatalf("ExpirarReservas = %d, %v; esperadas 2 sem erro", n, err)
+    }
+    for _, r := range reservas {
+        var gravada domain.ReservaEstoque
+        if err := db.WithContext(ctx).First(&gravada, "id = ?", r.ID).Error; err != nil {
+            t.Fatal(err)
+        }
+        if gravada.Status != domain.StatusReservaExpirada {
+            t.Errorf("reserva de %s com status %s, esperado %s", r.ProdutoID, gravada.Status, domain.StatusReservaExpirada)
+        }
+    }
+    if lido, _ := repo.FindByID(ctx, p.ID); !lido.Reservado.IsZero() {
+        t.Errorf("P2 reservado = %s após expirar, esperado 0", lido.Reservado)
+    }
+}
@@ -0,0 +1,257 @@
+// internal/repository/saldo_deposito.go
+package repository
+
+import (
+    "time"
+
+    "servico-estoque/internal/domain"
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+)
+
+// estoqueTravado guarda os produtos, saldos por depósito e lotes travados com
+// SELECT ... FOR UPDATE dentro de uma transação
+type estoqueTravado struct {
+    produtos  map[uuid.UUID]*domain.Produto
+    saldos    map[uuid.UUID][]*domain.SaldoDeposito
+    lotes     map[uuid.UUID][]*domain.Lote
+    depositos map[uuid.UUID]domain.Deposito
+}
+
+func travarProduto(tx *gorm.DB, id uuid.UUID) (*domain.Produto, error) {
+    var p domain.Produto
+    if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+        First(&p, "id = ?", id).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            return nil, domain.ErrProdutoNaoEncontrado
+        }
+        return nil, err
+    }
+    return &p, nil
+}
+
+// travarEstoque trava os produtos, seus saldos por depósito e seus lotes,
+// sempre em ordem de ID, para evitar deadlocks entre transações concorrentes.
+// Os lotes de cada produto ficam em ordem de validade (FEFO).
+func travarEstoque(tx *gorm.DB, ids []uuid.UUID) (*estoqueTravado, error) {
+    var produtos []domain.Produto
+    if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+        Where("id IN ?", ids).
+        Order("id").
+        Find(&produtos).Error; err != nil {
+        return nil, err
+    }
+
+    var saldos []domain.SaldoDeposito
+    if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+        Where("produto_id IN ?", ids).
+        Order("produto_id, deposito_id").
+        Find(&saldos).Error; err != nil {
+        return nil, err
+    }
+
+    var lotes []domain.Lote
+    if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+        Where("produto_id IN ?", ids).
+        Order("produto_id, validade, numero, id").
+        Find(&lotes).Error; err != nil {
+        return nil, err
+    }
+
+    var depositos []domain.Deposito
+    if err := tx.Find(&depositos).Error; err != nil {
+        return nil, err
+    }
+
+    e := &estoqueTravado{
+        produtos:  make(map[uuid.UUID]*domain.Produto, len(produtos)),
+        saldos:    make(map[uuid.UUID][]*domain.SaldoDeposito, len(produtos)),
+        lotes:     make(map[uuid.UUID][]*domain.Lote),
+        depositos: make(map[uuid.UUID]domain.Deposito, len(depositos)),
+    }
+    for i := range produtos {
+        e.produtos[produtos[i].ID] = &produtos[i]
+    }
+    for i := range saldos {
+        e.saldos[saldos[i].ProdutoID] = append(e.saldos[saldos[i].ProdutoID], &saldos[i])
+    }
+    for i := range lotes {
+        e.lotes[lotes[i].ProdutoID] = append(e.lotes[lotes[i].ProdutoID], &lotes[i])
+    }
+    for _, d := range depositos {
+        e.depositos[d.ID] = d
+    }
+    return e, nil
+}
+
+// controlaLote indica se o produto travado movimenta saldo por lote
+func (e *estoqueTravado) controlaLote(produtoID uuid.UUID) bool {
+    p, ok := e.produtos[produtoID]
+    return ok && p.ControlaLote
+}
+
+// controlaSerie indica se o produto travado movimenta saldo por número de série
+func (e *estoqueTravado) controlaSerie(produtoID uuid.UUID) bool {
+    p, ok := e.produtos[produtoID]
+    return ok && p.ControlaSerie
+}
+
+// lotesDoDeposito retorna os lotes do produto no depósito, em ordem FEFO
+func (e *estoqueTravado) lotesDoDeposito(produtoID, depositoID uuid.UUID) []*domain.Lote {
+    var lotes []*domain.Lote
+    for _, l := range e.lotes[produtoID] {
+        if l.DepositoID == depositoID {
+            lotes = append(lotes, l)
+        }
+    }
+    return lotes
+}
+
+// disponivelLotes é o quanto os lotes válidos do saldo ainda podem atender.
+// Substitui o disponível do depósito para produtos controlados por lote.
+func (e *estoqueTravado) disponivelLotes(s *domain.SaldoDeposito, agora time.Time) decimal.Decimal {
+    total := decimal.Zero
+    for _, l := range e.lotesDoDeposito(s.ProdutoID, s.DepositoID) {
+        total = total.Add(l.Disponivel(agora))
+    }
+    return total
+}
+
+// apenasVencidos indica se a falta de estoque de um produto controlado por
+// lote se deve a lotes vencidos, isto é, se os vencidos cobririam a diferença
+func (e *estoqueTravado) apenasVencidos(falha *domain.ItemIndisponivel, agora time.Time) bool {
+    vencido := decimal.Zero
+    for _, l := range e.lotes[falha.ProdutoID] {
+        if falha.DepositoID != nil && l.DepositoID != *falha.DepositoID {
+            continue
+        }
+        if l.Vencido(agora) {
+            vencido = vencido.Add(l.Saldo.Sub(l.Reservado))
+        }
+    }
+    return vencido.IsPositive() && vencido.GreaterThanOrEqual(falha.Faltante)
+}
+
+// consumirLotes distribui a quantidade pelos lotes do depósito em ordem FEFO,
+// aplica cada parte com aplicar e grava os lotes alterados. Retorna
+// ErrEstoqueInsuficiente se os lotes válidos não bastarem.
+func (e *estoqueTravado) consumirLotes(tx *gorm.DB, s *domain.SaldoDeposito, quantidade decimal.Decimal, agora time.Time, aplicar func(*domain.Lote, decimal.Decimal)) ([]domain.ReservaLote, error) {
+    lotes := e.lotesDoDeposito(s.ProdutoID, s.DepositoID)
+    alocacoes, ok := domain.AlocarFEFO(lotes, quantidade, agora)
+    if !ok {
+        return nil, domain.ErrEstoqueInsuficiente
+    }
+    for _, a := range alocacoes {
+        for _, l := range lotes {
+            if l.ID != a.LoteID {
+                continue
+            }
+            aplicar(l, a.Quantidade)
+            if err := tx.Save(l).Error; err != nil {
+                return nil, err
+            }
+        }
+    }
+    return alocacoes, nil
+}
+
+// alocar escolhe o saldo por depósito que atende o item. Quando o item não
+// informa depósito, a decisão fica com a estratégia de alocação. disponivel
+// define quanto de cada saldo pode ser usado (o disponível para reservas, o
+// saldo físico para baixas).
+func (r *produtoRepository) alocar(e *estoqueTravado, g itemAgrupado, disponivel func(*domain.SaldoDeposito) decimal.Decimal) (*domain.SaldoDeposito, *domain.ItemIndisponivel) {
+    falha := func(motivo string, depositoID *uuid.UUID, disp decimal.Decimal) *domain.ItemIndisponivel {
+        return novaFalha(g, motivo, depositoID, disp)
+    }
+
+    if _, ok := e.produtos[g.produtoID]; !ok {
+        return nil, falha(domain.ErrProdutoNaoEncontrado.Error(), g.depositoID, decimal.Zero)
+    }
+    saldos := e.saldos[g.produtoID]
+
+    if g.depositoID != nil {
+        if _, ok := e.depositos[*g.depositoID]; !ok {
+            return nil, falha(domain.ErrDepositoNaoEncontrado.Error(), g.depositoID, decimal.Zero)
+        }
+        for _, s := range saldos {
+            if s.DepositoID == *g.depositoID {
+                if disponivel(s).LessThan(g.quantidade) {
+                    return nil, falha(domain.ErrEstoqueInsuficiente.Error(), g.depositoID, disponivel(s))
+                }
+                return s, nil
+            }
+        }
+        return nil, falha(domain.ErrEstoqueInsuficiente.Error(), g.depositoID, decimal.Zero)
+    }
+
+    opcoes := make([]domain.OpcaoDeposito, 0, len(saldos))
+    for _, s := range saldos {
+        d := e.depositos[s.DepositoID]
+        opcoes = append(opcoes, domain.OpcaoDeposito{
+            DepositoID: s.DepositoID,
+            Codigo:     d.Codigo,
+            Padrao:     d.Padrao,
+            Disponivel: disponivel(s),
+        })
+    }
+
+    id, ok := r.alocacao.Escolher(opcoes, g.quantidade)
+    for _, s := range saldos {
+        if s.DepositoID == id {
+            if ok {
+                return s, nil
+            }
+            return nil, falha(domain.ErrEstoqueInsuficiente.Error(), &s.DepositoID, disponivel(s))
+        }
+    }
+    return nil, falha(domain.ErrEstoqueInsuficiente.Error(), nil, decimal.Zero)
+}
+
+func novaFalha(g itemAgrupado, motivo string, depositoID *uuid.UUID, disponivel decimal.Decimal) *domain.ItemIndisponivel {
+    return &domain.ItemIndisponivel{
+        ProdutoID:  g.produtoID,
+        DepositoID: depositoID,
+        Solicitado: g.quantidade,
+        Disponivel: disponivel,
+        Faltante:   g.quantidade.Sub(disponivel),
+        Motivo:     motivo,
+    }
+}
+
+// erroDaFalha converte o motivo de uma falha de alocação no erro de domínio
+// correspondente, para operações de um item só
+func erroDaFalha(f *domain.ItemIndisponivel) error {
+    for _, err := range []error{
+        domain.ErrProdutoNaoEncontrado,
+        domain.ErrDepositoNaoEncontrado,
+        domain.ErrLoteVencido,
+        domain.ErrProdutoSemSerie,
+        domain.ErrSerieNaoEncontrada,
+        domain.ErrSerieIndisponivel,
+        domain.ErrQuantidadeInvalida,
+        domain.ErrDadosInvalidos,
+    } {
+        if f.Motivo == err.Error() {
+            return err
+        }
+    }
+    return domain.ErrEstoqueInsuficiente
+}
+
+// resolverDeposito retorna o depósito informado ou o depósito padrão
+func resolverDeposito(tx *gorm.DB, id *uuid.UUID) (*domain.Deposito, error) {
+    var d domain.Deposito
+    q := tx.Where("padrao = ?", true)
+    if id != nil {
+        q = tx.Where("id = ?", *id)
+    }
+    if err := q.First(&d).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            return nil, domain.ErrDepositoNaoEncontrado
+        }
+        return nil, err
+    }
+    return &d, nil
+}
@@ -0,0 +1,266 @@
+// internal/repository/serie_repository.go
+package repository
+
+import (
+    "context"
+    "fmt"
+
+    "servico-estoque/internal/domain"
+    "github.com/google/uuid"
+    "github.com/shopspring/decimal"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+)
+
+// EntradaSeries cadastra as unidades informadas como disponíveis no depósito
+// e soma a quantidade ao saldo do produto
+func (r *produtoRepository) EntradaSeries(ctx context.Context, produtoID uuid.UUID, depositoID *uuid.UUID, numeros []string, custoUnitario *decimal.Decimal, motivo string) ([]domain.NumeroSerie, error) {
+    var series []domain.NumeroSerie
+    err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+        p, err := travarProduto(tx, produtoID)
+        if err != nil {
+            return err
+        }
+        if !p.ControlaSerie {
+            return domain.ErrProdutoSemSerie
+        }
+
+        deposito, err := resolverDeposito(tx, depositoID)
+        if err != nil {
+            return err
+        }
+
+        vistos := make(map[string]bool, len(numeros))
+        for _, n := range numeros {
+            if vistos[n] {
+                return domain.ErrSerieDuplicada
+            }
+            vistos[n] = true
+        }
+        var existentes int64
+        if err := tx.Model(&domain.NumeroSerie{}).
+            Where("produto_id = ? AND numero IN ?", p.ID, numeros).
+            Count(&existentes).Error; err != nil {
+            return err
+        }
+        if existentes > 0 {
+            return domain.ErrSerieDuplicada
+        }
+
+        series = make([]domain.NumeroSerie, len(numeros))
+        for i, n := range numeros {
+            series[i] = domain.NumeroSerie{
+                ProdutoID:  p.ID,
+                Numero:     n,
+                DepositoID: deposito.ID,
+                Status:     domain.SerieDisponivel,
+            }
+        }
+        if err := tx.Create(&series).Error; err != nil {
+            return err
+        }
+        if err := marcarSeries(tx, series, domain.SerieDisponivel, nil, nil, "entrada"); err != nil {
+            return err
+        }
+
+        saldo := domain.SaldoDeposito{ProdutoID: p.ID, DepositoID: deposito.ID}
+        if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+            FirstOrCreate(&saldo, "produto_id = ? AND deposito_id = ?", p.ID, deposito.ID).Error; err != nil {
+            return err
+        }
+        quantidade := decimal.NewFromInt(int64(len(series)))
+        saldo.Saldo = saldo.Saldo.Add(quantidade)
+        if err := tx.Save(&saldo).Error; err != nil {
+            return err
+        }
+
+        var custo decimal.Decimal
+        if custoUnitario != nil {
+            custo = *custoUnitario
+            p.EntradaComCusto(quantidade, custo)
+        }
+
+        saldoAnterior := p.Saldo
+        p.Saldo = p.Saldo.Add(quantidade)
+        if err := tx.Omit(clause.Associations).Save(p).Error; err != nil {
+            return err
+        }
+
+        if motivo == "" {
+            motivo = fmt.Sprintf("entrada de %d número(s) de série", len(series))
+        }
+        return registrarMovimento(tx, p, saldoAnterior, domain.MovimentoEstoque{
+            Tipo:          domain.MovimentoEntrada,
+            Quantidade:    quantidade,
+            CustoUnitario: custo,
+            DepositoID:    &deposito.ID,
+            Motivo:        motivo,
+        })
+    })
+    if err != nil {
+        return nil, err
+    }
+    return series, nil
+}
+
+// FindSerie retorna um número de série do produto com todo o seu histórico
+func (r *produtoRepository) FindSerie(ctx context.Context, produtoID uuid.UUID, numero string) (*domain.NumeroSerie, error) {
+    var s domain.NumeroSerie
+    if err := r.db.WithContext(ctx).
+        Preload("Historico", func(db *gorm.DB) *gorm.DB {
+            return db.Order("created_at, id")
+        }).
+        First(&s, "produto_id = ? AND numero = ?", produtoID, numero).Error; err != nil {
+        if err == gorm.ErrRecordNotFound {
+            return nil, domain.ErrSerieNaoEncontrada
+        }
+        return nil, err
+    }
+    return &s, nil
+}
+
+// separarSeries trava as unidades que atendem o grupo: as séries informadas
+// no item ou, sem elas, as disponíveis mais antigas do depósito alocado.
+// Problemas com o item voltam como falha; erros de banco, como error.
+func (r *produtoRepository) separarSeries(tx *gorm.DB, e *estoqueTravado, g itemAgrupado) (*domain.SaldoDeposito, []domain.NumeroSerie, *domain.ItemIndisponivel, error) {
+    falha := func(motivo error, numeros []string) *domain.ItemIndisponivel {
+        f := novaFalha(g, motivo.Error(), g.depositoID, decimal.Zero)
+        f.Series = numeros
+        return f
+    }
+
+    // Unidades com série não se fracionam
+    if !g.quantidade.IsInteger() {
+        return nil, nil, falha(domain.ErrQuantidadeInvalida, nil), nil
+    }
+    quantidade := int(g.quantidade.IntPart())
+
+    if len(g.series) == 0 {
+        saldo, falha := r.alocar(e, g, (*domain.SaldoDeposito).Disponivel)
+        if falha != nil {
+            return nil, nil, falha, nil
+        }
+
+        var series []domain.NumeroSerie
+        if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+            Where("produto_id = ? AND deposito_id = ? AND status = ?", g.produtoID, saldo.DepositoID, domain.SerieDisponivel).
+            Order("created_at, numero").
+            Limit(quantidade).
+            Find(&series).Error; err != nil {
+            return nil, nil, nil, err
+        }
+        if len(series) < quantidade {
+            return nil, nil, novaFalha(g, domain.ErrEstoqueInsuficiente.Error(), &saldo.DepositoID, decimal.NewFromInt(int64(len(series)))), nil
+        }
+        return saldo, series, nil, nil
+    }
+
+    if len(g.series) != quantidade {
+        return nil, nil, falha(domain.ErrQuantidadeInvalida, g.series), nil
+    }
+    vistos := make(map[string]bool, len(g.series))
+    for _, n := range g.series {
+        if vistos[n] {
+            return nil, nil, falha(domain.ErrDadosInvalidos, []string{n}), nil
+        }
+        vistos[n] = true
+    }
+
+    var series []domain.NumeroSerie
+    if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+        Where("produto_id = ? AND numero IN ?", g.produtoID, g.series).
+        Order("numero").
+        Find(&series).Error; err != nil {
+        return nil, nil, nil, err
+    }
+
+    encontradas := make(map[string]bool, len(series))
+    var indisponiveis []string
+    for _, s := range series {
+        encontradas[s.Numero] = true
+        if s.Status != domain.SerieDisponivel ||
+            (g.depositoID != nil && s.DepositoID != *g.depositoID) ||
+            s.DepositoID != series[0].DepositoID {
+            indisponiveis = append(indisponiveis, s.Numero)
+        }
+    }
+    var faltantes []string
+    for _, n := range g.series {
+        if !encontradas[n] {
+            faltantes = append(faltantes, n)
+        }
+    }
+    if len(faltantes) > 0 {
+        return nil, nil, falha(domain.ErrSerieNaoEncontrada, faltantes), nil
+    }
+    if len(indisponiveis) > 0 {
+        return nil, nil, falha(domain.ErrSerieIndisponivel, indisponiveis), nil
+    }
+
+    // O depósito é o das séries informadas
+    deposito := series[0].DepositoID
+    g.depositoID = &deposito
+    saldo, f := r.alocar(e, g, (*domain.SaldoDeposito).Disponivel)
+    return saldo, series, f, nil
+}
+
+// liberarSeries devolve as unidades da reserva ao disponível ou, com
+// baixar, as marca como vendidas na nota da reserva
+func liberarSeries(tx *gorm.DB, r *domain.ReservaEstoque, baixar bool) error {
+    var series []domain.NumeroSerie
+    if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+        Where("reserva_id = ? AND status = ?", r.ID, domain.SerieReservada).
+        Order("numero").
+        Find(&series).Error; err != nil {
+        return err
+    }
+    if len(series) == 0 {
+        return nil
+    }
+
+    if baixar {
+        return marcarSeries(tx, series, domain.SerieVendida, &r.ID, &r.NotaFiscalID, "reserva confirmada")
+    }
+    return marcarSeries(tx, series, domain.SerieDisponivel, &r.ID, &r.NotaFiscalID, "reserva liberada")
+}
+
+// marcarSeries muda o status das unidades e registra a mudança no histórico
+// de cada uma
+func marcarSeries(tx *gorm.DB, series []domain.NumeroSerie, status string, reservaID, notaID *uuid.UUID, motivo string) error {
+    for i := range series {
+        s := &series[i]
+        s.Status = status
+        switch status {
+        case domain.SerieDisponivel:
+            s.ReservaID = nil
+        case domain.SerieReservada:
+            s.ReservaID = reservaID
+        case domain.SerieVendida:
+            s.ReservaID = reservaID
+            s.NotaFiscalID = notaID
+        }
+        if err := tx.Omit(clause.Associations).Save(s).Error; err != nil {
+            return err
+        }
+
+        if err := tx.Create(&domain.HistoricoSerie{
+            SerieID:      s.ID,
+            Status:       status,
+            DepositoID:   s.DepositoID,
+            ReservaID:    reservaID,
+            NotaFiscalID: notaID,
+            Motivo:       motivo,
+        }).Error; err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func numerosDe(series []domain.NumeroSerie) []string {
+    numeros := make([]string, len(series))
+    for i, s := range series {
+        numeros[i] = s.Numero
+    }
+    return numeros
+}
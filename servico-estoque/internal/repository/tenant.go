@@ -0,0 +1,180 @@
+// internal/repository/tenant.go
+package repository
+
+import (
+    "context"
+    "reflect"
+
+    "servico-estoque/internal/domain"
+    "servico-estoque/internal/tenant"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+    "gorm.io/gorm/schema"
+)
+
+// campoTenant é o campo que identifica a empresa em todos os modelos
+const campoTenant = "TenantID"
+
+// IsolamentoTenant é o plugin do GORM que restringe cada operação à empresa
+// do contexto (tenant.Com): consultas, alterações e exclusões em modelos com
+// TenantID recebem a condição tenant_id = empresa e inserções têm o campo
+// preenchido. Sem empresa no contexto a operação falha com
+// ErrTenantAusente, exceto em contextos marcados com tenant.Sistema, que
+// enxergam todas as empresas. SQL escrito à mão (Raw e Exec) não passa pelo
+// plugin e precisa filtrar tenant_id por conta própria.
+type IsolamentoTenant struct{}
+
+func (IsolamentoTenant) Name() string {
+    return "isolamento_tenant"
+}
+
+func (IsolamentoTenant) Initialize(db *gorm.DB) error {
+    cb := db.Callback()
+    if err := cb.Create().Before("gorm:create").Register("tenant:preencher", preencherTenant); err != nil {
+        return err
+    }
+    if err := cb.Query().Before("gorm:query").Register("tenant:filtrar", filtrarTenant); err != nil {
+        return err
+    }
+    if err := cb.Row().Before("gorm:row").Register("tenant:filtrar", filtrarTenant); err != nil {
+        return err
+    }
+    if err := cb.Update().Before("gorm:update").Register("tenant:filtrar", filtrarTenant); err != nil {
+        return err
+    }
+    return cb.Delete().Before("gorm:delete").Register("tenant:filtrar", filtrarTenant)
+}
+
+// preencherTenant grava a empresa do contexto nos registros inseridos
+func preencherTenant(db *gorm.DB) {
+    campo, id, ok := tenantDaOperacao(db)
+    if !ok || id == "" {
+        return
+    }
+    atribuirTenant(db, campo, id)
+}
+
+// filtrarTenant restringe a operação à empresa do contexto. Em alterações a
+// empresa também é regravada no registro, para que um Save de um registro
+// sem TenantID (vindo do cache, por exemplo) não o tire da empresa.
+func filtrarTenant(db *gorm.DB) {
+    campo, id, ok := tenantDaOperacao(db)
+    if !ok || id == "" {
+        return
+    }
+    db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+        clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: campo.DBName}, Value: id},
+    }})
+    atribuirTenant(db, campo, id)
+}
+
+// tenantDaOperacao retorna o campo TenantID do modelo e a empresa do
+// contexto. ok é false quando o modelo não tem o campo; id é vazio em
+// contextos de sistema.
+func tenantDaOperacao(db *gorm.DB) (*schema.Field, string, bool) {
+    if db.Error != nil || db.Statement.Schema == nil {
+        return nil, "", false
+    }
+    campo := db.Statement.Schema.LookUpField(campoTenant)
+    if campo == nil {
+        return nil, "", false
+    }
+    ctx := db.Statement.Context
+    if tenant.EhSistema(ctx) {
+        return campo, "", true
+    }
+    id, ok := tenant.De(ctx)
+    if !ok {
+        db.AddError(domain.ErrTenantAusente)
+        return nil, "", false
+    }
+    return campo, id, true
+}
+
+func atribuirTenant(db *gorm.DB, campo *schema.Field, id string) {
+    ctx := db.Statement.Context
+    rv := db.Statement.ReflectValue
+    switch rv.Kind() {
+    case reflect.Slice, reflect.Array:
+        for i := 0; i < rv.Len(); i++ {
+            if item := reflect.Indirect(rv.Index(i)); item.Kind() == reflect.Struct {
+                campo.Set(ctx, item, id)
+            }
+        }
+    case reflect.Struct:
+        if rv.Type() == db.Statement.Schema.ModelType {
+            campo.Set(ctx, rv, id)
+        }
+    }
+}
+
+// tenantDe retorna a empresa do contexto, para as consultas escritas à mão
+func tenantDe(ctx context.Context) (string, error) {
+    id, ok := tenant.De(ctx)
+    if !ok {
+        return "", domain.ErrTenantAusente
+    }
+    return id, nil
+}
+
+// indicesGlobais são os índices únicos de antes do isolamento por empresa,
+// substituídos pelos índices por (tenant_id, codigo)
+var indicesGlobais = []struct {
+    modelo any
+    nome   string
+}{
+    {&domain.Produto{}, "idx_produtos_codigo"},
+    {&domain.Deposito{}, "idx_depositos_codigo"},
+    {&domain.Categoria{}, "idx_categorias_codigo"},
+}
+
+// modelosTenant são todos os modelos com TenantID
+var modelosTenant = []any{
+    &domain.Deposito{},
+    &domain.Categoria{},
+    &domain.Produto{},
+    &domain.ConversaoUnidade{},
+    &domain.SaldoDeposito{},
+    &domain.Lote{},
+    &domain.ReservaEstoque{},
+    &domain.ReservaLote{},
+    &domain.NumeroSerie{},
+    &domain.HistoricoSerie{},
+    &domain.MovimentoEstoque{},
+    &domain.BaixaEstoque{},
+    &domain.ItemBaixa{},
+    &domain.LoteBaixa{},
+    &domain.ChaveAPI{},
+}
+
+// MigrarTenants completa a migração para o isolamento por empresa, depois
+// do AutoMigrate: remove os índices únicos globais e, se legado for
+// informado, atribui a essa empresa os registros gravados antes da coluna
+// tenant_id existir. Sem legado, esses registros ficam inacessíveis.
+func MigrarTenants(ctx context.Context, db *gorm.DB, legado string) error {
+    m := db.WithContext(tenant.Sistema(ctx)).Migrator()
+    for _, i := range indicesGlobais {
+        if m.HasIndex(i.modelo, i.nome) {
+            if err := m.DropIndex(i.modelo, i.nome); err != nil {
+                return err
+            }
+        }
+    }
+    if legado == "" {
+        return nil
+    }
+    legado, err := domain.NormalizarCNPJ(legado)
+    if err != nil {
+        return err
+    }
+
+    return db.WithContext(tenant.Sistema(ctx)).Transaction(func(tx *gorm.DB) error {
+        for _, modelo := range modelosTenant {
+            if err := tx.Model(modelo).Where("tenant_id = ''").
+                UpdateColumn("tenant_id", legado).Error; err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+}
@@ -0,0 +1,72 @@
+// internal/repository/tenant_test.go
+package repository
+
+import (
+    "context"
+    "errors"
+    "testing"
+
+    "servico-estoque/internal/domain"
+)
+
+func TestProdutoDeOutraEmpresaNaoEVisivel(t *testing.T) {
+    db, empresaA := abrirBanco(t)
+    empresaB := novaEmpresa(t, db)
+    repo := novoRepositorio(t, db)
+    p := criarProduto(t, empresaA, repo, "P1", 10)
+
+    if _, err := repo.FindByID(empresaB, p.ID); !errors.Is(err, domain.ErrProdutoNaoEncontrado) {
+        t.Errorf("FindByID de outra empresa: err = %v, esperado ErrProdutoNaoEncontrado", err)
+    }
+    if achado, err := repo.FindByCodigo(empresaB, "P1"); err != nil || achado != nil {
+        t.Errorf("FindByCodigo de outra empresa = %v, %v, esperado nenhum produto", achado, err)
+    }
+    produtos, total, err := repo.FindAll(empresaB, domain.FiltroProdutos{Page: 1, Size: 10})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if total != 0 || len(produtos) != 0 {
+        t.Errorf("FindAll de outra empresa listou %d produtos (total %d)", len(produtos), total)
+    }
+}
+
+func TestProdutoDeOutraEmpresaNaoEAlteradoNemExcluido(t *testing.T) {
+    db, empresaA := abrirBanco(t)
+    empresaB := novaEmpresa(t, db)
+    repo := novoRepositorio(t, db)
+    p := criarProduto(t, empresaA, repo, "P1", 10)
+
+    lido, err := repo.FindByID(empresaA, p.ID)
+    if err != nil {
+        t.Fatal(err)
+    }
+    alterado := *lido
+    alterado.Descricao = "Alterado por outra empresa"
+    if err := repo.Update(empresaB, &alterado, &AjusteSaldo{Saldo: dec(t, "99")}, nil); !errors.Is(err, domain.ErrProdutoNaoEncontrado) {
+        t.Errorf("Update de outra empresa: err = %v, esperado ErrProdutoNaoEncontrado", err)
+    }
+    if err := repo.Delete(empresaB, p.ID, lido.Versao); !errors.Is(err, domain.ErrProdutoNaoEncontrado) {
+        t.Errorf("Delete de outra empresa: err = %v, esperado ErrProdutoNaoEncontrado", err)
+    }
+
+    gravado, err := repo.FindByID(empresaA, p.ID)
+    if err != nil {
+        t.Fatalf("produto sumiu da própria empresa: %v", err)
+    }
+    if gravado.Descricao != lido.Descricao || !gravado.Saldo.Equal(dec(t, "10")) || gravado.Versao != lido.Versao {
+        t.Errorf("produto alterado por outra empresa: %+v", gravado)
+    }
+}
+
+func TestOperacaoSemEmpresaFalha(t *testing.T) {
+    db, empresaA := abrirBanco(t)
+    repo := novoRepositorio(t, db)
+    p := criarProduto(t, empresaA, repo, "P1", 10)
+
+    if _, err := repo.FindByID(context.Background(), p.ID); !errors.Is(err, domain.ErrTenantAusente) {
+        t.Errorf("FindByID sem empresa: err = %v, esperado ErrTenantAusente", err)
+    }
+    if err := repo.Delete(context.Background(), p.ID, p.Versao); !errors.Is(err, domain.ErrTenantAusente) {
+        t.Errorf("Delete sem empresa: err = %v, esperado ErrTenantAusente", err)
+    }
+}
@@ -0,0 +1,83 @@
+// internal/repository/valorizacao_repository.go
+package repository
+
+import (
+    "context"
+    "time"
+
+    "servico-estoque/internal/domain"
+)
+
+// posicaoAtual lê o saldo atual de cada produto por depósito
+const posicaoAtual = `
+SELECT s.produto_id, p.codigo, p.descricao, s.deposito_id, d.codigo AS deposito_codigo,
+       s.saldo, p.custo_medio
+FROM saldo_depositos s
+JOIN produtos p ON p.id = s.produto_id
+JOIN depositos d ON d.id = s.deposito_id
+WHERE s.tenant_id = @tenant AND s.saldo <> 0
+ORDER BY p.codigo, p.id, d.codigo`
+
+// posicaoEmData parte do saldo atual por depósito e desfaz as variações de
+// saldo registradas no kardex depois da data. Assim o saldo que nunca passou
+// pelo kardex (anterior a ele ou migrado para o depósito padrão) também
+// entra na posição; movimentos sem depósito, anteriores aos depósitos, são
+// do depósito padrão. O custo médio é o do último movimento do produto até
+// a data ou, sem movimento até lá, o custo médio atual.
+const posicaoEmData = `
+WITH padrao AS (
+    SELECT id FROM depositos WHERE tenant_id = @tenant AND padrao LIMIT 1
+), posteriores AS (
+    SELECT produto_id, COALESCE(deposito_id, (SELECT id FROM padrao)) AS deposito_id,
+           SUM(saldo_posterior - saldo_anterior) AS variacao
+    FROM movimento_estoques
+    WHERE tenant_id = @tenant AND created_at > @data
+    GROUP BY 1, 2
+), custos AS (
+    SELECT DISTINCT ON (produto_id) produto_id, custo_medio
+    FROM movimento_estoques
+    WHERE tenant_id = @tenant AND created_at <= @data
+    ORDER BY produto_id, created_at DESC
+)
+SELECT s.produto_id, p.codigo, p.descricao, s.deposito_id, d.codigo AS deposito_codigo,
+       s.saldo - COALESCE(v.variacao, 0) AS saldo, COALESCE(c.custo_medio, p.custo_medio) AS custo_medio
+FROM saldo_depositos s
+JOIN produtos p ON p.id = s.produto_id
+JOIN depositos d ON d.id = s.deposito_id
+LEFT JOIN posteriores v ON v.produto_id = s.produto_id AND v.deposito_id = s.deposito_id
+LEFT JOIN custos c ON c.produto_id = s.produto_id
+WHERE s.tenant_id = @tenant AND s.saldo - COALESCE(v.variacao, 0) <> 0
+ORDER BY p.codigo, p.id, d.codigo`
+
+// PosicoesEstoque retorna o saldo e o custo médio por produto e depósito,
+// atuais (data nil) ou ao fim da data informada, ordenados por produto
+func (r *produtoRepository) PosicoesEstoque(ctx context.Context, data *time.Time) ([]domain.PosicaoEstoque, error) {
+    empresa, err := tenantDe(ctx)
+    if err != nil {
+        return nil, err
+    }
+
+    var posicoes []domain.PosicaoEstoque
+    q := r.db.WithContext(ctx).Raw(posicaoAtual, map[string]any{"tenant": empresa})
+    if data != nil {
+        q = r.db.WithContext(ctx).Raw(posicaoEmData, map[string]any{"data": *data, "tenant": empresa})
+    }
+    if err := q.Scan(&posicoes).Error; err != nil {
+        return nil, err
+    }
+    return posicoes, nil
+}
+
+// TotaisEstoque soma o reservado e o disponível dos produtos por empresa.
+// Lê todas as empresas, independentemente do contexto.
+func (r *produtoRepository) TotaisEstoque(ctx context.Context) ([]domain.TotalEstoque, error) {
+    var totais []domain.TotalEstoque
+    if err := r.db.WithContext(ctx).Raw(`
+        SELECT tenant_id, COALESCE(SUM(reservado), 0) AS reservado,
+               COALESCE(SUM(saldo - reservado), 0) AS disponivel
+        FROM produtos
+        GROUP BY tenant_id`).Scan(&totais).Error; err != nil {
+        return nil, err
+    }
+    return totais, nil
+}
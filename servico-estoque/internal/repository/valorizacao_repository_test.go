@@ -0,0 +1,54 @@
+// internal/repository/valorizacao_repository_test.go
+package repository
+
+import (
+    "testing"
+    "time"
+
+    "servico-estoque/internal/domain"
+)
+
+func TestPosicoesEstoqueEmDataIncluiSaldoForaDoKardex(t *testing.T) {
+    db, ctx := abrirBanco(t)
+    repo := novoRepositorio(t, db)
+    p := criarProduto(t, ctx, repo, "P1", 10)
+
+    // Saldo anterior ao kardex: o produto tem estoque mas nenhum movimento
+    if err := db.WithContext(ctx).Where("produto_id = ?", p.ID).Delete(&domain.MovimentoEstoque{}).Error; err != nil {
+        t.Fatal(err)
+    }
+
+    antes := time.Now()
+    time.Sleep(10 * time.Millisecond)
+    lido, err := repo.FindByID(ctx, p.ID)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := repo.Update(ctx, lido, &AjusteSaldo{Saldo: dec(t, "4")}, nil); err != nil {
+        t.Fatal(err)
+    }
+
+    atual, err := repo.PosicoesEstoque(ctx, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    agora := time.Now()
+    hoje, err := repo.PosicoesEstoque(ctx, &agora)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(atual) != 1 || len(hoje) != 1 || !atual[0].Saldo.Equal(hoje[0].Saldo) {
+        t.Fatalf("posição de agora %v difere da atual %v", hoje, atual)
+    }
+    if !hoje[0].Saldo.Equal(dec(t, "4")) {
+        t.Errorf("saldo agora = %s, esperado 4", hoje[0].Saldo)
+    }
+
+    passado, err := repo.PosicoesEstoque(ctx, &antes)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(passado) != 1 || !passado[0].Saldo.Equal(dec(t, "10")) {
+        t.Errorf("posição antes do ajuste = %v, esperado saldo 10", passado)
+    }
+}
@@ -0,0 +1,53 @@
+// internal/service/categoria_service.go
+package service
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"servico-estoque/internal/domain"
+)
+
+// ListarCategorias retorna a árvore de categorias com os totais de produtos
+// e estoque de cada nó, incluindo as descendentes
+func (s *EstoqueService) ListarCategorias(ctx context.Context) ([]*domain.NoCategoria, error) {
+	categorias, err := s.categorias.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	totais, err := s.categorias.Totais(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return domain.MontarArvore(categorias, totais), nil
+}
+
+// CriarCategoria cadastra uma categoria, como raiz ou filha de outra
+func (s *EstoqueService) CriarCategoria(ctx context.Context, req domain.CriarCategoriaRequest) (*domain.Categoria, error) {
+	existente, err := s.categorias.FindByCodigo(ctx, req.Codigo)
+	if err != nil {
+		return nil, err
+	}
+	if existente != nil {
+		return nil, domain.ErrCodigoDuplicado
+	}
+	if req.PaiID != nil {
+		if _, err := s.categorias.FindByID(ctx, *req.PaiID); err != nil {
+			return nil, err
+		}
+	}
+
+	categoria := &domain.Categoria{
+		Codigo: req.Codigo,
+		Nome:   req.Nome,
+		PaiID:  req.PaiID,
+	}
+	if err := s.categorias.Create(ctx, categoria); err != nil {
+		s.logger.Error("Erro ao criar categoria", zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("Categoria criada", zap.String("id", categoria.ID.String()))
+	return categoria, nil
+}
@@ -0,0 +1,170 @@
+// internal/service/chave_api_service.go
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"servico-estoque/internal/domain"
+	"servico-estoque/internal/repository"
+)
+
+// intervaloUsoChave limita a gravação do último uso a uma por chave nesse
+// intervalo, para que cada requisição não gere uma escrita no banco
+const intervaloUsoChave = time.Minute
+
+// chaveInexistente marca no cache um hash que não corresponde a nenhuma
+// chave, evitando que tentativas com chaves inválidas cheguem ao banco
+const chaveInexistente = "-"
+
+// ChaveAPIService administra as chaves de API e as valida nas requisições
+type ChaveAPIService struct {
+	repo     repository.ChaveAPIRepository
+	cache    *redis.Client
+	cacheTTL time.Duration
+	logger   *zap.Logger
+}
+
+func NewChaveAPIService(repo repository.ChaveAPIRepository, cache *redis.Client, cacheTTL time.Duration, logger *zap.Logger) *ChaveAPIService {
+	return &ChaveAPIService{
+		repo:     repo,
+		cache:    cache,
+		cacheTTL: cacheTTL,
+		logger:   logger,
+	}
+}
+
+// CriarChave gera uma nova chave com os escopos informados. A chave completa
+// só é retornada aqui; depois disso apenas o hash fica guardado.
+func (s *ChaveAPIService) CriarChave(ctx context.Context, req domain.CriarChaveAPIRequest) (*domain.ChaveAPICriada, error) {
+	escopos, err := domain.ValidarEscopos(req.Escopos)
+	if err != nil {
+		return nil, err
+	}
+	if req.ExpiraEm != nil && !req.ExpiraEm.After(time.Now()) {
+		return nil, domain.ErrDadosInvalidos
+	}
+
+	texto, prefixo, hash, err := domain.NovaChaveAPI()
+	if err != nil {
+		return nil, err
+	}
+	chave := &domain.ChaveAPI{
+		ID:       uuid.New(),
+		Nome:     req.Nome,
+		Prefixo:  prefixo,
+		Hash:     hash,
+		Escopos:  escopos,
+		ExpiraEm: req.ExpiraEm,
+	}
+	if err := s.repo.Create(ctx, chave); err != nil {
+		s.logger.Error("Erro ao criar chave de API", zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("Chave de API criada", zap.String("id", chave.ID.String()), zap.String("prefixo", prefixo), zap.Strings("escopos", escopos))
+	return &domain.ChaveAPICriada{ChaveAPI: chave, Chave: texto}, nil
+}
+
+// ListarChaves retorna todas as chaves, inclusive revogadas e expiradas
+func (s *ChaveAPIService) ListarChaves(ctx context.Context) ([]domain.ChaveAPI, error) {
+	return s.repo.FindAll(ctx)
+}
+
+// RotacionarChave gera um novo segredo para a chave, mantendo ID, nome,
+// escopos e validade. O segredo anterior deixa de valer imediatamente.
+func (s *ChaveAPIService) RotacionarChave(ctx context.Context, id uuid.UUID) (*domain.ChaveAPICriada, error) {
+	texto, prefixo, hash, err := domain.NovaChaveAPI()
+	if err != nil {
+		return nil, err
+	}
+	antigo, chave, err := s.repo.Rotacionar(ctx, id, prefixo, hash)
+	if err != nil {
+		return nil, err
+	}
+	s.esquecer(ctx, antigo)
+
+	s.logger.Info("Chave de API rotacionada", zap.String("id", id.String()), zap.String("prefixo", prefixo))
+	return &domain.ChaveAPICriada{ChaveAPI: chave, Chave: texto}, nil
+}
+
+// RevogarChave revoga a chave de forma definitiva
+func (s *ChaveAPIService) RevogarChave(ctx context.Context, id uuid.UUID) (*domain.ChaveAPI, error) {
+	chave, err := s.repo.Revogar(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.esquecer(ctx, chave.Hash)
+
+	s.logger.Info("Chave de API revogada", zap.String("id", id.String()), zap.String("prefixo", chave.Prefixo))
+	return chave, nil
+}
+
+// ValidarChave retorna a chave ativa correspondente ao texto recebido, ou
+// ErrNaoAutenticado. A consulta passa pelo cache do Redis, inclusive para
+// chaves inexistentes; revogação e rotação removem a entrada do cache.
+func (s *ChaveAPIService) ValidarChave(ctx context.Context, texto string) (*domain.ChaveAPI, error) {
+	if !domain.FormatoChaveAPIValido(texto) {
+		return nil, domain.ErrNaoAutenticado
+	}
+	hash := domain.HashChaveAPI(texto)
+	cacheKey := chaveCacheChaveAPI(hash)
+
+	var chave *domain.ChaveAPI
+	cached, err := s.cache.Get(ctx, cacheKey).Result()
+	if err == nil {
+		if cached == chaveInexistente {
+			return nil, domain.ErrNaoAutenticado
+		}
+		var c domain.ChaveAPI
+		if err := json.Unmarshal([]byte(cached), &c); err == nil {
+			chave = &c
+		}
+	}
+
+	if chave == nil {
+		chave, err = s.repo.FindByHash(ctx, hash)
+		if err == domain.ErrChaveAPINaoEncontrada {
+			s.cache.Set(ctx, cacheKey, chaveInexistente, s.cacheTTL)
+			return nil, domain.ErrNaoAutenticado
+		}
+		if err != nil {
+			return nil, err
+		}
+		chaveJSON, _ := json.Marshal(chave)
+		s.cache.Set(ctx, cacheKey, chaveJSON, s.cacheTTL)
+	}
+
+	agora := time.Now()
+	if !chave.Ativa(agora) {
+		return nil, domain.ErrNaoAutenticado
+	}
+	s.registrarUso(ctx, chave.ID, agora)
+	return chave, nil
+}
+
+// registrarUso grava o último uso, no máximo uma vez por intervaloUsoChave
+func (s *ChaveAPIService) registrarUso(ctx context.Context, id uuid.UUID, agora time.Time) {
+	primeiro, err := s.cache.SetNX(ctx, "chaves-api:uso:"+id.String(), 1, intervaloUsoChave).Result()
+	if err != nil || !primeiro {
+		return
+	}
+	if err := s.repo.RegistrarUso(context.WithoutCancel(ctx), id, agora); err != nil {
+		s.logger.Warn("Erro ao registrar uso de chave de API", zap.String("id", id.String()), zap.Error(err))
+	}
+}
+
+func (s *ChaveAPIService) esquecer(ctx context.Context, hash string) {
+	if err := s.cache.Del(ctx, chaveCacheChaveAPI(hash)).Err(); err != nil {
+		s.logger.Warn("Erro ao invalidar cache de chave de API", zap.Error(err))
+	}
+}
+
+func chaveCacheChaveAPI(hash string) string {
+	return "chaves-api:" + hash
+}
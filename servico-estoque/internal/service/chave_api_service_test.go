@@ -0,0 +1,189 @@
+// internal/service/chave_api_service_test.go
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"servico-estoque/internal/domain"
+	"servico-estoque/internal/repository"
+)
+
+// chavesFalso guarda as chaves de API em memória e conta as consultas por
+// hash, para distinguir o que veio do cache
+type chavesFalso struct {
+	repository.ChaveAPIRepository
+
+	chaves    map[uuid.UUID]*domain.ChaveAPI
+	consultas int
+}
+
+func (r *chavesFalso) FindByHash(ctx context.Context, hash string) (*domain.ChaveAPI, error) {
+	r.consultas++
+	for _, c := range r.chaves {
+		if c.Hash == hash {
+			copia := *c
+			return &copia, nil
+		}
+	}
+	return nil, domain.ErrChaveAPINaoEncontrada
+}
+
+func (r *chavesFalso) Create(ctx context.Context, c *domain.ChaveAPI) error {
+	copia := *c
+	r.chaves[c.ID] = &copia
+	return nil
+}
+
+func (r *chavesFalso) Rotacionar(ctx context.Context, id uuid.UUID, prefixo, hash string) (string, *domain.ChaveAPI, error) {
+	c, ok := r.chaves[id]
+	if !ok {
+		return "", nil, domain.ErrChaveAPINaoEncontrada
+	}
+	antigo := c.Hash
+	c.Prefixo, c.Hash = prefixo, hash
+	copia := *c
+	return antigo, &copia, nil
+}
+
+func (r *chavesFalso) Revogar(ctx context.Context, id uuid.UUID) (*domain.ChaveAPI, error) {
+	c, ok := r.chaves[id]
+	if !ok {
+		return nil, domain.ErrChaveAPINaoEncontrada
+	}
+	agora := time.Now()
+	c.RevogadaEm = &agora
+	copia := *c
+	return &copia, nil
+}
+
+func (r *chavesFalso) RegistrarUso(ctx context.Context, id uuid.UUID, em time.Time) error {
+	return nil
+}
+
+func novoServicoChaves(t *testing.T) (*ChaveAPIService, *chavesFalso, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	repo := &chavesFalso{chaves: map[uuid.UUID]*domain.ChaveAPI{}}
+	return NewChaveAPIService(repo, rdb, time.Hour, zap.NewNop()), repo, mr
+}
+
+func criarChaveTeste(t *testing.T, s *ChaveAPIService) *domain.ChaveAPICriada {
+	t.Helper()
+	criada, err := s.CriarChave(context.Background(), domain.CriarChaveAPIRequest{
+		Nome:    "faturamento",
+		Escopos: []string{domain.EscopoReservasEscrita},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return criada
+}
+
+func TestValidarChaveUsaOCache(t *testing.T) {
+	s, repo, _ := novoServicoChaves(t)
+	ctx := context.Background()
+	criada := criarChaveTeste(t, s)
+
+	for i := 0; i < 3; i++ {
+		chave, err := s.ValidarChave(ctx, criada.Chave)
+		if err != nil {
+			t.Fatalf("validação %d: %v", i+1, err)
+		}
+		if chave.ID != criada.ID || len(chave.Escopos) != 1 || chave.Escopos[0] != domain.EscopoReservasEscrita {
+			t.Errorf("validação %d: chave = %+v", i+1, chave)
+		}
+	}
+	if repo.consultas != 1 {
+		t.Errorf("consultas ao banco = %d, esperada 1", repo.consultas)
+	}
+}
+
+func TestValidarChaveRecusaChaveRevogadaMesmoEmCache(t *testing.T) {
+	s, _, _ := novoServicoChaves(t)
+	ctx := context.Background()
+	criada := criarChaveTeste(t, s)
+
+	if _, err := s.ValidarChave(ctx, criada.Chave); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RevogarChave(ctx, criada.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ValidarChave(ctx, criada.Chave); !errors.Is(err, domain.ErrNaoAutenticado) {
+		t.Errorf("chave revogada: err = %v, esperado ErrNaoAutenticado", err)
+	}
+}
+
+func TestValidarChaveRecusaSegredoRotacionadoMesmoEmCache(t *testing.T) {
+	s, _, _ := novoServicoChaves(t)
+	ctx := context.Background()
+	criada := criarChaveTeste(t, s)
+
+	if _, err := s.ValidarChave(ctx, criada.Chave); err != nil {
+		t.Fatal(err)
+	}
+	nova, err := s.RotacionarChave(ctx, criada.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nova.Chave == criada.Chave {
+		t.Fatal("rotação manteve o mesmo segredo")
+	}
+	if _, err := s.ValidarChave(ctx, criada.Chave); !errors.Is(err, domain.ErrNaoAutenticado) {
+		t.Errorf("segredo antigo: err = %v, esperado ErrNaoAutenticado", err)
+	}
+	chave, err := s.ValidarChave(ctx, nova.Chave)
+	if err != nil {
+		t.Fatalf("segredo novo: %v", err)
+	}
+	if chave.ID != criada.ID {
+		t.Errorf("segredo novo validou a chave %s, esperada %s", chave.ID, criada.ID)
+	}
+}
+
+func TestValidarChaveGuardaChaveInexistenteNoCache(t *testing.T) {
+	s, repo, _ := novoServicoChaves(t)
+	ctx := context.Background()
+	criada := criarChaveTeste(t, s)
+	if _, err := s.RevogarChave(ctx, criada.ID); err != nil {
+		t.Fatal(err)
+	}
+	delete(repo.chaves, criada.ID)
+
+	for i := 0; i < 3; i++ {
+		if _, err := s.ValidarChave(ctx, criada.Chave); !errors.Is(err, domain.ErrNaoAutenticado) {
+			t.Fatalf("validação %d: err = %v, esperado ErrNaoAutenticado", i+1, err)
+		}
+	}
+	if repo.consultas != 1 {
+		t.Errorf("consultas ao banco = %d, esperada 1", repo.consultas)
+	}
+	if _, err := s.ValidarChave(ctx, "nao-e-uma-chave"); !errors.Is(err, domain.ErrNaoAutenticado) {
+		t.Errorf("formato inválido: err = %v, esperado ErrNaoAutenticado", err)
+	}
+	if repo.consultas != 1 {
+		t.Errorf("formato inválido consultou o banco")
+	}
+}
+
+func TestValidarChaveRecusaChaveExpirada(t *testing.T) {
+	s, repo, _ := novoServicoChaves(t)
+	ctx := context.Background()
+	criada := criarChaveTeste(t, s)
+	passado := time.Now().Add(-time.Minute)
+	repo.chaves[criada.ID].ExpiraEm = &passado
+
+	if _, err := s.ValidarChave(ctx, criada.Chave); !errors.Is(err, domain.ErrNaoAutenticado) {
+		t.Errorf("chave expirada: err = %v, esperado ErrNaoAutenticado", err)
+	}
+}
@@ -0,0 +1,39 @@
+// internal/service/deposito_service.go
+package service
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"servico-estoque/internal/domain"
+)
+
+// ListarDepositos lista os depósitos cadastrados
+func (s *EstoqueService) ListarDepositos(ctx context.Context) ([]domain.Deposito, error) {
+	return s.depositos.FindAll(ctx)
+}
+
+// CriarDeposito cadastra um novo depósito
+func (s *EstoqueService) CriarDeposito(ctx context.Context, req domain.CriarDepositoRequest) (*domain.Deposito, error) {
+	existente, err := s.depositos.FindByCodigo(ctx, req.Codigo)
+	if err != nil {
+		return nil, err
+	}
+	if existente != nil {
+		return nil, domain.ErrCodigoDuplicado
+	}
+
+	deposito := &domain.Deposito{
+		Codigo: req.Codigo,
+		Nome:   req.Nome,
+		Padrao: req.Padrao,
+	}
+	if err := s.depositos.Create(ctx, deposito); err != nil {
+		s.logger.Error("Erro ao criar depósito", zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("Depósito criado", zap.String("id", deposito.ID.String()))
+	return deposito, nil
+}
@@ -3,42 +3,134 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 
 	"servico-estoque/internal/domain"
+	"servico-estoque/internal/metricas"
 	"servico-estoque/internal/repository"
+	"servico-estoque/internal/tenant"
 	"servico-estoque/pkg/lock"
 )
 
+// formatoData é o formato das datas de fabricação e validade de lotes
+const formatoData = "2006-01-02"
+
+// Options reúne os tempos de expiração usados pelo serviço
+type Options struct {
+	ReservaTTL         time.Duration
+	IdempotenciaTTL    time.Duration
+	CacheProdutoTTL    time.Duration
+	CacheListaTTL      time.Duration
+	LockReservaTTL     time.Duration
+	LockBaixaTTL       time.Duration
+	ExpiracaoIntervalo time.Duration
+	ExpiracaoLote      int
+	LockExpiracaoTTL   time.Duration
+}
+
 type EstoqueService struct {
-	repo   repository.ProdutoRepository
-	cache  *redis.Client
-	lock   *lock.DistributedLock
-	logger *zap.Logger
+	repo       repository.ProdutoRepository
+	depositos  repository.DepositoRepository
+	categorias repository.CategoriaRepository
+	cache      *redis.Client
+	lock       *lock.DistributedLock
+	opts       Options
+	logger     *zap.Logger
+
+	// preparados guarda as empresas já preparadas por PrepararTenant
+	preparados sync.Map
 }
 
 func NewEstoqueService(
 	repo repository.ProdutoRepository,
+	depositos repository.DepositoRepository,
+	categorias repository.CategoriaRepository,
 	cache *redis.Client,
 	lock *lock.DistributedLock,
+	opts Options,
 	logger *zap.Logger,
 ) *EstoqueService {
 	return &EstoqueService{
-		repo:   repo,
-		cache:  cache,
-		lock:   lock,
-		logger: logger,
+		repo:       repo,
+		depositos:  depositos,
+		categorias: categorias,
+		cache:      cache,
+		lock:       lock,
+		opts:       opts,
+		logger:     logger,
+	}
+}
+
+// PrepararTenant prepara a empresa do contexto na primeira vez em que ela é
+// usada pelo processo: cria o depósito padrão, migra saldos antigos e monta
+// o índice de sugestões. As chamadas seguintes não fazem nada.
+func (s *EstoqueService) PrepararTenant(ctx context.Context) error {
+	id, ok := tenant.De(ctx)
+	if !ok {
+		return domain.ErrTenantAusente
+	}
+	if _, pronto := s.preparados.Load(id); pronto {
+		return nil
+	}
+
+	if err := s.depositos.Inicializar(ctx); err != nil {
+		return err
+	}
+	if err := s.ReconstruirSugestoes(ctx); err != nil {
+		s.logger.Warn("Erro ao reconstruir índice de sugestões", zap.String("tenant", id), zap.Error(err))
+	}
+	s.preparados.Store(id, true)
+	return nil
+}
+
+// PrepararTenants prepara todas as empresas já conhecidas, na inicialização
+func (s *EstoqueService) PrepararTenants(ctx context.Context) error {
+	tenants, err := s.depositos.Tenants(ctx)
+	if err != nil {
+		return err
+	}
+	for _, id := range tenants {
+		if err := s.PrepararTenant(tenant.Com(ctx, id)); err != nil {
+			return fmt.Errorf("empresa %s: %w", id, err)
+		}
 	}
+	return nil
 }
 
 // CriarProduto cria um novo produto
 func (s *EstoqueService) CriarProduto(ctx context.Context, req domain.CriarProdutoRequest) (*domain.Produto, error) {
+	produto, err := s.novoProduto(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Create(ctx, produto, req.DepositoID); err != nil {
+		s.logger.Error("Erro ao criar produto", zap.Error(err))
+		return nil, err
+	}
+
+	// Invalidar cache
+	s.invalidateCache(ctx, "produtos:*")
+	s.indexarSugestao(ctx, produto)
+
+	s.logger.Info("Produto criado", zap.String("id", produto.ID.String()))
+	return produto, nil
+}
+
+// novoProduto aplica as regras de cadastro, inclusive código duplicado, e
+// monta o produto sem gravá-lo
+func (s *EstoqueService) novoProduto(ctx context.Context, req domain.CriarProdutoRequest) (*domain.Produto, error) {
 	// Validar se código já existe
 	existente, err := s.repo.FindByCodigo(ctx, req.Codigo)
 	if err != nil && err != domain.ErrProdutoNaoEncontrado {
@@ -48,36 +140,70 @@ func (s *EstoqueService) CriarProduto(ctx context.Context, req domain.CriarProdu
 		return nil, domain.ErrCodigoDuplicado
 	}
 
-	produto := &domain.Produto{
-		Codigo:    req.Codigo,
-		Descricao: req.Descricao,
-		Saldo:     req.Saldo,
-		Reservado: 0,
+	if err := domain.ValidarSaldo(req.Saldo); err != nil {
+		return nil, err
 	}
-
-	if err := s.repo.Create(ctx, produto); err != nil {
-		s.logger.Error("Erro ao criar produto", zap.Error(err))
+	if err := domain.ValidarCusto(req.CustoUnitario); err != nil {
 		return nil, err
 	}
+	unidade := req.Unidade
+	if unidade == "" {
+		unidade = domain.UnidadePadrao
+	}
+	if err := domain.ValidarConversoes(unidade, req.Conversoes); err != nil {
+		return nil, err
+	}
+	fiscal := req.Fiscal
+	fiscal.Normalizar(unidade)
+	if err := fiscal.Validar(); err != nil {
+		return nil, err
+	}
+	if req.CategoriaID != nil {
+		if _, err := s.categorias.FindByID(ctx, *req.CategoriaID); err != nil {
+			return nil, err
+		}
+	}
 
-	// Invalidar cache
-	s.invalidateCache(ctx, "produtos:*")
+	// Produto com lote ou série recebe saldo apenas pelas respectivas entradas
+	if req.ControlaLote && req.ControlaSerie {
+		return nil, domain.ErrDadosInvalidos
+	}
+	if req.ControlaLote && req.Saldo.IsPositive() {
+		return nil, domain.ErrLoteObrigatorio
+	}
+	if req.ControlaSerie && req.Saldo.IsPositive() {
+		return nil, domain.ErrSerieObrigatoria
+	}
 
-	s.logger.Info("Produto criado", zap.String("id", produto.ID.String()))
+	produto := &domain.Produto{
+		Codigo:        req.Codigo,
+		Descricao:     req.Descricao,
+		CategoriaID:   req.CategoriaID,
+		Saldo:         req.Saldo,
+		Reservado:     decimal.Zero,
+		CustoMedio:    req.CustoUnitario,
+		Unidade:       unidade,
+		Conversoes:    req.Conversoes,
+		ControlaLote:  req.ControlaLote,
+		ControlaSerie: req.ControlaSerie,
+		Fiscal:        fiscal,
+	}
 	return produto, nil
 }
 
 // ObterProduto busca produto por ID com cache
 func (s *EstoqueService) ObterProduto(ctx context.Context, id uuid.UUID) (*domain.Produto, error) {
 	// Tentar buscar no cache
-	cacheKey := fmt.Sprintf("produtos:%s", id.String())
+	cacheKey := tenant.Chave(ctx, fmt.Sprintf("produtos:%s", id.String()))
 	cached, err := s.cache.Get(ctx, cacheKey).Result()
 	if err == nil {
 		var produto domain.Produto
 		if err := json.Unmarshal([]byte(cached), &produto); err == nil {
+			metricas.ConsultaCache(metricas.CacheObterProduto, true)
 			return &produto, nil
 		}
 	}
+	metricas.ConsultaCache(metricas.CacheObterProduto, false)
 
 	// Buscar no banco
 	produto, err := s.repo.FindByID(ctx, id)
@@ -85,88 +211,336 @@ func (s *EstoqueService) ObterProduto(ctx context.Context, id uuid.UUID) (*domai
 		return nil, err
 	}
 
-	// Salvar no cache (TTL 5 minutos)
+	// Salvar no cache
 	produtoJSON, _ := json.Marshal(produto)
-	s.cache.Set(ctx, cacheKey, produtoJSON, 5*time.Minute)
+	s.cache.Set(ctx, cacheKey, produtoJSON, s.opts.CacheProdutoTTL)
 
 	return produto, nil
 }
 
-// ListarProdutos lista todos os produtos
-func (s *EstoqueService) ListarProdutos(ctx context.Context) ([]domain.Produto, error) {
+// ListarProdutos lista uma página de produtos conforme o filtro. Cada
+// combinação de filtro, ordenação e página tem sua própria entrada de cache.
+func (s *EstoqueService) ListarProdutos(ctx context.Context, filtro domain.FiltroProdutos) (*domain.Pagina[domain.Produto], error) {
+	if filtro.Ordem == "" {
+		filtro.Ordem = domain.OrdemCodigo
+	}
+	if filtro.CategoriaID != nil {
+		if _, err := s.categorias.FindByID(ctx, *filtro.CategoriaID); err != nil {
+			return nil, err
+		}
+	}
+
 	// Tentar cache
-	cacheKey := "produtos:list"
+	cacheKey := tenant.Chave(ctx, chaveListagem(filtro))
 	cached, err := s.cache.Get(ctx, cacheKey).Result()
 	if err == nil {
-		var produtos []domain.Produto
-		if err := json.Unmarshal([]byte(cached), &produtos); err == nil {
-			return produtos, nil
+		var pagina domain.Pagina[domain.Produto]
+		if err := json.Unmarshal([]byte(cached), &pagina); err == nil {
+			metricas.ConsultaCache(metricas.CacheListarProdutos, true)
+			return &pagina, nil
 		}
 	}
+	metricas.ConsultaCache(metricas.CacheListarProdutos, false)
 
 	// Buscar no banco
-	produtos, err := s.repo.FindAll(ctx)
+	produtos, total, err := s.repo.FindAll(ctx, filtro)
 	if err != nil {
 		return nil, err
 	}
+	if produtos == nil {
+		produtos = []domain.Produto{}
+	}
+
+	pagina := &domain.Pagina[domain.Produto]{
+		Itens: produtos,
+		Total: total,
+		Size:  filtro.Size,
+	}
+	if filtro.Cursor == nil {
+		pagina.Page = filtro.Page
+	}
+	if len(produtos) == filtro.Size {
+		pagina.NextCursor = domain.NovoCursor(produtos[len(produtos)-1], filtro)
+	}
 
 	// Cachear resultado
-	produtosJSON, _ := json.Marshal(produtos)
-	s.cache.Set(ctx, cacheKey, produtosJSON, 2*time.Minute)
+	paginaJSON, _ := json.Marshal(pagina)
+	s.cache.Set(ctx, cacheKey, paginaJSON, s.opts.CacheListaTTL)
 
-	return produtos, nil
+	return pagina, nil
 }
 
-// BuscarProdutos busca produtos por termo
-func (s *EstoqueService) BuscarProdutos(ctx context.Context, query string) ([]domain.Produto, error) {
-	return s.repo.Search(ctx, query)
+// chaveListagem deriva a chave de cache de uma consulta da listagem a partir
+// de todos os campos do filtro
+func chaveListagem(filtro domain.FiltroProdutos) string {
+	b, _ := json.Marshal(filtro)
+	soma := sha256.Sum256(b)
+	return "produtos:list:" + hex.EncodeToString(soma[:16])
 }
 
-// AtualizarProduto atualiza produto
-func (s *EstoqueService) AtualizarProduto(ctx context.Context, id uuid.UUID, req domain.AtualizarProdutoRequest) (*domain.Produto, error) {
+// impressaoItens identifica os itens de uma reserva, para distinguir uma
+// repetição de uma nova reserva com a mesma nota
+func impressaoItens(itens []domain.ItemReserva) string {
+	b, _ := json.Marshal(itens)
+	soma := sha256.Sum256(b)
+	return hex.EncodeToString(soma[:])
+}
+
+// BuscarProdutos busca produtos por código, GTIN ou descrição, ignorando
+// acentos e tolerando erros de digitação, em ordem de relevância
+func (s *EstoqueService) BuscarProdutos(ctx context.Context, filtro domain.FiltroBusca) (*domain.Pagina[domain.ResultadoBusca], error) {
+	resultados, total, err := s.repo.Search(ctx, filtro)
+	if err != nil {
+		return nil, err
+	}
+	if resultados == nil {
+		resultados = []domain.ResultadoBusca{}
+	}
+
+	return &domain.Pagina[domain.ResultadoBusca]{
+		Itens: resultados,
+		Total: total,
+		Page:  filtro.Page,
+		Size:  filtro.Size,
+	}, nil
+}
+
+// AtualizarProduto atualiza produto. versao é a versão que o cliente leu; se
+// o produto foi alterado desde então, retorna ErrVersaoDivergente.
+func (s *EstoqueService) AtualizarProduto(ctx context.Context, id uuid.UUID, versao int64, req domain.AtualizarProdutoRequest) (*domain.Produto, error) {
 	produto, err := s.repo.FindByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
+	if produto.Versao != versao {
+		return nil, domain.ErrVersaoDivergente
+	}
+
+	ajuste, err := s.aplicarAtualizacao(ctx, produto, req)
+	if err != nil {
+		return nil, err
+	}
 
-	if req.Descricao != "" {
-		produto.Descricao = req.Descricao
+	if err := s.repo.Update(ctx, produto, ajuste, req.Conversoes); err != nil {
+		return nil, err
 	}
+
+	// Invalidar cache
+	s.invalidateCache(ctx, "produtos:*")
+	s.indexarSugestao(ctx, produto)
+
+	// Recarregar para refletir o detalhamento por depósito
+	return s.repo.FindByID(ctx, id)
+}
+
+// aplicarAtualizacao valida a requisição, aplica os dados cadastrais ao
+// produto em memória e retorna o ajuste de saldo pedido, se houver
+func (s *EstoqueService) aplicarAtualizacao(ctx context.Context, produto *domain.Produto, req domain.AtualizarProdutoRequest) (*repository.AjusteSaldo, error) {
+	if req.Descricao != nil {
+		produto.Descricao = *req.Descricao
+	}
+	if req.CategoriaID != nil {
+		produto.CategoriaID = nil
+		if *req.CategoriaID != uuid.Nil {
+			if _, err := s.categorias.FindByID(ctx, *req.CategoriaID); err != nil {
+				return nil, err
+			}
+			produto.CategoriaID = req.CategoriaID
+		}
+	}
+
+	var ajuste *repository.AjusteSaldo
 	if req.Saldo != nil {
-		produto.Saldo = *req.Saldo
+		if err := domain.ValidarSaldo(*req.Saldo); err != nil {
+			return nil, err
+		}
+		if produto.ControlaLote {
+			return nil, domain.ErrLoteObrigatorio
+		}
+		if produto.ControlaSerie {
+			return nil, domain.ErrSerieObrigatoria
+		}
+		if req.CustoUnitario != nil {
+			if err := domain.ValidarCusto(*req.CustoUnitario); err != nil {
+				return nil, err
+			}
+		}
+		ajuste = &repository.AjusteSaldo{
+			DepositoID:    req.DepositoID,
+			Saldo:         *req.Saldo,
+			CustoUnitario: req.CustoUnitario,
+			Motivo:        req.Motivo,
+		}
+	}
+
+	if req.Conversoes != nil {
+		if err := domain.ValidarConversoes(produto.Unidade, *req.Conversoes); err != nil {
+			return nil, err
+		}
+	}
+	if req.Fiscal != nil {
+		fiscal := *req.Fiscal
+		fiscal.Normalizar(produto.Unidade)
+		if err := fiscal.Validar(); err != nil {
+			return nil, err
+		}
+		produto.Fiscal = fiscal
+	}
+	return ajuste, nil
+}
+
+// ListarMovimentos retorna o kardex paginado de um produto
+func (s *EstoqueService) ListarMovimentos(ctx context.Context, filtro domain.FiltroMovimentos) (*domain.Pagina[domain.MovimentoEstoque], error) {
+	if _, err := s.repo.FindByID(ctx, filtro.ProdutoID); err != nil {
+		return nil, err
 	}
 
-	if err := s.repo.Update(ctx, produto); err != nil {
+	movimentos, total, err := s.repo.ListarMovimentos(ctx, filtro)
+	if err != nil {
+		return nil, err
+	}
+	if movimentos == nil {
+		movimentos = []domain.MovimentoEstoque{}
+	}
+
+	return &domain.Pagina[domain.MovimentoEstoque]{
+		Itens: movimentos,
+		Total: total,
+		Page:  filtro.Page,
+		Size:  filtro.Size,
+	}, nil
+}
+
+// EntradaLote dá entrada de estoque em um lote do produto
+func (s *EstoqueService) EntradaLote(ctx context.Context, produtoID uuid.UUID, req domain.EntradaLoteRequest) (*domain.Lote, error) {
+	if err := domain.ValidarQuantidade(req.Quantidade); err != nil {
+		return nil, err
+	}
+	if req.CustoUnitario != nil {
+		if err := domain.ValidarCusto(*req.CustoUnitario); err != nil {
+			return nil, err
+		}
+	}
+	validade, err := time.Parse(formatoData, req.Validade)
+	if err != nil {
+		return nil, domain.ErrDadosInvalidos
+	}
+
+	lote := &domain.Lote{
+		ProdutoID: produtoID,
+		Numero:    req.Numero,
+		Validade:  validade,
+	}
+	if req.Fabricacao != "" {
+		fabricacao, err := time.Parse(formatoData, req.Fabricacao)
+		if err != nil || fabricacao.After(validade) {
+			return nil, domain.ErrDadosInvalidos
+		}
+		lote.Fabricacao = &fabricacao
+	}
+	if req.DepositoID != nil {
+		lote.DepositoID = *req.DepositoID
+	}
+
+	if err := s.repo.EntradaLote(ctx, lote, req.Quantidade, req.CustoUnitario, req.Motivo); err != nil {
+		s.logger.Error("Erro ao dar entrada em lote", zap.String("produto_id", produtoID.String()), zap.Error(err))
 		return nil, err
 	}
 
 	// Invalidar cache
 	s.invalidateCache(ctx, "produtos:*")
 
-	return produto, nil
+	s.logger.Info("Entrada de lote registrada",
+		zap.String("produto_id", produtoID.String()),
+		zap.String("lote", lote.Numero),
+		zap.String("quantidade", req.Quantidade.String()),
+	)
+	return lote, nil
+}
+
+// ListarLotes retorna os lotes de um produto, dos que vencem primeiro
+func (s *EstoqueService) ListarLotes(ctx context.Context, produtoID uuid.UUID) ([]domain.Lote, error) {
+	if _, err := s.repo.FindByID(ctx, produtoID); err != nil {
+		return nil, err
+	}
+
+	lotes, err := s.repo.ListarLotes(ctx, produtoID)
+	if err != nil {
+		return nil, err
+	}
+	if lotes == nil {
+		lotes = []domain.Lote{}
+	}
+	return lotes, nil
+}
+
+// EntradaSeries dá entrada de unidades de um produto controlado por série
+func (s *EstoqueService) EntradaSeries(ctx context.Context, produtoID uuid.UUID, req domain.EntradaSeriesRequest) ([]domain.NumeroSerie, error) {
+	if req.CustoUnitario != nil {
+		if err := domain.ValidarCusto(*req.CustoUnitario); err != nil {
+			return nil, err
+		}
+	}
+
+	series, err := s.repo.EntradaSeries(ctx, produtoID, req.DepositoID, req.Numeros, req.CustoUnitario, req.Motivo)
+	if err != nil {
+		s.logger.Error("Erro ao dar entrada em números de série", zap.String("produto_id", produtoID.String()), zap.Error(err))
+		return nil, err
+	}
+
+	// Invalidar cache
+	s.invalidateCache(ctx, "produtos:*")
+
+	s.logger.Info("Entrada de números de série registrada",
+		zap.String("produto_id", produtoID.String()),
+		zap.Int("quantidade", len(series)),
+	)
+	return series, nil
+}
+
+// ObterSerie retorna um número de série com todo o seu histórico
+func (s *EstoqueService) ObterSerie(ctx context.Context, produtoID uuid.UUID, numero string) (*domain.NumeroSerie, error) {
+	return s.repo.FindSerie(ctx, produtoID, numero)
 }
 
-// DeletarProduto deleta produto
-func (s *EstoqueService) DeletarProduto(ctx context.Context, id uuid.UUID) error {
-	if err := s.repo.Delete(ctx, id); err != nil {
+// DeletarProduto deleta produto, se versao ainda for a versão atual
+func (s *EstoqueService) DeletarProduto(ctx context.Context, id uuid.UUID, versao int64) error {
+	if err := s.repo.Delete(ctx, id, versao); err != nil {
 		return err
 	}
 
 	// Invalidar cache
 	s.invalidateCache(ctx, "produtos:*")
+	s.removerSugestao(ctx, id)
 
 	return nil
 }
 
 // ReservarProdutos reserva múltiplos produtos (com idempotência)
 func (s *EstoqueService) ReservarProdutos(ctx context.Context, req domain.ReservarEstoqueRequest) (*domain.ReservaResult, error) {
-	// Implementar idempotência
-	idempotencyKey := fmt.Sprintf("reserva:%s", req.NotaFiscalID.String())
+	// Lock por nota para que requisições duplicadas simultâneas não reservem em dobro
+	lockKey := tenant.Chave(ctx, fmt.Sprintf("nota:%s", req.NotaFiscalID.String()))
+	lockValue, err := s.lock.AcquireLock(ctx, lockKey, s.opts.LockReservaTTL)
+	if err != nil {
+		s.logger.Warn("Falha ao adquirir lock", zap.String("nota_id", req.NotaFiscalID.String()), zap.Error(err))
+		return nil, domain.ErrOperacaoEmAndamento
+	}
+	defer s.lock.ReleaseLock(ctx, lockKey, lockValue)
+
+	// Implementar idempotência. A mesma nota com outros itens é outra
+	// operação e não recebe o resultado guardado.
+	idempotencyKey := tenant.Chave(ctx, fmt.Sprintf("reserva:%s", req.NotaFiscalID.String()))
+	impressao := impressaoItens(req.Itens)
 	exists, err := s.cache.Exists(ctx, idempotencyKey).Result()
 	if err != nil {
 		s.logger.Warn("Erro ao verificar idempotência", zap.Error(err))
 	}
 	if exists > 0 {
+		guardada, err := s.cache.Get(ctx, idempotencyKey+":itens").Result()
+		if err == nil && guardada != impressao {
+			return nil, domain.ErrIdempotenciaDivergente
+		}
+
 		// Retornar resultado cacheado
 		cached, _ := s.cache.Get(ctx, idempotencyKey).Result()
 		var result domain.ReservaResult
@@ -176,44 +550,22 @@ func (s *EstoqueService) ReservarProdutos(ctx context.Context, req domain.Reserv
 		}
 	}
 
-	var reservas []domain.ReservaEstoque
-
-	// Processar cada item
-	for _, item := range req.Itens {
-		// Adquirir lock distribuído para evitar race conditions
-		lockKey := fmt.Sprintf("produto:%s", item.ProdutoID.String())
-		lockValue, err := s.lock.AcquireLock(ctx, lockKey, 10*time.Second)
-		if err != nil {
-			s.logger.Error("Falha ao adquirir lock", zap.String("produto_id", item.ProdutoID.String()))
-			// Cancelar reservas anteriores
-			s.cancelarReservasAnteriores(ctx, reservas)
-			return nil, fmt.Errorf("produto está sendo processado simultaneamente")
-		}
-
-		// Criar reserva
-		reserva := &domain.ReservaEstoque{
-			ProdutoID:    item.ProdutoID,
-			NotaFiscalID: req.NotaFiscalID,
-			Quantidade:   item.Quantidade,
-			ExpiresAt:    time.Now().Add(10 * time.Minute),
-		}
+	itens, err := s.converterItens(ctx, req.Itens)
+	if err != nil {
+		return nil, err
+	}
 
-		if err := s.repo.ReservarEstoque(ctx, reserva); err != nil {
-			s.logger.Error("Falha ao reservar produto",
-				zap.String("produto_id", item.ProdutoID.String()),
-				zap.Error(err),
-			)
-			// Liberar lock
-			s.lock.ReleaseLock(ctx, lockKey, lockValue)
-			// Cancelar reservas anteriores
-			s.cancelarReservasAnteriores(ctx, reservas)
-			return nil, err
+	// Reservar todos os itens em uma única transação
+	reservas, err := s.repo.ReservarEstoque(ctx, req.NotaFiscalID, itens, time.Now().Add(s.opts.ReservaTTL))
+	if err != nil {
+		if errors.Is(err, domain.ErrEstoqueInsuficiente) {
+			metricas.EstoqueInsuficiente.WithLabelValues("reserva").Inc()
 		}
-
-		reservas = append(reservas, *reserva)
-
-		// Liberar lock
-		s.lock.ReleaseLock(ctx, lockKey, lockValue)
+		s.logger.Error("Falha ao reservar produtos",
+			zap.String("nota_id", req.NotaFiscalID.String()),
+			zap.Error(err),
+		)
+		return nil, err
 	}
 
 	result := &domain.ReservaResult{
@@ -222,20 +574,23 @@ func (s *EstoqueService) ReservarProdutos(ctx context.Context, req domain.Reserv
 		Mensagem:  fmt.Sprintf("%d produtos reservados com sucesso", len(reservas)),
 	}
 
-	// Salvar no cache para idempotência (TTL 1 hora)
+	// Salvar no cache para idempotência
 	resultJSON, _ := json.Marshal(result)
-	s.cache.Set(ctx, idempotencyKey, resultJSON, time.Hour)
+	s.cache.Set(ctx, idempotencyKey, resultJSON, s.opts.IdempotenciaTTL)
+	s.cache.Set(ctx, idempotencyKey+":itens", impressao, s.opts.IdempotenciaTTL)
 
 	// Invalidar cache de produtos
 	s.invalidateCache(ctx, "produtos:*")
 
+	metricas.Reservas.WithLabelValues(metricas.ReservaCriada).Add(float64(len(reservas)))
 	s.logger.Info("Reservas criadas", zap.Int("quantidade", len(reservas)))
 	return result, nil
 }
 
 // ConfirmarReserva confirma uma reserva
 func (s *EstoqueService) ConfirmarReserva(ctx context.Context, reservaID uuid.UUID) error {
-	if err := s.repo.ConfirmarReserva(ctx, reservaID); err != nil {
+	confirmadas, err := s.repo.ConfirmarReserva(ctx, reservaID)
+	if err != nil {
 		s.logger.Error("Erro ao confirmar reserva", zap.Error(err))
 		return err
 	}
@@ -243,13 +598,15 @@ func (s *EstoqueService) ConfirmarReserva(ctx context.Context, reservaID uuid.UU
 	// Invalidar cache
 	s.invalidateCache(ctx, "produtos:*")
 
+	metricas.Reservas.WithLabelValues(metricas.ReservaConfirmada).Add(float64(confirmadas))
 	s.logger.Info("Reserva confirmada", zap.String("reserva_id", reservaID.String()))
 	return nil
 }
 
 // CancelarReserva cancela uma reserva
 func (s *EstoqueService) CancelarReserva(ctx context.Context, reservaID uuid.UUID) error {
-	if err := s.repo.CancelarReserva(ctx, reservaID); err != nil {
+	canceladas, err := s.repo.CancelarReserva(ctx, reservaID)
+	if err != nil {
 		s.logger.Error("Erro ao cancelar reserva", zap.Error(err))
 		return err
 	}
@@ -257,64 +614,235 @@ func (s *EstoqueService) CancelarReserva(ctx context.Context, reservaID uuid.UUI
 	// Invalidar cache
 	s.invalidateCache(ctx, "produtos:*")
 
+	metricas.Reservas.WithLabelValues(metricas.ReservaCancelada).Add(float64(canceladas))
 	s.logger.Info("Reserva cancelada", zap.String("reserva_id", reservaID.String()))
 	return nil
 }
 
-// BaixarEstoque baixa estoque diretamente (endpoint para serviço de faturamento)
-func (s *EstoqueService) BaixarEstoque(ctx context.Context, itens []domain.ItemReserva) error {
-	for _, item := range itens {
-		// Lock para evitar concorrência
-		lockKey := fmt.Sprintf("produto:%s", item.ProdutoID.String())
-		lockValue, err := s.lock.AcquireLock(ctx, lockKey, 5*time.Second)
-		if err != nil {
-			return fmt.Errorf("não foi possível processar: %w", err)
-		}
+// BaixarEstoque baixa os itens diretamente, sem reserva, em uma única
+// transação. A baixa fica registrada pela operação (operacaoId ou, sem ela,
+// notaFiscalId): repeti-la devolve a baixa original sem movimentar estoque
+// de novo, e repeti-la com outros itens retorna ErrIdempotenciaDivergente.
+func (s *EstoqueService) BaixarEstoque(ctx context.Context, req domain.BaixarEstoqueRequest) (*domain.BaixaEstoque, error) {
+	operacaoID, ok := req.Operacao()
+	if !ok {
+		return nil, domain.ErrDadosInvalidos
+	}
 
-		if err := s.repo.BaixarEstoque(ctx, item.ProdutoID, item.Quantidade); err != nil {
-			s.lock.ReleaseLock(ctx, lockKey, lockValue)
-			s.logger.Error("Erro ao baixar estoque",
-				zap.String("produto_id", item.ProdutoID.String()),
-				zap.Error(err),
-			)
-			return err
+	// Lock por operação para que repetições simultâneas não disputem a baixa
+	lockKey := tenant.Chave(ctx, fmt.Sprintf("baixa:%s", operacaoID.String()))
+	lockValue, err := s.lock.AcquireLock(ctx, lockKey, s.opts.LockBaixaTTL)
+	if err != nil {
+		s.logger.Warn("Falha ao adquirir lock", zap.String("operacao_id", operacaoID.String()), zap.Error(err))
+		return nil, domain.ErrOperacaoEmAndamento
+	}
+	defer s.lock.ReleaseLock(ctx, lockKey, lockValue)
+
+	impressao := impressaoItens(req.Itens)
+	itens, err := s.converterItens(ctx, req.Itens)
+	if err != nil {
+		return nil, err
+	}
+
+	baixa, repetida, err := s.repo.BaixarEstoque(ctx, &domain.BaixaEstoque{
+		ID:           operacaoID,
+		NotaFiscalID: req.NotaFiscalID,
+		Impressao:    impressao,
+	}, itens)
+	if err != nil {
+		if errors.Is(err, domain.ErrEstoqueInsuficiente) {
+			metricas.EstoqueInsuficiente.WithLabelValues("baixa").Inc()
+		}
+		s.logger.Error("Erro ao baixar estoque",
+			zap.String("operacao_id", operacaoID.String()),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+	if repetida {
+		if baixa.Impressao != impressao {
+			return nil, domain.ErrIdempotenciaDivergente
 		}
+		s.logger.Info("Operação idempotente detectada", zap.String("operacao_id", operacaoID.String()))
+		return baixa, nil
+	}
+
+	// Invalidar cache
+	s.invalidateCache(ctx, "produtos:*")
+
+	metricas.Baixas.WithLabelValues(metricas.BaixaRealizada).Inc()
+	s.logger.Info("Estoque baixado", zap.String("operacao_id", operacaoID.String()), zap.Int("itens", len(baixa.Itens)))
+	return baixa, nil
+}
+
+// EstornarBaixa desfaz uma baixa direta, devolvendo ao estoque o que saiu.
+// Estornar de novo a mesma baixa não tem efeito.
+func (s *EstoqueService) EstornarBaixa(ctx context.Context, id uuid.UUID, motivo string) (*domain.BaixaEstoque, error) {
+	lockKey := tenant.Chave(ctx, fmt.Sprintf("baixa:%s", id.String()))
+	lockValue, err := s.lock.AcquireLock(ctx, lockKey, s.opts.LockBaixaTTL)
+	if err != nil {
+		s.logger.Warn("Falha ao adquirir lock", zap.String("operacao_id", id.String()), zap.Error(err))
+		return nil, domain.ErrOperacaoEmAndamento
+	}
+	defer s.lock.ReleaseLock(ctx, lockKey, lockValue)
 
-		s.lock.ReleaseLock(ctx, lockKey, lockValue)
+	if motivo == "" {
+		motivo = "estorno de baixa"
+	}
+	baixa, err := s.repo.EstornarBaixa(ctx, id, motivo)
+	if err != nil {
+		return nil, err
 	}
 
 	// Invalidar cache
 	s.invalidateCache(ctx, "produtos:*")
 
-	s.logger.Info("Estoque baixado", zap.Int("itens", len(itens)))
-	return nil
+	metricas.Baixas.WithLabelValues(metricas.BaixaEstornada).Inc()
+	s.logger.Info("Baixa estornada", zap.String("operacao_id", id.String()))
+	return baixa, nil
 }
 
-// VerificarDisponibilidade verifica se há estoque disponível
-func (s *EstoqueService) VerificarDisponibilidade(ctx context.Context, produtoID uuid.UUID, quantidade int) (bool, error) {
+// ObterBaixa retorna uma baixa direta pelo ID da operação
+func (s *EstoqueService) ObterBaixa(ctx context.Context, id uuid.UUID) (*domain.BaixaEstoque, error) {
+	return s.repo.FindBaixa(ctx, id)
+}
+
+// IniciarExpiracaoReservas expira periodicamente as reservas pendentes
+// vencidas até que ctx seja cancelado
+func (s *EstoqueService) IniciarExpiracaoReservas(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.ExpiracaoIntervalo)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.ExpirarReservas(ctx); err != nil && ctx.Err() == nil {
+				s.logger.Error("Erro ao expirar reservas", zap.Error(err))
+			}
+		}
+	}
+}
+
+// ExpirarReservas move as reservas pendentes vencidas para EXPIRADO e libera
+// o estoque reservado, empresa por empresa. Apenas uma réplica executa por
+// vez (lock distribuído, comum a todas as empresas, renovado a cada lote).
+// A falha em uma empresa é registrada e não impede as seguintes; o erro
+// retornado é o da última empresa que falhou.
+func (s *EstoqueService) ExpirarReservas(ctx context.Context) (int, error) {
+	lockKey := "reservas:expiracao"
+	lockValue, err := s.lock.AcquireLock(ctx, lockKey, s.opts.LockExpiracaoTTL)
+	if err != nil {
+		s.logger.Debug("Expiração de reservas em execução em outra instância", zap.Error(err))
+		return 0, nil
+	}
+	defer s.lock.ReleaseLock(ctx, lockKey, lockValue)
+
+	tenants, err := s.depositos.Tenants(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	var falha error
+	for _, id := range tenants {
+		n, err := s.expirarReservasTenant(tenant.Com(ctx, id), lockKey, lockValue)
+		total += n
+		if errors.Is(err, lock.ErrLockPerdido) || ctx.Err() != nil {
+			// Sem o lock outra réplica pode assumir a expiração
+			return total, err
+		}
+		if err != nil {
+			s.logger.Error("Erro ao expirar reservas da empresa", zap.String("tenant", id), zap.Error(err))
+			falha = err
+		}
+	}
+	return total, falha
+}
+
+func (s *EstoqueService) expirarReservasTenant(ctx context.Context, lockKey, lockValue string) (int, error) {
+	total := 0
+	for {
+		// Renova o lock para o próximo lote
+		if err := s.lock.ExtendLock(ctx, lockKey, lockValue, s.opts.LockExpiracaoTTL); err != nil {
+			return total, err
+		}
+		n, err := s.repo.ExpirarReservas(ctx, time.Now(), s.opts.ExpiracaoLote)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n < s.opts.ExpiracaoLote {
+			break
+		}
+	}
+
+	if total > 0 {
+		// Invalidar cache
+		s.invalidateCache(ctx, "produtos:*")
+
+		metricas.Reservas.WithLabelValues(metricas.ReservaExpirada).Add(float64(total))
+		id, _ := tenant.De(ctx)
+		s.logger.Info("Reservas expiradas", zap.String("tenant", id), zap.Int("quantidade", total))
+	}
+	return total, nil
+}
+
+// VerificarDisponibilidade verifica se há estoque disponível. unidade vazia
+// indica a unidade base do produto.
+func (s *EstoqueService) VerificarDisponibilidade(ctx context.Context, produtoID uuid.UUID, quantidade decimal.Decimal, unidade string) (bool, error) {
 	produto, err := s.repo.FindByID(ctx, produtoID)
 	if err != nil {
 		return false, err
 	}
 
+	quantidade, err = produto.ParaUnidadeBase(quantidade, unidade)
+	if err != nil {
+		return false, err
+	}
+
 	return produto.PodeReservar(quantidade), nil
 }
 
 // Helpers
 
-func (s *EstoqueService) cancelarReservasAnteriores(ctx context.Context, reservas []domain.ReservaEstoque) {
-	for _, reserva := range reservas {
-		if err := s.repo.CancelarReserva(ctx, reserva.ID); err != nil {
-			s.logger.Error("Erro ao cancelar reserva no rollback",
-				zap.String("reserva_id", reserva.ID.String()),
-				zap.Error(err),
-			)
+// converterItens valida as quantidades e converte cada item para a unidade
+// base do produto. Produtos inexistentes seguem como estão para que o
+// repositório os reporte.
+func (s *EstoqueService) converterItens(ctx context.Context, itens []domain.ItemReserva) ([]domain.ItemReserva, error) {
+	ids := make([]uuid.UUID, 0, len(itens))
+	for _, item := range itens {
+		ids = append(ids, item.ProdutoID)
+	}
+	produtos, err := s.repo.FindByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	porID := make(map[uuid.UUID]*domain.Produto, len(produtos))
+	for i := range produtos {
+		porID[produtos[i].ID] = &produtos[i]
+	}
+
+	convertidos := make([]domain.ItemReserva, len(itens))
+	for i, item := range itens {
+		if p, ok := porID[item.ProdutoID]; ok {
+			if item.Quantidade, err = p.ParaUnidadeBase(item.Quantidade, item.Unidade); err != nil {
+				return nil, err
+			}
+		} else if err := domain.ValidarQuantidade(item.Quantidade); err != nil {
+			return nil, err
 		}
+		item.Unidade = ""
+		convertidos[i] = item
 	}
+	return convertidos, nil
 }
 
+// invalidateCache apaga as chaves de cache da empresa do contexto que casam
+// com o padrão
 func (s *EstoqueService) invalidateCache(ctx context.Context, pattern string) {
-	keys, err := s.cache.Keys(ctx, pattern).Result()
+	keys, err := s.cache.Keys(ctx, tenant.Chave(ctx, pattern)).Result()
 	if err != nil {
 		s.logger.Warn("Erro ao invalidar cache", zap.Error(err))
 		return
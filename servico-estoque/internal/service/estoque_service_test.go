@@ -0,0 +1,237 @@
+// internal/service/estoque_service_test.go
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"servico-estoque/internal/domain"
+	"servico-estoque/internal/tenant"
+	"servico-estoque/pkg/lock"
+)
+
+func item(p *domain.Produto, quantidade int64, unidade string) domain.ItemReserva {
+	return domain.ItemReserva{ProdutoID: p.ID, Quantidade: decimal.NewFromInt(quantidade), Unidade: unidade}
+}
+
+func TestReservarProdutosRepeteAMesmaNotaSemReservarDeNovo(t *testing.T) {
+	parafuso := novoProdutoTeste("PAR-01", "Parafuso", 10)
+	repo := novoRepoFalso(parafuso)
+	s, _, ctx := novoServico(t, repo)
+	req := domain.ReservarEstoqueRequest{NotaFiscalID: uuid.New(), Itens: []domain.ItemReserva{item(parafuso, 4, "")}}
+
+	primeira, err := s.ReservarProdutos(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repetida, err := s.ReservarProdutos(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(repo.reservas) != 1 {
+		t.Errorf("ReservarEstoque chamado %d vezes, esperada 1", len(repo.reservas))
+	}
+	if !parafuso.Reservado.Equal(decimal.NewFromInt(4)) {
+		t.Errorf("reservado = %s, esperado 4", parafuso.Reservado)
+	}
+	if len(repetida.Reservas) != 1 || repetida.Reservas[0].ID != primeira.Reservas[0].ID {
+		t.Errorf("repetição = %+v, esperado o resultado original %+v", repetida, primeira)
+	}
+
+	outra := req
+	outra.Itens = []domain.ItemReserva{item(parafuso, 5, "")}
+	if _, err := s.ReservarProdutos(ctx, outra); !errors.Is(err, domain.ErrIdempotenciaDivergente) {
+		t.Errorf("mesma nota com outros itens = %v, esperado ErrIdempotenciaDivergente", err)
+	}
+	if len(repo.reservas) != 1 {
+		t.Errorf("ReservarEstoque chamado %d vezes, esperada 1", len(repo.reservas))
+	}
+}
+
+func TestReservarProdutosSemEstoqueNaoGuardaOResultado(t *testing.T) {
+	parafuso := novoProdutoTeste("PAR-01", "Parafuso", 3)
+	arruela := novoProdutoTeste("ARR-01", "Arruela", 10)
+	repo := novoRepoFalso(parafuso, arruela)
+	s, _, ctx := novoServico(t, repo)
+	req := domain.ReservarEstoqueRequest{
+		NotaFiscalID: uuid.New(),
+		Itens:        []domain.ItemReserva{item(arruela, 2, ""), item(parafuso, 4, "")},
+	}
+
+	if _, err := s.ReservarProdutos(ctx, req); !errors.Is(err, domain.ErrEstoqueInsuficiente) {
+		t.Fatalf("erro = %v, esperado ErrEstoqueInsuficiente", err)
+	}
+	if !arruela.Reservado.IsZero() {
+		t.Errorf("arruela reservada = %s em uma reserva recusada", arruela.Reservado)
+	}
+
+	// Com estoque, a mesma nota é reservada de novo em vez de repetir a falha
+	parafuso.Saldo = decimal.NewFromInt(4)
+	resultado, err := s.ReservarProdutos(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resultado.Reservas) != 2 || len(repo.reservas) != 2 {
+		t.Errorf("reservas = %d em %d chamadas, esperadas 2 em 2", len(resultado.Reservas), len(repo.reservas))
+	}
+}
+
+func TestReservarProdutosConverteParaAUnidadeBase(t *testing.T) {
+	parafuso := novoProdutoTeste("PAR-01", "Parafuso", 100)
+	parafuso.Conversoes = []domain.ConversaoUnidade{{Unidade: "CX", Fator: decimal.NewFromInt(12)}}
+	repo := novoRepoFalso(parafuso)
+	s, _, ctx := novoServico(t, repo)
+
+	req := domain.ReservarEstoqueRequest{NotaFiscalID: uuid.New(), Itens: []domain.ItemReserva{item(parafuso, 2, "CX")}}
+	if _, err := s.ReservarProdutos(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	pedido := repo.reservas[0][0]
+	if !pedido.Quantidade.Equal(decimal.NewFromInt(24)) || pedido.Unidade != "" {
+		t.Errorf("item repassado = %s %q, esperado 24 na unidade base", pedido.Quantidade, pedido.Unidade)
+	}
+
+	req = domain.ReservarEstoqueRequest{NotaFiscalID: uuid.New(), Itens: []domain.ItemReserva{item(parafuso, 1, "DZ")}}
+	if _, err := s.ReservarProdutos(ctx, req); !errors.Is(err, domain.ErrUnidadeInvalida) {
+		t.Errorf("unidade desconhecida = %v, esperado ErrUnidadeInvalida", err)
+	}
+}
+
+func TestReservarProdutosComANotaEmAndamento(t *testing.T) {
+	parafuso := novoProdutoTeste("PAR-01", "Parafuso", 10)
+	repo := novoRepoFalso(parafuso)
+	s, mr, ctx := novoServico(t, repo)
+	nota := uuid.New()
+
+	// Outra réplica reservando a mesma nota
+	mr.Set(lock.Chave(tenant.Chave(ctx, fmt.Sprintf("nota:%s", nota))), "outra-replica")
+
+	req := domain.ReservarEstoqueRequest{NotaFiscalID: nota, Itens: []domain.ItemReserva{item(parafuso, 1, "")}}
+	if _, err := s.ReservarProdutos(ctx, req); !errors.Is(err, domain.ErrOperacaoEmAndamento) {
+		t.Errorf("erro = %v, esperado ErrOperacaoEmAndamento", err)
+	}
+	if len(repo.reservas) != 0 {
+		t.Errorf("ReservarEstoque chamado com a nota em andamento")
+	}
+}
+
+func TestBaixarEstoqueRepeteAMesmaOperacaoSemBaixarDeNovo(t *testing.T) {
+	parafuso := novoProdutoTeste("PAR-01", "Parafuso", 10)
+	repo := novoRepoFalso(parafuso)
+	s, _, ctx := novoServico(t, repo)
+	operacao := uuid.New()
+	req := domain.BaixarEstoqueRequest{OperacaoID: &operacao, Itens: []domain.ItemReserva{item(parafuso, 4, "")}}
+
+	primeira, err := s.BaixarEstoque(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repetida, err := s.BaixarEstoque(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if primeira.ID != operacao || repetida != primeira {
+		t.Errorf("repetição = %+v, esperada a baixa original %+v", repetida, primeira)
+	}
+	if !parafuso.Saldo.Equal(decimal.NewFromInt(6)) {
+		t.Errorf("saldo = %s, esperado 6", parafuso.Saldo)
+	}
+
+	outra := req
+	outra.Itens = []domain.ItemReserva{item(parafuso, 5, "")}
+	if _, err := s.BaixarEstoque(ctx, outra); !errors.Is(err, domain.ErrIdempotenciaDivergente) {
+		t.Errorf("mesma operação com outros itens = %v, esperado ErrIdempotenciaDivergente", err)
+	}
+	if !parafuso.Saldo.Equal(decimal.NewFromInt(6)) {
+		t.Errorf("saldo = %s após operação divergente, esperado 6", parafuso.Saldo)
+	}
+}
+
+func TestBaixarEstoqueIdentificaAOperacao(t *testing.T) {
+	parafuso := novoProdutoTeste("PAR-01", "Parafuso", 10)
+	operacao, nota := uuid.New(), uuid.New()
+	itens := []domain.ItemReserva{item(parafuso, 1, "")}
+
+	casos := []struct {
+		nome     string
+		req      domain.BaixarEstoqueRequest
+		esperado uuid.UUID
+		erro     error
+	}{
+		{"pela operação", domain.BaixarEstoqueRequest{OperacaoID: &operacao, NotaFiscalID: &nota, Itens: itens}, operacao, nil},
+		{"pela nota sem operação", domain.BaixarEstoqueRequest{NotaFiscalID: &nota, Itens: itens}, nota, nil},
+		{"operação nula usa a nota", domain.BaixarEstoqueRequest{OperacaoID: &uuid.Nil, NotaFiscalID: &nota, Itens: itens}, nota, nil},
+		{"sem operação nem nota", domain.BaixarEstoqueRequest{Itens: itens}, uuid.Nil, domain.ErrDadosInvalidos},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			s, _, ctx := novoServico(t, novoRepoFalso(parafuso))
+			baixa, err := s.BaixarEstoque(ctx, c.req)
+			if !errors.Is(err, c.erro) {
+				t.Fatalf("erro = %v, esperado %v", err, c.erro)
+			}
+			if err == nil && baixa.ID != c.esperado {
+				t.Errorf("baixa %s, esperada %s", baixa.ID, c.esperado)
+			}
+		})
+	}
+}
+
+func TestBaixarEstoqueComAOperacaoEmAndamento(t *testing.T) {
+	parafuso := novoProdutoTeste("PAR-01", "Parafuso", 10)
+	repo := novoRepoFalso(parafuso)
+	s, mr, ctx := novoServico(t, repo)
+	operacao := uuid.New()
+
+	mr.Set(lock.Chave(tenant.Chave(ctx, fmt.Sprintf("baixa:%s", operacao))), "outra-replica")
+
+	req := domain.BaixarEstoqueRequest{OperacaoID: &operacao, Itens: []domain.ItemReserva{item(parafuso, 1, "")}}
+	if _, err := s.BaixarEstoque(ctx, req); !errors.Is(err, domain.ErrOperacaoEmAndamento) {
+		t.Errorf("erro = %v, esperado ErrOperacaoEmAndamento", err)
+	}
+	if len(repo.baixas) != 0 || !parafuso.Saldo.Equal(decimal.NewFromInt(10)) {
+		t.Errorf("baixa executada com a operação em andamento")
+	}
+}
+
+func TestExpirarReservasSegueParaAsProximasEmpresasERenovaOLock(t *testing.T) {
+	repo := novoRepoFalso()
+	s, mr, ctx := novoServico(t, repo)
+	s.depositos = depositosFalso{tenants: []string{"11111111111111", "22222222222222", "33333333333333"}}
+	s.opts.ExpiracaoLote = 2
+	s.opts.LockExpiracaoTTL = 30 * time.Second
+
+	falha := errors.New("falha no banco")
+	repo.falhaExpiracao["11111111111111"] = falha
+	repo.vencidas["22222222222222"] = 5
+	repo.vencidas["33333333333333"] = 1
+
+	// Cada lote leva 20s: sem a renovação o lock venceria no segundo
+	lockKey := lock.Chave("reservas:expiracao")
+	chamadas := 0
+	repo.aoExpirar = func() {
+		chamadas++
+		if !mr.Exists(lockKey) {
+			t.Errorf("lock perdido na chamada %d", chamadas)
+		}
+		mr.FastForward(20 * time.Second)
+	}
+
+	total, err := s.ExpirarReservas(ctx)
+	if !errors.Is(err, falha) {
+		t.Errorf("erro = %v, esperado o da empresa que falhou", err)
+	}
+	if total != 6 || repo.vencidas["22222222222222"] != 0 || repo.vencidas["33333333333333"] != 0 {
+		t.Errorf("expiradas = %d, restantes = %v; esperadas todas as 6 das demais empresas", total, repo.vencidas)
+	}
+	if mr.Exists(lockKey) {
+		t.Error("lock não liberado ao final")
+	}
+}
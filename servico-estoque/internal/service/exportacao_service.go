@@ -0,0 +1,43 @@
+// internal/service/exportacao_service.go
+package service
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"servico-estoque/internal/domain"
+)
+
+// ExportarProdutos entrega a fn, um a um, todos os produtos que atendem aos
+// filtros da listagem, na ordem pedida e com o disponível calculado. Com
+// valorizar, cada linha traz também o custo médio e o valor do estoque.
+// Paginação e cursor do filtro são ignorados e o cache não é usado.
+func (s *EstoqueService) ExportarProdutos(ctx context.Context, filtro domain.FiltroProdutos, valorizar bool, fn func(domain.ProdutoExportado) error) error {
+	if filtro.Ordem == "" {
+		filtro.Ordem = domain.OrdemCodigo
+	}
+	if filtro.CategoriaID != nil {
+		if _, err := s.categorias.FindByID(ctx, *filtro.CategoriaID); err != nil {
+			return err
+		}
+	}
+
+	return s.repo.ExportarProdutos(ctx, filtro, func(p *domain.Produto) error {
+		return fn(domain.NovoProdutoExportado(*p, valorizar))
+	})
+}
+
+// ExportarMovimentos entrega a fn, em ordem cronológica, os movimentos do
+// kardex no período do filtro, de um produto ou de todos
+func (s *EstoqueService) ExportarMovimentos(ctx context.Context, filtro domain.FiltroMovimentos, fn func(domain.MovimentoExportado) error) error {
+	if filtro.ProdutoID != uuid.Nil {
+		if _, err := s.repo.FindByID(ctx, filtro.ProdutoID); err != nil {
+			return err
+		}
+	}
+
+	return s.repo.ExportarMovimentos(ctx, filtro, func(m *domain.MovimentoExportado) error {
+		return fn(*m)
+	})
+}
@@ -0,0 +1,316 @@
+// internal/service/importacao_service.go
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+
+	"servico-estoque/internal/domain"
+	"servico-estoque/pkg/planilha"
+)
+
+// Colunas aceitas na importação. Categoria e deposito são códigos; unidade,
+// controlaLote e controlaSerie só valem na criação do produto.
+var colunasImportacao = []string{
+	"codigo", "descricao", "saldo", "custounitario", "unidade",
+	"controlalote", "controlaserie", "categoria", "deposito",
+	"ncm", "cest", "origem", "cfop", "unidadecomercial", "unidadetributavel", "gtin",
+}
+
+var colunasFiscais = []string{"ncm", "cest", "origem", "cfop", "unidadecomercial", "unidadetributavel", "gtin"}
+
+// linhaImportacao dá acesso às células de uma linha pelo nome da coluna
+type linhaImportacao struct {
+	campos  []string
+	indices map[string]int
+}
+
+func (l linhaImportacao) tem(coluna string) bool {
+	_, ok := l.indices[coluna]
+	return ok
+}
+
+func (l linhaImportacao) valor(coluna string) string {
+	i, ok := l.indices[coluna]
+	if !ok || i >= len(l.campos) {
+		return ""
+	}
+	return strings.TrimSpace(l.campos[i])
+}
+
+// cadastrosImportacao guarda os códigos de categorias e depósitos, lidos uma
+// vez por importação
+type cadastrosImportacao struct {
+	categorias map[string]uuid.UUID
+	depositos  map[string]uuid.UUID
+}
+
+// ImportarProdutos cria (ou, com Upsert, atualiza) produtos a partir de um
+// CSV ou XLSX. Cada linha passa pelas mesmas regras de CriarProduto e
+// AtualizarProduto e é gravada em sua própria transação: linhas com erro
+// entram no relatório sem impedir as demais. Em dry-run nada é gravado.
+func (s *EstoqueService) ImportarProdutos(ctx context.Context, r io.Reader, formato string, opcoes domain.OpcoesImportacao) (*domain.ResultadoImportacao, error) {
+	linhas, err := planilha.Ler(r, formato)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrPlanilhaInvalida, err)
+	}
+	if len(linhas) == 0 {
+		return nil, fmt.Errorf("%w: arquivo vazio", domain.ErrPlanilhaInvalida)
+	}
+	indices, err := lerCabecalho(linhas[0].Campos)
+	if err != nil {
+		return nil, err
+	}
+	cadastros, err := s.cadastrosImportacao(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resultado := &domain.ResultadoImportacao{
+		DryRun: opcoes.DryRun,
+		Upsert: opcoes.Upsert,
+		Linhas: []domain.LinhaImportacao{},
+	}
+	vistos := map[string]int{}
+	var gravados []*domain.Produto
+
+	for _, l := range linhas[1:] {
+		linha := linhaImportacao{campos: l.Campos, indices: indices}
+		item := domain.LinhaImportacao{Linha: l.Numero, Codigo: linha.valor("codigo")}
+
+		if anterior, ok := vistos[item.Codigo]; ok && item.Codigo != "" {
+			item.Acao = domain.ImportacaoErro
+			item.Erro = fmt.Sprintf("%s na linha %d do arquivo", domain.ErrCodigoDuplicado, anterior)
+			resultado.Registrar(item)
+			continue
+		}
+		vistos[item.Codigo] = l.Numero
+
+		produto, acao, err := s.importarLinha(ctx, linha, cadastros, opcoes)
+		if err != nil {
+			item.Acao = domain.ImportacaoErro
+			item.Erro = err.Error()
+		} else {
+			item.Acao = acao
+			if !opcoes.DryRun {
+				gravados = append(gravados, produto)
+			}
+		}
+		resultado.Registrar(item)
+	}
+
+	if len(gravados) > 0 {
+		// Invalidar cache uma vez para o lote inteiro
+		s.invalidateCache(ctx, "produtos:*")
+		for _, p := range gravados {
+			s.indexarSugestao(ctx, p)
+		}
+	}
+
+	s.logger.Info("Importação de produtos concluída",
+		zap.Bool("dry_run", opcoes.DryRun),
+		zap.Int("criados", resultado.Criados),
+		zap.Int("atualizados", resultado.Atualizados),
+		zap.Int("erros", resultado.Erros),
+	)
+	return resultado, nil
+}
+
+// importarLinha valida a linha e, fora do dry-run, grava o produto
+func (s *EstoqueService) importarLinha(ctx context.Context, linha linhaImportacao, cadastros *cadastrosImportacao, opcoes domain.OpcoesImportacao) (*domain.Produto, string, error) {
+	req, err := requisicaoImportacao(linha, cadastros)
+	if err != nil {
+		return nil, "", err
+	}
+
+	existente, err := s.repo.FindByCodigo(ctx, req.Codigo)
+	if err != nil {
+		return nil, "", err
+	}
+	if existente != nil && opcoes.Upsert {
+		ajuste, err := s.aplicarAtualizacao(ctx, existente, atualizacaoImportacao(linha, req))
+		if err != nil {
+			return nil, "", err
+		}
+		if !opcoes.DryRun {
+			if err := s.repo.Update(ctx, existente, ajuste, nil); err != nil {
+				return nil, "", err
+			}
+		}
+		return existente, domain.ImportacaoAtualizar, nil
+	}
+
+	produto, err := s.novoProduto(ctx, req)
+	if err != nil {
+		return nil, "", err
+	}
+	if !opcoes.DryRun {
+		if err := s.repo.Create(ctx, produto, req.DepositoID); err != nil {
+			return nil, "", err
+		}
+	}
+	return produto, domain.ImportacaoCriar, nil
+}
+
+// lerCabecalho mapeia cada coluna conhecida para sua posição. Os nomes são
+// comparados sem acentos, maiúsculas, espaços ou sublinhados.
+func lerCabecalho(campos []string) (map[string]int, error) {
+	conhecidas := map[string]bool{}
+	for _, c := range colunasImportacao {
+		conhecidas[c] = true
+	}
+
+	indices := map[string]int{}
+	for i, c := range campos {
+		nome := strings.NewReplacer(" ", "", "_", "").Replace(domain.NormalizarTermo(c))
+		if !conhecidas[nome] {
+			return nil, fmt.Errorf("%w: coluna desconhecida %q", domain.ErrPlanilhaInvalida, c)
+		}
+		if _, ok := indices[nome]; ok {
+			return nil, fmt.Errorf("%w: coluna repetida %q", domain.ErrPlanilhaInvalida, c)
+		}
+		indices[nome] = i
+	}
+	for _, obrigatoria := range []string{"codigo", "descricao"} {
+		if _, ok := indices[obrigatoria]; !ok {
+			return nil, fmt.Errorf("%w: coluna obrigatória %q ausente", domain.ErrPlanilhaInvalida, obrigatoria)
+		}
+	}
+	return indices, nil
+}
+
+func (s *EstoqueService) cadastrosImportacao(ctx context.Context) (*cadastrosImportacao, error) {
+	c := &cadastrosImportacao{
+		categorias: map[string]uuid.UUID{},
+		depositos:  map[string]uuid.UUID{},
+	}
+	categorias, err := s.categorias.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, cat := range categorias {
+		c.categorias[cat.Codigo] = cat.ID
+	}
+	depositos, err := s.depositos.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range depositos {
+		c.depositos[d.Codigo] = d.ID
+	}
+	return c, nil
+}
+
+// requisicaoImportacao converte a linha na requisição de cadastro
+func requisicaoImportacao(linha linhaImportacao, cadastros *cadastrosImportacao) (domain.CriarProdutoRequest, error) {
+	req := domain.CriarProdutoRequest{
+		Codigo:    linha.valor("codigo"),
+		Descricao: linha.valor("descricao"),
+		Unidade:   strings.ToUpper(linha.valor("unidade")),
+		Fiscal: domain.DadosFiscais{
+			NCM:               linha.valor("ncm"),
+			CEST:              linha.valor("cest"),
+			CFOP:              linha.valor("cfop"),
+			UnidadeComercial:  linha.valor("unidadecomercial"),
+			UnidadeTributavel: linha.valor("unidadetributavel"),
+			GTIN:              linha.valor("gtin"),
+		},
+	}
+	if req.Codigo == "" || req.Descricao == "" {
+		return req, fmt.Errorf("%w: codigo e descricao são obrigatórios", domain.ErrDadosInvalidos)
+	}
+
+	var err error
+	if req.Saldo, err = lerDecimal(linha.valor("saldo")); err != nil {
+		return req, fmt.Errorf("saldo: %w", domain.ErrQuantidadeInvalida)
+	}
+	if req.CustoUnitario, err = lerDecimal(linha.valor("custounitario")); err != nil {
+		return req, fmt.Errorf("custoUnitario: %w", domain.ErrDadosInvalidos)
+	}
+	if req.ControlaLote, err = lerBooleano(linha.valor("controlalote")); err != nil {
+		return req, fmt.Errorf("controlaLote: %w", domain.ErrDadosInvalidos)
+	}
+	if req.ControlaSerie, err = lerBooleano(linha.valor("controlaserie")); err != nil {
+		return req, fmt.Errorf("controlaSerie: %w", domain.ErrDadosInvalidos)
+	}
+	if v := linha.valor("origem"); v != "" {
+		if req.Fiscal.Origem, err = strconv.Atoi(v); err != nil {
+			return req, fmt.Errorf("origem: %w", domain.ErrDadosFiscaisInvalidos)
+		}
+	}
+	if v := linha.valor("categoria"); v != "" {
+		id, ok := cadastros.categorias[v]
+		if !ok {
+			return req, fmt.Errorf("%w: %s", domain.ErrCategoriaNaoEncontrada, v)
+		}
+		req.CategoriaID = &id
+	}
+	if v := linha.valor("deposito"); v != "" {
+		id, ok := cadastros.depositos[v]
+		if !ok {
+			return req, fmt.Errorf("%w: %s", domain.ErrDepositoNaoEncontrado, v)
+		}
+		req.DepositoID = &id
+	}
+	return req, nil
+}
+
+// atualizacaoImportacao monta a atualização de um produto existente com as
+// colunas presentes no arquivo. Saldo vazio mantém o saldo atual.
+func atualizacaoImportacao(linha linhaImportacao, req domain.CriarProdutoRequest) domain.AtualizarProdutoRequest {
+	atualizacao := domain.AtualizarProdutoRequest{
+		Descricao:  &req.Descricao,
+		DepositoID: req.DepositoID,
+		Motivo:     "importação de produtos",
+	}
+	if linha.tem("categoria") {
+		// Categoria em branco tira o produto da categoria
+		categoriaID := uuid.Nil
+		if req.CategoriaID != nil {
+			categoriaID = *req.CategoriaID
+		}
+		atualizacao.CategoriaID = &categoriaID
+	}
+	if linha.valor("saldo") != "" {
+		atualizacao.Saldo = &req.Saldo
+		if linha.valor("custounitario") != "" {
+			atualizacao.CustoUnitario = &req.CustoUnitario
+		}
+	}
+	for _, c := range colunasFiscais {
+		if linha.tem(c) {
+			atualizacao.Fiscal = &req.Fiscal
+			break
+		}
+	}
+	return atualizacao
+}
+
+// lerDecimal aceita vírgula decimal ("10,5"); vazio é zero
+func lerDecimal(v string) (decimal.Decimal, error) {
+	if v == "" {
+		return decimal.Zero, nil
+	}
+	if strings.Contains(v, ",") && !strings.Contains(v, ".") {
+		v = strings.Replace(v, ",", ".", 1)
+	}
+	return decimal.NewFromString(v)
+}
+
+// lerBooleano aceita sim/não, s/n, true/false e 1/0; vazio é falso
+func lerBooleano(v string) (bool, error) {
+	switch domain.NormalizarTermo(v) {
+	case "", "nao", "n", "false", "0":
+		return false, nil
+	case "sim", "s", "true", "1":
+		return true, nil
+	}
+	return false, fmt.Errorf("valor booleano inválido: %q", v)
+}
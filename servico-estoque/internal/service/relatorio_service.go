@@ -0,0 +1,20 @@
+// internal/service/relatorio_service.go
+package service
+
+import (
+	"context"
+	"time"
+
+	"servico-estoque/internal/domain"
+)
+
+// Valorizacao calcula o valor do estoque pelo custo médio, por produto e por
+// depósito. Com data, usa a posição e o custo médio ao fim daquela data,
+// obtidos desfazendo sobre o saldo atual os movimentos do kardex posteriores.
+func (s *EstoqueService) Valorizacao(ctx context.Context, data *time.Time) (*domain.Valorizacao, error) {
+	posicoes, err := s.repo.PosicoesEstoque(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	return domain.Valorizar(posicoes, data), nil
+}
@@ -0,0 +1,205 @@
+// internal/service/servico_test.go
+package service
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+
+	"servico-estoque/internal/domain"
+	"servico-estoque/internal/repository"
+	"servico-estoque/internal/tenant"
+	"servico-estoque/pkg/lock"
+)
+
+// empresaTeste é o CNPJ usado como empresa nos testes
+const empresaTeste = "11222333000181"
+
+// repoFalso guarda os produtos em memória e implementa só os métodos do
+// repositório usados nos testes; os demais entram em pânico pela interface
+// embutida, que fica nil
+type repoFalso struct {
+	repository.ProdutoRepository
+
+	produtos map[uuid.UUID]*domain.Produto
+	// aoListar, se definida, roda antes de FindAll devolver os produtos
+	aoListar func()
+	// reservas guarda os itens de cada chamada a ReservarEstoque
+	reservas [][]domain.ItemReserva
+	// baixas guarda as baixas diretas pelo ID da operação
+	baixas map[uuid.UUID]*domain.BaixaEstoque
+	// vencidas é o número de reservas vencidas por empresa e falhaExpiracao
+	// o erro que ExpirarReservas retorna para a empresa
+	vencidas       map[string]int
+	falhaExpiracao map[string]error
+	// aoExpirar, se definida, roda a cada chamada de ExpirarReservas
+	aoExpirar func()
+}
+
+func novoRepoFalso(produtos ...*domain.Produto) *repoFalso {
+	r := &repoFalso{
+		produtos:       map[uuid.UUID]*domain.Produto{},
+		baixas:         map[uuid.UUID]*domain.BaixaEstoque{},
+		vencidas:       map[string]int{},
+		falhaExpiracao: map[string]error{},
+	}
+	for _, p := range produtos {
+		r.produtos[p.ID] = p
+	}
+	return r
+}
+
+// FindAll devolve todos os produtos em ordem de código, numa página só
+func (r *repoFalso) FindAll(ctx context.Context, filtro domain.FiltroProdutos) ([]domain.Produto, int64, error) {
+	var produtos []domain.Produto
+	if filtro.Cursor == nil {
+		for _, p := range r.produtos {
+			produtos = append(produtos, *p)
+		}
+	}
+	sort.Slice(produtos, func(i, j int) bool { return produtos[i].Codigo < produtos[j].Codigo })
+	if r.aoListar != nil {
+		r.aoListar()
+	}
+	return produtos, int64(len(produtos)), nil
+}
+
+func (r *repoFalso) FindByID(ctx context.Context, id uuid.UUID) (*domain.Produto, error) {
+	p, ok := r.produtos[id]
+	if !ok {
+		return nil, domain.ErrProdutoNaoEncontrado
+	}
+	copia := *p
+	return &copia, nil
+}
+
+func (r *repoFalso) FindByIDs(ctx context.Context, ids []uuid.UUID) ([]domain.Produto, error) {
+	var produtos []domain.Produto
+	for _, id := range ids {
+		if p, ok := r.produtos[id]; ok {
+			produtos = append(produtos, *p)
+		}
+	}
+	return produtos, nil
+}
+
+// ReservarEstoque reserva todos os itens ou nenhum, como o repositório real
+func (r *repoFalso) ReservarEstoque(ctx context.Context, notaID uuid.UUID, itens []domain.ItemReserva, expiresAt time.Time) ([]domain.ReservaEstoque, error) {
+	r.reservas = append(r.reservas, itens)
+	pedido := map[uuid.UUID]decimal.Decimal{}
+	for _, item := range itens {
+		p, ok := r.produtos[item.ProdutoID]
+		if !ok {
+			return nil, domain.ErrProdutoNaoEncontrado
+		}
+		pedido[p.ID] = pedido[p.ID].Add(item.Quantidade)
+		if p.Saldo.Sub(p.Reservado).LessThan(pedido[p.ID]) {
+			return nil, domain.ErrEstoqueInsuficiente
+		}
+	}
+
+	reservas := make([]domain.ReservaEstoque, len(itens))
+	for i, item := range itens {
+		p := r.produtos[item.ProdutoID]
+		p.Reservado = p.Reservado.Add(item.Quantidade)
+		reservas[i] = domain.ReservaEstoque{
+			ID:           uuid.New(),
+			ProdutoID:    item.ProdutoID,
+			NotaFiscalID: notaID,
+			Quantidade:   item.Quantidade,
+			Status:       domain.StatusReservaPendente,
+			ExpiresAt:    expiresAt,
+		}
+	}
+	return reservas, nil
+}
+
+// BaixarEstoque baixa todos os itens ou nenhum e devolve a baixa já
+// registrada com o mesmo ID, como o repositório real
+func (r *repoFalso) BaixarEstoque(ctx context.Context, baixa *domain.BaixaEstoque, itens []domain.ItemReserva) (*domain.BaixaEstoque, bool, error) {
+	if existente, ok := r.baixas[baixa.ID]; ok {
+		return existente, true, nil
+	}
+	pedido := map[uuid.UUID]decimal.Decimal{}
+	for _, item := range itens {
+		p, ok := r.produtos[item.ProdutoID]
+		if !ok {
+			return nil, false, domain.ErrProdutoNaoEncontrado
+		}
+		pedido[p.ID] = pedido[p.ID].Add(item.Quantidade)
+		if p.Saldo.LessThan(pedido[p.ID]) {
+			return nil, false, domain.ErrEstoqueInsuficiente
+		}
+	}
+
+	baixa.Status = domain.StatusBaixaEfetivada
+	for _, item := range itens {
+		p := r.produtos[item.ProdutoID]
+		p.Saldo = p.Saldo.Sub(item.Quantidade)
+		baixa.Itens = append(baixa.Itens, domain.ItemBaixa{ID: uuid.New(), BaixaID: baixa.ID, ProdutoID: p.ID, Quantidade: item.Quantidade})
+	}
+	r.baixas[baixa.ID] = baixa
+	return baixa, false, nil
+}
+
+// ExpirarReservas expira até limite reservas vencidas da empresa do contexto
+func (r *repoFalso) ExpirarReservas(ctx context.Context, agora time.Time, limite int) (int, error) {
+	if r.aoExpirar != nil {
+		r.aoExpirar()
+	}
+	id, _ := tenant.De(ctx)
+	if err := r.falhaExpiracao[id]; err != nil {
+		return 0, err
+	}
+	n := min(r.vencidas[id], limite)
+	r.vencidas[id] -= n
+	return n, nil
+}
+
+// depositosFalso lista as empresas informadas; os demais métodos entram em
+// pânico pela interface embutida
+type depositosFalso struct {
+	repository.DepositoRepository
+	tenants []string
+}
+
+func (d depositosFalso) Tenants(ctx context.Context) ([]string, error) {
+	return d.tenants, nil
+}
+
+// novoServico cria o serviço sobre o repositório informado e um Redis em
+// memória, e devolve um contexto da empresa de teste
+func novoServico(t *testing.T, repo repository.ProdutoRepository) (*EstoqueService, *miniredis.Miniredis, context.Context) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	s := NewEstoqueService(repo, nil, nil, rdb, lock.NewDistributedLock(rdb), Options{
+		ReservaTTL:      10 * time.Minute,
+		IdempotenciaTTL: time.Hour,
+		CacheProdutoTTL: time.Minute,
+		CacheListaTTL:   time.Minute,
+		LockReservaTTL:  10 * time.Second,
+		LockBaixaTTL:    5 * time.Second,
+	}, zap.NewNop())
+	return s, mr, tenant.Com(context.Background(), empresaTeste)
+}
+
+// novoProdutoTeste monta um produto com o saldo informado, sem gravá-lo
+func novoProdutoTeste(codigo, descricao string, saldo int64) *domain.Produto {
+	return &domain.Produto{
+		ID:        uuid.New(),
+		Codigo:    codigo,
+		Descricao: descricao,
+		Saldo:     decimal.NewFromInt(saldo),
+		Unidade:   domain.UnidadePadrao,
+	}
+}
@@ -0,0 +1,384 @@
+// internal/service/sugestao_service.go
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+
+	"servico-estoque/internal/domain"
+	"servico-estoque/internal/tenant"
+	"servico-estoque/pkg/lock"
+)
+
+// O autocomplete usa um sorted set com todos os membros de score 0, que o
+// Redis ordena lexicograficamente: cada membro é "termo\x00id". Uma busca
+// por prefixo é um ZRANGEBYLEX. Os dados exibidos e os termos de cada
+// produto ficam em um hash, para que atualizações removam os termos antigos.
+// Cada empresa tem o seu índice, sob o prefixo "tenant:<cnpj>:" como todo o
+// cache. As chaves ficam fora de "produtos:*" para não serem apagadas junto
+// com o cache; o disponível, que muda a cada reserva, é cacheado por produto sob
+// "produtos:disponivel:<id>" e invalidado com o restante do cache.
+const (
+	chaveSugestoesIndice    = "sugestoes:produtos:indice"
+	chaveSugestoesDados     = "sugestoes:produtos:dados"
+	chaveSugestoesAlterados = "sugestoes:produtos:alterados"
+	recursoReconstrucao     = "sugestoes:reconstrucao"
+	separadorSugestao       = "\x00"
+	loteReconstrucao        = 500
+	tentativasSugestao      = 10
+	ttlReconstrucao         = time.Minute
+)
+
+// marcarAlterado registra o produto entre os alterados durante a
+// reconstrução do índice, se houver uma em andamento (lock em KEYS[1])
+var marcarAlterado = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	redis.call("SADD", KEYS[2], ARGV[1])
+	redis.call("PEXPIRE", KEYS[2], ARGV[2])
+end
+return 0
+`)
+
+// chavesSugestoes são as chaves do índice de uma empresa. Nas chaves
+// temporárias da reconstrução, lock e alterados ficam vazios e a gravação
+// não marca o produto.
+type chavesSugestoes struct {
+	indice    string
+	dados     string
+	lock      string
+	alterados string
+}
+
+// chavesEmpresa retorna as chaves do índice da empresa do contexto
+func chavesEmpresa(ctx context.Context) chavesSugestoes {
+	return chavesSugestoes{
+		indice:    tenant.Chave(ctx, chaveSugestoesIndice),
+		dados:     tenant.Chave(ctx, chaveSugestoesDados),
+		lock:      lock.Chave(tenant.Chave(ctx, recursoReconstrucao)),
+		alterados: tenant.Chave(ctx, chaveSugestoesAlterados),
+	}
+}
+
+// dadosSugestao é o que o hash guarda de cada produto indexado
+type dadosSugestao struct {
+	Codigo    string   `json:"codigo"`
+	Descricao string   `json:"descricao"`
+	Termos    []string `json:"termos"`
+}
+
+// Sugestoes retorna até limite produtos cujo código, descrição ou alguma
+// palavra da descrição começa com prefixo, ignorando maiúsculas e acentos
+func (s *EstoqueService) Sugestoes(ctx context.Context, prefixo string, limite int) ([]domain.Sugestao, error) {
+	prefixo = domain.NormalizarTermo(prefixo)
+	sugestoes := []domain.Sugestao{}
+	if prefixo == "" {
+		return sugestoes, nil
+	}
+
+	// Um produto aparece uma vez por termo; lê alguns a mais para compensar
+	membros, err := s.cache.ZRangeByLex(ctx, tenant.Chave(ctx, chaveSugestoesIndice), &redis.ZRangeBy{
+		Min:   "[" + prefixo,
+		Max:   "[" + prefixo + "\xff",
+		Count: int64(limite * 4),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	vistos := map[string]bool{}
+	for _, m := range membros {
+		i := strings.LastIndex(m, separadorSugestao)
+		if i < 0 || vistos[m[i+1:]] {
+			continue
+		}
+		vistos[m[i+1:]] = true
+		ids = append(ids, m[i+1:])
+		if len(ids) == limite {
+			break
+		}
+	}
+	if len(ids) == 0 {
+		return sugestoes, nil
+	}
+
+	dados, err := s.cache.HMGet(ctx, tenant.Chave(ctx, chaveSugestoesDados), ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+	disponiveis, err := s.disponiveis(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, v := range dados {
+		bruto, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var d dadosSugestao
+		if err := json.Unmarshal([]byte(bruto), &d); err != nil {
+			continue
+		}
+		id, err := uuid.Parse(ids[i])
+		if err != nil {
+			continue
+		}
+		disponivel, ok := disponiveis[id]
+		if !ok {
+			// Produto removido entre a indexação e a consulta
+			continue
+		}
+		sugestoes = append(sugestoes, domain.Sugestao{
+			ID:         id,
+			Codigo:     d.Codigo,
+			Descricao:  d.Descricao,
+			Disponivel: disponivel,
+		})
+	}
+	return sugestoes, nil
+}
+
+// disponiveis lê o disponível dos produtos do cache e busca no banco, de uma
+// vez, apenas os que não estão cacheados
+func (s *EstoqueService) disponiveis(ctx context.Context, ids []string) (map[uuid.UUID]decimal.Decimal, error) {
+	chaves := make([]string, len(ids))
+	for i, id := range ids {
+		chaves[i] = tenant.Chave(ctx, fmt.Sprintf("produtos:disponivel:%s", id))
+	}
+	cacheados, err := s.cache.MGet(ctx, chaves...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	resultado := make(map[uuid.UUID]decimal.Decimal, len(ids))
+	var faltantes []uuid.UUID
+	for i, v := range cacheados {
+		id, err := uuid.Parse(ids[i])
+		if err != nil {
+			continue
+		}
+		if bruto, ok := v.(string); ok {
+			if d, err := decimal.NewFromString(bruto); err == nil {
+				resultado[id] = d
+				continue
+			}
+		}
+		faltantes = append(faltantes, id)
+	}
+	if len(faltantes) == 0 {
+		return resultado, nil
+	}
+
+	produtos, err := s.repo.FindByIDs(ctx, faltantes)
+	if err != nil {
+		return nil, err
+	}
+	pipe := s.cache.Pipeline()
+	for _, p := range produtos {
+		disponivel := p.Saldo.Sub(p.Reservado)
+		resultado[p.ID] = disponivel
+		pipe.Set(ctx, tenant.Chave(ctx, fmt.Sprintf("produtos:disponivel:%s", p.ID)), disponivel.String(), s.opts.CacheProdutoTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		s.logger.Warn("Erro ao cachear disponível das sugestões", zap.Error(err))
+	}
+	return resultado, nil
+}
+
+// indexarSugestao inclui ou atualiza o produto no autocomplete. Falhas são
+// apenas registradas: o índice é reconstruído na inicialização.
+func (s *EstoqueService) indexarSugestao(ctx context.Context, p *domain.Produto) {
+	novo := dadosSugestao{Codigo: p.Codigo, Descricao: p.Descricao, Termos: domain.TermosSugestao(*p)}
+	if err := gravarSugestao(ctx, s.cache, chavesEmpresa(ctx), p.ID, novo); err != nil {
+		s.logger.Warn("Erro ao indexar sugestão", zap.String("produto_id", p.ID.String()), zap.Error(err))
+	}
+}
+
+// removerSugestao retira o produto do autocomplete
+func (s *EstoqueService) removerSugestao(ctx context.Context, id uuid.UUID) {
+	if err := gravarSugestao(ctx, s.cache, chavesEmpresa(ctx), id, dadosSugestao{}); err != nil {
+		s.logger.Warn("Erro ao remover sugestão", zap.String("produto_id", id.String()), zap.Error(err))
+	}
+}
+
+// gravarSugestao troca os termos antigos do produto pelos de novo; novo sem
+// termos remove o produto do índice. Com chaves.lock, o produto é marcado
+// como alterado na mesma transação, se uma reconstrução estiver em
+// andamento, para que ela não o perca. O hash de dados fica em WATCH entre a
+// leitura dos termos antigos e a gravação: se outra gravação o alterar
+// nesse meio tempo, a leitura é refeita, para que termos removidos por uma
+// não fiquem no índice pela outra.
+func gravarSugestao(ctx context.Context, rdb *redis.Client, chaves chavesSugestoes, id uuid.UUID, novo dadosSugestao) error {
+	gravar := func(tx *redis.Tx) error {
+		var antigo dadosSugestao
+		bruto, err := tx.HGet(ctx, chaves.dados, id.String()).Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if err == nil {
+			_ = json.Unmarshal([]byte(bruto), &antigo)
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, t := range antigo.Termos {
+				pipe.ZRem(ctx, chaves.indice, t+separadorSugestao+id.String())
+			}
+			if len(novo.Termos) == 0 {
+				pipe.HDel(ctx, chaves.dados, id.String())
+			} else {
+				for _, t := range novo.Termos {
+					pipe.ZAdd(ctx, chaves.indice, &redis.Z{Member: t + separadorSugestao + id.String()})
+				}
+				novoJSON, _ := json.Marshal(novo)
+				pipe.HSet(ctx, chaves.dados, id.String(), novoJSON)
+			}
+			if chaves.lock != "" {
+				marcarAlterado.Eval(ctx, pipe, []string{chaves.lock, chaves.alterados}, id.String(), ttlReconstrucao.Milliseconds())
+			}
+			return nil
+		})
+		return err
+	}
+
+	for i := 0; i < tentativasSugestao; i++ {
+		err := rdb.Watch(ctx, gravar, chaves.dados)
+		if err != redis.TxFailedErr {
+			return err
+		}
+	}
+	return redis.TxFailedErr
+}
+
+// ReconstruirSugestoes recria o índice do autocomplete da empresa do
+// contexto a partir do banco.
+// O índice novo é montado em chaves temporárias e substitui o atual de uma
+// vez, sem deixar o autocomplete vazio durante a reconstrução. Ela roda sob
+// um lock por empresa, e uma segunda reconstrução simultânea (de outra
+// réplica) falha. Pode rodar com o serviço recebendo alterações: enquanto o
+// lock existe, cada gravação marca o produto como alterado, e esses
+// produtos são relidos do banco antes da troca, que só acontece se nenhum
+// outro tiver sido marcado desde a última releitura.
+func (s *EstoqueService) ReconstruirSugestoes(ctx context.Context) error {
+	recurso := tenant.Chave(ctx, recursoReconstrucao)
+	valor, err := s.lock.AcquireLock(ctx, recurso, ttlReconstrucao)
+	if err != nil {
+		return err
+	}
+	defer s.lock.ReleaseLock(ctx, recurso, valor)
+
+	atual := chavesEmpresa(ctx)
+	temporarias := chavesSugestoes{
+		indice: atual.indice + ":reconstrucao",
+		dados:  atual.dados + ":reconstrucao",
+	}
+	if err := s.cache.Del(ctx, temporarias.indice, temporarias.dados, atual.alterados).Err(); err != nil {
+		return err
+	}
+
+	filtro := domain.FiltroProdutos{Ordem: domain.OrdemCodigo, Page: 1, Size: loteReconstrucao}
+	total := 0
+	for {
+		produtos, _, err := s.repo.FindAll(ctx, filtro)
+		if err != nil {
+			return err
+		}
+		for i := range produtos {
+			p := &produtos[i]
+			d := dadosSugestao{Codigo: p.Codigo, Descricao: p.Descricao, Termos: domain.TermosSugestao(*p)}
+			if err := gravarSugestao(ctx, s.cache, temporarias, p.ID, d); err != nil {
+				return err
+			}
+		}
+		if err := s.lock.ExtendLock(ctx, recurso, valor, ttlReconstrucao); err != nil {
+			return err
+		}
+		total += len(produtos)
+		if len(produtos) < loteReconstrucao {
+			break
+		}
+		ultimo := produtos[len(produtos)-1]
+		filtro.Cursor = &domain.CursorProdutos{Ordem: filtro.Ordem, Valor: ultimo.Codigo, ID: ultimo.ID}
+	}
+
+	for trocado := false; !trocado; {
+		if err := s.reindexarAlterados(ctx, atual.alterados, temporarias); err != nil {
+			return err
+		}
+		err := s.cache.Watch(ctx, func(tx *redis.Tx) error {
+			pendentes, err := tx.SCard(ctx, atual.alterados).Result()
+			if err != nil || pendentes > 0 {
+				return err
+			}
+			existentes, err := tx.Exists(ctx, temporarias.indice, temporarias.dados).Result()
+			if err != nil {
+				return err
+			}
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				if existentes == 2 {
+					pipe.Rename(ctx, temporarias.indice, atual.indice)
+					pipe.Rename(ctx, temporarias.dados, atual.dados)
+				} else {
+					pipe.Del(ctx, atual.indice, atual.dados, temporarias.indice, temporarias.dados)
+				}
+				return nil
+			})
+			trocado = err == nil
+			return err
+		}, atual.alterados)
+		if err != nil && err != redis.TxFailedErr {
+			return err
+		}
+	}
+
+	id, _ := tenant.De(ctx)
+	s.logger.Info("Índice de sugestões reconstruído", zap.String("tenant", id), zap.Int("produtos", total))
+	return nil
+}
+
+// reindexarAlterados relê do banco os produtos marcados como alterados
+// durante a reconstrução e os grava nas chaves temporárias. Cada produto sai
+// do conjunto antes da leitura: uma gravação posterior o marca de novo.
+func (s *EstoqueService) reindexarAlterados(ctx context.Context, alterados string, temporarias chavesSugestoes) error {
+	for {
+		membros, err := s.cache.SPopN(ctx, alterados, loteReconstrucao).Result()
+		if err != nil {
+			return err
+		}
+		if len(membros) == 0 {
+			return nil
+		}
+
+		ids := make([]uuid.UUID, 0, len(membros))
+		for _, m := range membros {
+			if id, err := uuid.Parse(m); err == nil {
+				ids = append(ids, id)
+			}
+		}
+		produtos, err := s.repo.FindByIDs(ctx, ids)
+		if err != nil {
+			return err
+		}
+		encontrados := make(map[uuid.UUID]*domain.Produto, len(produtos))
+		for i := range produtos {
+			encontrados[produtos[i].ID] = &produtos[i]
+		}
+		for _, id := range ids {
+			var d dadosSugestao
+			if p, ok := encontrados[id]; ok {
+				d = dadosSugestao{Codigo: p.Codigo, Descricao: p.Descricao, Termos: domain.TermosSugestao(*p)}
+			}
+			if err := gravarSugestao(ctx, s.cache, temporarias, id, d); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// internal/service/sugestao_service_test.go
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+func TestReconstruirSugestoesMantemProdutosGravadosDuranteAReconstrucao(t *testing.T) {
+	parafuso := novoProdutoTeste("PAR-01", "Parafuso sextavado", 10)
+	repo := novoRepoFalso(parafuso)
+	s, mr, ctx := novoServico(t, repo)
+
+	// Um produto criado depois da leitura do banco pela reconstrução
+	arruela := novoProdutoTeste("ARR-01", "Arruela lisa", 5)
+	repo.aoListar = func() {
+		repo.aoListar = nil
+		repo.produtos[arruela.ID] = arruela
+		s.indexarSugestao(ctx, arruela)
+	}
+
+	if err := s.ReconstruirSugestoes(ctx); err != nil {
+		t.Fatalf("ReconstruirSugestoes: %v", err)
+	}
+
+	for prefixo, esperado := range map[string]string{"paraf": "PAR-01", "arru": "ARR-01"} {
+		sugestoes, err := s.Sugestoes(ctx, prefixo, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sugestoes) != 1 || sugestoes[0].Codigo != esperado {
+			t.Errorf("Sugestoes(%q) = %+v, esperado %s", prefixo, sugestoes, esperado)
+		}
+	}
+	for _, k := range mr.Keys() {
+		if k == chavesEmpresa(ctx).alterados || k == chavesEmpresa(ctx).indice+":reconstrucao" {
+			t.Errorf("chave %s ficou no Redis após a reconstrução", k)
+		}
+	}
+}
+
+func TestReconstruirSugestoesRemoveProdutoExcluidoDuranteAReconstrucao(t *testing.T) {
+	parafuso := novoProdutoTeste("PAR-01", "Parafuso sextavado", 10)
+	repo := novoRepoFalso(parafuso)
+	s, _, ctx := novoServico(t, repo)
+	s.indexarSugestao(ctx, parafuso)
+
+	// A exclusão chega depois de a reconstrução ler o produto do banco
+	repo.aoListar = func() {
+		repo.aoListar = nil
+		delete(repo.produtos, parafuso.ID)
+		s.removerSugestao(ctx, parafuso.ID)
+	}
+
+	if err := s.ReconstruirSugestoes(ctx); err != nil {
+		t.Fatalf("ReconstruirSugestoes: %v", err)
+	}
+	sugestoes, err := s.Sugestoes(ctx, "paraf", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sugestoes) != 0 {
+		t.Errorf("Sugestoes = %+v, esperado nenhuma", sugestoes)
+	}
+	membros, err := s.cache.ZRange(ctx, chavesEmpresa(ctx).indice, 0, -1).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(membros) != 0 {
+		t.Errorf("índice ainda tem %v", membros)
+	}
+}
+
+func TestGravarSugestaoConcorrenteNaoDeixaTermosOrfaos(t *testing.T) {
+	s, _, ctx := novoServico(t, novoRepoFalso())
+	chaves := chavesEmpresa(ctx)
+	id := uuid.New()
+
+	// Cada gravação troca todos os termos do mesmo produto; sem o WATCH,
+	// duas gravações leem os mesmos termos antigos e os termos de uma delas
+	// ficam no índice
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			novo := dadosSugestao{
+				Codigo: fmt.Sprintf("P-%d", i),
+				Termos: []string{fmt.Sprintf("termo-%d-a", i), fmt.Sprintf("termo-%d-b", i)},
+			}
+			if err := gravarSugestao(ctx, s.cache, chaves, id, novo); err != nil && err != redis.TxFailedErr {
+				t.Errorf("gravarSugestao: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	bruto, err := s.cache.HGet(ctx, chaves.dados, id.String()).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gravado dadosSugestao
+	if err := json.Unmarshal([]byte(bruto), &gravado); err != nil {
+		t.Fatal(err)
+	}
+	membros, err := s.cache.ZRange(ctx, chaves.indice, 0, -1).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	termos := make([]string, len(membros))
+	for i, m := range membros {
+		termos[i], _, _ = strings.Cut(m, separadorSugestao)
+	}
+	sort.Strings(gravado.Termos)
+	if strings.Join(termos, ",") != strings.Join(gravado.Termos, ",") {
+		t.Errorf("índice tem %v, esperados só os termos gravados %v", termos, gravado.Termos)
+	}
+}
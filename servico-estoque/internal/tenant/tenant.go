@@ -0,0 +1,45 @@
+// internal/tenant/tenant.go
+package tenant
+
+import "context"
+
+type chaveTenant struct{}
+
+type chaveSistema struct{}
+
+// Com retorna um contexto da empresa (tenant) informada. Consultas ao banco
+// e chaves do Redis feitas com ele ficam restritas a essa empresa.
+func Com(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, chaveTenant{}, id)
+}
+
+// De retorna a empresa do contexto
+func De(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(chaveTenant{}).(string)
+	return id, ok && id != ""
+}
+
+// Sistema marca o contexto de uma rotina que precisa enxergar todas as
+// empresas, como a consulta de uma chave de API antes de se saber de quem
+// ela é. Deve ser usado apenas em leituras pontuais.
+func Sistema(ctx context.Context) context.Context {
+	return context.WithValue(ctx, chaveSistema{}, true)
+}
+
+// EhSistema indica se o contexto foi marcado com Sistema
+func EhSistema(ctx context.Context) bool {
+	v, _ := ctx.Value(chaveSistema{}).(bool)
+	return v
+}
+
+// Chave prefixa uma chave (ou padrão) do Redis com a empresa do contexto:
+// "tenant:<id>:<chave>"
+func Chave(ctx context.Context, chave string) string {
+	id, _ := De(ctx)
+	return "tenant:" + id + ":" + chave
+}
+
+// ChaveTodos é o padrão que casa a chave em todas as empresas
+func ChaveTodos(chave string) string {
+	return "tenant:*:" + chave
+}
@@ -3,15 +3,24 @@ package lock
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
 )
 
+// ErrLockPerdido indica que o lock expirou ou foi adquirido por outro
+// cliente
+var ErrLockPerdido = errors.New("lock não pertence a este cliente ou já expirou")
+
 type DistributedLock struct {
 	redis *redis.Client
+	// adquiridos guarda quando cada lock (pelo valor) foi adquirido, para
+	// medir o tempo retido na liberação
+	adquiridos sync.Map
 }
 
 // NewDistributedLock cria uma nova instância do lock distribuído
@@ -19,28 +28,41 @@ func NewDistributedLock(redis *redis.Client) *DistributedLock {
 	return &DistributedLock{redis: redis}
 }
 
+// Chave retorna a chave do Redis que guarda o lock do recurso, para quem
+// precisa saber se ele está retido
+func Chave(resource string) string {
+	return fmt.Sprintf("lock:%s", resource)
+}
+
 // AcquireLock tenta adquirir um lock com TTL.
 // Retorna o valor único do lock (para liberação segura) ou erro.
 func (l *DistributedLock) AcquireLock(ctx context.Context, resource string, ttl time.Duration) (string, error) {
-	lockKey := fmt.Sprintf("lock:%s", resource)
+	lockKey := Chave(resource)
 	lockValue := uuid.New().String()
 
 	// SET key value NX PX milliseconds
 	ok, err := l.redis.SetNX(ctx, lockKey, lockValue, ttl).Result()
 	if err != nil {
+		aquisicoes.WithLabelValues(tipoRecurso(resource), resultadoErro).Inc()
 		return "", fmt.Errorf("erro ao tentar adquirir lock: %w", err)
 	}
 	if !ok {
+		aquisicoes.WithLabelValues(tipoRecurso(resource), resultadoOcupado).Inc()
 		return "", fmt.Errorf("lock já adquirido por outro processo: %s", resource)
 	}
 
+	aquisicoes.WithLabelValues(tipoRecurso(resource), resultadoSucesso).Inc()
+	l.adquiridos.Store(lockValue, time.Now())
 	return lockValue, nil
 }
 
 // ReleaseLock libera o lock de forma atômica usando Lua Script
 // Só libera se o valor for exatamente o mesmo que foi adquirido
 func (l *DistributedLock) ReleaseLock(ctx context.Context, resource, lockValue string) error {
-	lockKey := fmt.Sprintf("lock:%s", resource)
+	lockKey := Chave(resource)
+	if inicio, ok := l.adquiridos.LoadAndDelete(lockValue); ok {
+		tempoRetido.WithLabelValues(tipoRecurso(resource)).Observe(time.Since(inicio.(time.Time)).Seconds())
+	}
 
 	// Script Lua: só deleta se o valor bater exatamente
 	script := `
@@ -65,7 +87,7 @@ func (l *DistributedLock) ReleaseLock(ctx context.Context, resource, lockValue s
 
 // ExtendLock estende a expiração do lock (útil em operações longas)
 func (l *DistributedLock) ExtendLock(ctx context.Context, resource, lockValue string, ttl time.Duration) error {
-	lockKey := fmt.Sprintf("lock:%s", resource)
+	lockKey := Chave(resource)
 
 	script := `
 		if redis.call("GET", KEYS[1]) == ARGV[1] then
@@ -81,7 +103,7 @@ func (l *DistributedLock) ExtendLock(ctx context.Context, resource, lockValue st
 	}
 
 	if result.(int64) == 0 {
-		return fmt.Errorf("não foi possível estender: lock não pertence a este cliente")
+		return fmt.Errorf("não foi possível estender: %w", ErrLockPerdido)
 	}
 
 	return nil
@@ -0,0 +1,41 @@
+// pkg/lock/metricas.go
+package lock
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	aquisicoes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "estoque_lock_aquisicoes_total",
+		Help: "Tentativas de adquirir lock por tipo de recurso e resultado (sucesso, ocupado ou erro).",
+	}, []string{"recurso", "resultado"})
+
+	tempoRetido = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "estoque_lock_retencao_segundos",
+		Help:    "Tempo entre a aquisição e a liberação dos locks, por tipo de recurso.",
+		Buckets: []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"recurso"})
+)
+
+// Resultados de uma tentativa de aquisição
+const (
+	resultadoSucesso = "sucesso"
+	resultadoOcupado = "ocupado"
+	resultadoErro    = "erro"
+)
+
+// tipoRecurso reduz o recurso ao seu tipo, para o rótulo das métricas:
+// "tenant:<cnpj>:nota:<id>" vira "nota" e "reservas:expiracao", "reservas"
+func tipoRecurso(resource string) string {
+	if resto, ok := strings.CutPrefix(resource, "tenant:"); ok {
+		if _, depois, ok := strings.Cut(resto, ":"); ok {
+			resource = depois
+		}
+	}
+	tipo, _, _ := strings.Cut(resource, ":")
+	return tipo
+}
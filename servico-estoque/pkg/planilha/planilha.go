@@ -0,0 +1,118 @@
+// pkg/planilha/planilha.go
+package planilha
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Formatos de arquivo aceitos
+const (
+	FormatoCSV  = "csv"
+	FormatoXLSX = "xlsx"
+)
+
+// Linha é uma linha não vazia do arquivo. Numero é a posição no arquivo,
+// a partir de 1, para que relatórios de erro apontem a linha certa.
+type Linha struct {
+	Numero int
+	Campos []string
+}
+
+// FormatoDoArquivo deduz o formato pela extensão do nome do arquivo
+func FormatoDoArquivo(nome string) string {
+	return strings.TrimPrefix(strings.ToLower(filepath.Ext(nome)), ".")
+}
+
+// Ler retorna as linhas não vazias do CSV ou da primeira aba do XLSX. A
+// primeira linha é o cabeçalho. CSV separado por ponto e vírgula, como o
+// exportado pelo Excel em português, é detectado pelo cabeçalho.
+func Ler(r io.Reader, formato string) ([]Linha, error) {
+	switch formato {
+	case FormatoCSV:
+		return lerCSV(r)
+	case FormatoXLSX:
+		return lerXLSX(r)
+	default:
+		return nil, fmt.Errorf("formato não suportado: %q (use csv ou xlsx)", formato)
+	}
+}
+
+func lerCSV(r io.Reader) ([]Linha, error) {
+	br := bufio.NewReader(r)
+	if bom, _ := br.Peek(3); bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+		br.Discard(3)
+	}
+	inicio, err := br.Peek(br.Size())
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	if i := bytes.IndexByte(inicio, '\n'); i >= 0 {
+		inicio = inicio[:i]
+	}
+
+	leitor := csv.NewReader(br)
+	leitor.FieldsPerRecord = -1
+	leitor.TrimLeadingSpace = true
+	if bytes.Count(inicio, []byte(";")) > bytes.Count(inicio, []byte(",")) {
+		leitor.Comma = ';'
+	}
+
+	var linhas []Linha
+	for {
+		campos, err := leitor.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("CSV inválido: %w", err)
+		}
+		numero, _ := leitor.FieldPos(0)
+		if !vazia(campos) {
+			linhas = append(linhas, Linha{Numero: numero, Campos: campos})
+		}
+	}
+	return linhas, nil
+}
+
+func lerXLSX(r io.Reader) ([]Linha, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("XLSX inválido: %w", err)
+	}
+	defer f.Close()
+
+	abas := f.GetSheetList()
+	if len(abas) == 0 {
+		return nil, fmt.Errorf("XLSX sem planilhas")
+	}
+	// Valores crus: números sem a formatação de exibição da célula
+	rows, err := f.GetRows(abas[0], excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, fmt.Errorf("XLSX inválido: %w", err)
+	}
+
+	var linhas []Linha
+	for i, campos := range rows {
+		if !vazia(campos) {
+			linhas = append(linhas, Linha{Numero: i + 1, Campos: campos})
+		}
+	}
+	return linhas, nil
+}
+
+func vazia(campos []string) bool {
+	for _, v := range campos {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}